@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/djwhocodes/restaurant_management/database"
+)
+
+const usage = `usage: restaurant_management <command> [arguments]
+
+commands:
+  serve                   run the HTTP API (default)
+  migrate [up|status]     apply or list database migrations
+  seed                    load sample menus, foods and tables for development
+  user create             create a user with a role
+  user reset-password     set a user's password and sign them out
+  tokens revoke-all       sign out every user, or one with --email
+  keys list|rotate|prune  manage the token signing keys
+  export --dir DIR        write every collection to DIR as extended JSON
+  import --dir DIR        load collections written by export
+
+Run "restaurant_management <command> -h" for a command's flags.`
+
+// runCommand dispatches the subcommand named by args[0] and returns the
+// process exit code.
+func runCommand(args []string) int {
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return 0
+	case "serve", "migrate", "seed", "user", "tokens", "keys", "export", "import":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", command, usage)
+		return 2
+	}
+
+	// The client connects lazily, so make sure the server is there before
+	// a command starts relying on it.
+	if err := database.Ping(context.Background()); err != nil {
+		log.Printf("MongoDB ping failed: %v", err)
+		return 1
+	}
+	fmt.Println("✅ Connected to MongoDB successfully!")
+
+	switch command {
+	case "migrate":
+		return runMigrate(args)
+	case "seed":
+		return runSeed(args)
+	case "user":
+		return runUser(args)
+	case "tokens":
+		return runTokens(args)
+	case "keys":
+		return runKeys(args)
+	case "export":
+		return runExport(args)
+	case "import":
+		return runImport(args)
+	default:
+		return serve()
+	}
+}
@@ -0,0 +1,440 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var aggregatorOrderModel *mongo.Collection = database.OpenCollection(database.MongoClient, "aggregator_order")
+var itemMappingModel *mongo.Collection = database.OpenCollection(database.MongoClient, "item_mapping")
+
+// IngestAggregatorOrder receives an order webhook from a delivery platform
+// and books it as a takeaway or delivery order. Platforms retry webhooks,
+// so each external order id is only ever imported once.
+func IngestAggregatorOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+
+		adapter, err := helpers.GetAggregatorAdapter(c.Param("platform"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown platform"})
+			return
+		}
+
+		payload, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		if err := adapter.VerifySignature(payload, c.Request.Header); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+			return
+		}
+
+		external, err := adapter.ParseOrder(payload)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		record, duplicate, err := claimAggregatorOrder(ctx, adapter.Name(), external.External_Order_Id, payload)
+		if err != nil {
+			log.Printf("Error recording %s order %s: %v", adapter.Name(), external.External_Order_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording order"})
+			return
+		}
+		if duplicate {
+			c.JSON(http.StatusOK, gin.H{
+				"message":        "Order already received",
+				"duplicate":      true,
+				"order_id":       record.Order_Id,
+				"status":         record.Status,
+				"unmapped_items": record.Unmapped_Items,
+			})
+			return
+		}
+
+		mapped, unmapped, err := mapExternalItems(ctx, adapter.Name(), external.Items)
+		if err != nil {
+			failAggregatorOrder(ctx, record, "Error mapping items: "+err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error mapping items"})
+			return
+		}
+		if len(mapped) == 0 {
+			record.Unmapped_Items = unmapped
+			failAggregatorOrder(ctx, record, "None of the items are mapped to our menu")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":          "None of the items are mapped to our menu",
+				"unmapped_items": unmapped,
+			})
+			return
+		}
+
+		order := aggregatorOrderDocument(adapter.Name(), &external)
+		if _, err := insertOrder(ctx, &order); err != nil {
+			log.Printf("Error creating order for %s order %s: %v", adapter.Name(), external.External_Order_Id, err)
+			failAggregatorOrder(ctx, record, "Error creating order")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating order"})
+			return
+		}
+
+		for _, orderItem := range mapped {
+			orderItem.Order_Id = order.Order_Id
+			if err := insertOrderItem(ctx, &orderItem); err != nil {
+				log.Printf("Error adding item to order %s: %v", order.Order_Id, err)
+				failAggregatorOrder(ctx, record, "Error adding items to order "+order.Order_Id)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating order items"})
+				return
+			}
+		}
+
+		status := "IMPORTED"
+		if len(unmapped) > 0 {
+			status = "PARTIAL"
+		}
+
+		_, err = aggregatorOrderModel.UpdateOne(ctx,
+			bson.M{"aggregator_order_id": record.Aggregator_Order_Id},
+			bson.M{"$set": bson.M{
+				"order_id":       order.Order_Id,
+				"status":         status,
+				"unmapped_items": unmapped,
+				"error":          "",
+				"updated_at":     time.Now().UTC(),
+			}},
+		)
+		if err != nil {
+			log.Printf("Error updating %s order record %s: %v", adapter.Name(), record.Aggregator_Order_Id, err)
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":        "Order imported",
+			"order_id":       order.Order_Id,
+			"status":         status,
+			"unmapped_items": unmapped,
+		})
+	}
+}
+
+func GetAggregatorOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page <= 0 {
+			page = 1
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit <= 0 {
+			limit = 20
+		}
+
+		filter := bson.M{}
+		if platform := c.Query("platform"); platform != "" {
+			filter["platform"] = platform
+		}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+
+		opts := options.Find().
+			SetSort(bson.D{{Key: "received_at", Value: -1}}).
+			SetSkip(int64((page - 1) * limit)).
+			SetLimit(int64(limit)).
+			SetProjection(bson.M{"payload": 0})
+
+		cursor, err := aggregatorOrderModel.Find(ctx, filter, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching platform orders"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var records []bson.M
+		if err := cursor.All(ctx, &records); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding platform orders"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"page":  page,
+			"limit": limit,
+			"data":  records,
+		})
+	}
+}
+
+func GetItemMappings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		cursor, err := itemMappingModel.Find(ctx,
+			bson.M{"platform": c.Param("platform")},
+			options.Find().SetSort(bson.D{{Key: "external_item_id", Value: 1}}),
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching item mappings"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var mappings []bson.M
+		if err := cursor.All(ctx, &mappings); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding item mappings"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": mappings})
+	}
+}
+
+// SaveItemMapping maps a platform item id to one of our foods, replacing
+// any earlier mapping for that id.
+func SaveItemMapping() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		platform := c.Param("platform")
+		if _, err := helpers.GetAggregatorAdapter(platform); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown platform"})
+			return
+		}
+
+		var mapping models.ItemMapping
+		if err := c.ShouldBindJSON(&mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		count, err := foodModel.CountDocuments(ctx, bson.M{"food_id": *mapping.Food_Id})
+		if err != nil || count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+			return
+		}
+
+		now := time.Now().UTC()
+		id := primitive.NewObjectID()
+		_, err = itemMappingModel.UpdateOne(ctx,
+			bson.M{"platform": platform, "external_item_id": mapping.External_Item_Id},
+			bson.M{
+				"$set": bson.M{
+					"food_id":    *mapping.Food_Id,
+					"name":       mapping.Name,
+					"updated_at": now,
+				},
+				"$setOnInsert": bson.M{
+					"_id":             id,
+					"item_mapping_id": id.Hex(),
+					"created_at":      now,
+				},
+			},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			log.Printf("Error saving %s item mapping %s: %v", platform, mapping.External_Item_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save item mapping"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Item mapping saved"})
+	}
+}
+
+// claimAggregatorOrder records the external order before anything is
+// created. It reports a duplicate when the order was seen before, unless
+// that earlier attempt failed, in which case this call takes it over.
+func claimAggregatorOrder(ctx context.Context, platform, externalId string, payload []byte) (*models.AggregatorOrder, bool, error) {
+	now := time.Now().UTC()
+	id := primitive.NewObjectID()
+	filter := bson.M{"platform": platform, "external_order_id": externalId}
+
+	result, err := aggregatorOrderModel.UpdateOne(ctx, filter,
+		bson.M{"$setOnInsert": models.AggregatorOrder{
+			ID:                  id,
+			Platform:            platform,
+			External_Order_Id:   externalId,
+			Status:              "RECEIVED",
+			Payload:             string(payload),
+			Received_At:         now,
+			Updated_At:          now,
+			Aggregator_Order_Id: id.Hex(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if result.UpsertedCount == 0 {
+		var record models.AggregatorOrder
+		err := aggregatorOrderModel.FindOneAndUpdate(ctx,
+			bson.M{"platform": platform, "external_order_id": externalId, "status": "FAILED"},
+			bson.M{"$set": bson.M{"status": "RECEIVED", "payload": string(payload), "updated_at": now}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&record)
+		if err == nil {
+			return &record, false, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, false, err
+		}
+
+		if err := aggregatorOrderModel.FindOne(ctx, filter).Decode(&record); err != nil {
+			return nil, false, err
+		}
+		return &record, true, nil
+	}
+
+	var record models.AggregatorOrder
+	if err := aggregatorOrderModel.FindOne(ctx, bson.M{"_id": result.UpsertedID}).Decode(&record); err != nil {
+		return nil, false, err
+	}
+	return &record, false, nil
+}
+
+func failAggregatorOrder(ctx context.Context, record *models.AggregatorOrder, message string) {
+	_, err := aggregatorOrderModel.UpdateOne(ctx,
+		bson.M{"aggregator_order_id": record.Aggregator_Order_Id},
+		bson.M{"$set": bson.M{
+			"status":         "FAILED",
+			"error":          message,
+			"unmapped_items": record.Unmapped_Items,
+			"updated_at":     time.Now().UTC(),
+		}},
+	)
+	if err != nil {
+		log.Printf("Error marking platform order %s as failed: %v", record.Aggregator_Order_Id, err)
+	}
+}
+
+// mapExternalItems turns platform items into order items using the
+// platform's item mappings. Items without a mapping to an existing food are
+// returned as unmapped.
+func mapExternalItems(ctx context.Context, platform string, items []helpers.ExternalOrderItem) ([]models.OrderItem, []models.UnmappedItem, error) {
+	externalIds := make([]string, 0, len(items))
+	for _, item := range items {
+		externalIds = append(externalIds, item.External_Item_Id)
+	}
+
+	cursor, err := itemMappingModel.Find(ctx, bson.M{"platform": platform, "external_item_id": bson.M{"$in": externalIds}})
+	if err != nil {
+		return nil, nil, err
+	}
+	var mappings []models.ItemMapping
+	if err := cursor.All(ctx, &mappings); err != nil {
+		return nil, nil, err
+	}
+
+	foodIds := map[string]string{}
+	var mappedFoodIds []string
+	for _, mapping := range mappings {
+		if mapping.Food_Id != nil {
+			foodIds[mapping.External_Item_Id] = *mapping.Food_Id
+			mappedFoodIds = append(mappedFoodIds, *mapping.Food_Id)
+		}
+	}
+
+	foodCursor, err := foodModel.Find(ctx, bson.M{"food_id": bson.M{"$in": mappedFoodIds}})
+	if err != nil {
+		return nil, nil, err
+	}
+	var foods []models.Food
+	if err := foodCursor.All(ctx, &foods); err != nil {
+		return nil, nil, err
+	}
+	foodsById := map[string]models.Food{}
+	for _, food := range foods {
+		foodsById[food.Food_Id] = food
+	}
+
+	var mapped []models.OrderItem
+	unmapped := []models.UnmappedItem{}
+	for _, item := range items {
+		food, ok := foodsById[foodIds[item.External_Item_Id]]
+		if !ok {
+			unmapped = append(unmapped, models.UnmappedItem{
+				External_Item_Id: item.External_Item_Id,
+				Name:             item.Name,
+				Quantity:         item.Quantity,
+			})
+			continue
+		}
+
+		// The customer paid the platform's price, which may differ from ours.
+		price := item.Unit_Price
+		if price <= 0 && food.Price != nil {
+			price = *food.Price
+		}
+		quantity := strconv.Itoa(item.Quantity)
+		foodId := food.Food_Id
+
+		orderItem := models.OrderItem{
+			Quantity:   &quantity,
+			Unit_Price: &price,
+			Food_Id:    &foodId,
+			Source:     "AGGREGATOR",
+		}
+		if item.Note != "" {
+			note := item.Note
+			orderItem.Note = &note
+		}
+		mapped = append(mapped, orderItem)
+	}
+
+	return mapped, unmapped, nil
+}
+
+// aggregatorOrderDocument builds our order from a platform order. The
+// platform handles delivery, so no zone, fee or minimum applies.
+func aggregatorOrderDocument(platform string, external *helpers.ExternalOrder) models.Order {
+	externalId := external.External_Order_Id
+	order := models.Order{
+		Order_Type:        external.Order_Type,
+		Source:            "AGGREGATOR:" + platform,
+		External_Order_Id: &externalId,
+		Promised_Time:     external.Promised_Time,
+	}
+
+	if external.Customer_Name != "" || external.Customer_Phone != "" {
+		order.Contact = &models.OrderContact{
+			Name:  external.Customer_Name,
+			Phone: external.Customer_Phone,
+			Email: external.Customer_Email,
+		}
+	}
+
+	if external.Order_Type == "DELIVERY" {
+		order.Delivery_Address = &models.DeliveryAddress{
+			Line1:       external.Address_Line1,
+			Line2:       external.Address_Line2,
+			City:        external.City,
+			Postal_Code: external.Postal_Code,
+			Notes:       external.Address_Notes,
+		}
+	}
+
+	return order
+}
@@ -0,0 +1,613 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Catalogue rows are what a spreadsheet sees: foods point at their menu by
+// name, and both are matched to existing documents by name on import.
+type MenuRow struct {
+	Name       string `json:"name"`
+	Category   string `json:"category"`
+	Start_Date string `json:"start_date"`
+	End_Date   string `json:"end_date"`
+}
+
+type FoodRow struct {
+	Name       string   `json:"name"`
+	Price      *float64 `json:"price"`
+	Food_Image string   `json:"food_image"`
+	Menu       string   `json:"menu"`
+}
+
+var menuColumns = []string{"name", "category", "start_date", "end_date"}
+var foodColumns = []string{"name", "price", "food_image", "menu"}
+
+type CatalogRowResult struct {
+	Row    int      `json:"row"`
+	Name   string   `json:"name"`
+	Action string   `json:"action"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type CatalogImportReport struct {
+	Dry_Run bool               `json:"dry_run"`
+	Created int                `json:"created"`
+	Updated int                `json:"updated"`
+	Failed  int                `json:"failed"`
+	Rows    []CatalogRowResult `json:"rows"`
+}
+
+const catalogImportMaxBytes = 5 << 20
+
+var errCatalogFormat = errors.New("unsupported catalogue format, use csv or json")
+
+func ImportMenus() gin.HandlerFunc {
+	return catalogImportHandler(importMenus)
+}
+
+func ImportFoods() gin.HandlerFunc {
+	return catalogImportHandler(importFoods)
+}
+
+func ExportMenus() gin.HandlerFunc {
+	return catalogExportHandler("menus", exportMenus)
+}
+
+func ExportFoods() gin.HandlerFunc {
+	return catalogExportHandler("foods", exportFoods)
+}
+
+// catalogImportHandler validates every row before writing any. With
+// ?dry_run=true, or when a row fails, nothing is written and the per-row
+// report says what would have happened.
+func catalogImportHandler(run func(ctx context.Context, r io.Reader, format string, dryRun bool) (*CatalogImportReport, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+		// An oversized file is refused outright: cutting it off could end
+		// the last row mid-field and still leave it parseable.
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, catalogImportMaxBytes)
+
+		report, err := run(ctx, body, catalogFormat(c), dryRun)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Import files can be at most %d bytes", catalogImportMaxBytes)})
+				return
+			}
+			if errors.Is(err, errCatalogFormat) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if report == nil {
+				log.Printf("Error importing catalogue: %v", err)
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read import file", "details": err.Error()})
+				return
+			}
+			log.Printf("Error importing catalogue: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Import stopped part way", "details": err.Error(), "report": report})
+			return
+		}
+
+		if report.Failed > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Some rows are invalid, nothing was imported", "report": report})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"report": report})
+	}
+}
+
+func catalogExportHandler(name string, run func(ctx context.Context, w io.Writer, format string) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		format := c.DefaultQuery("format", "json")
+		switch format {
+		case "csv":
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+		case "json":
+			c.Header("Content-Type", "application/json; charset=utf-8")
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": errCatalogFormat.Error()})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", name, format))
+
+		if err := run(ctx, c.Writer, format); err != nil {
+			log.Printf("Error exporting %s: %v", name, err)
+			if !c.Writer.Written() {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error exporting " + name})
+			}
+		}
+	}
+}
+
+// catalogFormat takes ?format= first and falls back to the content type.
+func catalogFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+	if strings.Contains(c.ContentType(), "csv") {
+		return "csv"
+	}
+	return "json"
+}
+
+func importMenus(ctx context.Context, r io.Reader, format string, dryRun bool) (*CatalogImportReport, error) {
+	var rows []MenuRow
+	if err := decodeCatalogRows(r, format, &rows, menuColumns, func(record map[string]string) error {
+		rows = append(rows, MenuRow{
+			Name:       record["name"],
+			Category:   record["category"],
+			Start_Date: record["start_date"],
+			End_Date:   record["end_date"],
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return ImportMenuRows(ctx, rows, dryRun)
+}
+
+// ImportMenuRows upserts menus by name once every row is valid.
+func ImportMenuRows(ctx context.Context, rows []MenuRow, dryRun bool) (*CatalogImportReport, error) {
+	existing, err := idsByName(ctx, menuModel, "menu_id")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CatalogImportReport{Dry_Run: dryRun}
+	menus := make([]models.Menu, len(rows))
+	seen := map[string]int{}
+
+	for i, row := range rows {
+		result := CatalogRowResult{Row: i + 1, Name: row.Name, Action: "create"}
+		name := strings.TrimSpace(row.Name)
+		category := strings.TrimSpace(row.Category)
+
+		menu := models.Menu{Name: &name}
+		if category != "" {
+			menu.Category = &category
+		}
+
+		var dateErr error
+		if menu.Start_Date, dateErr = parseCatalogDate(row.Start_Date); dateErr != nil {
+			result.Errors = append(result.Errors, "start_date: "+dateErr.Error())
+		}
+		if menu.End_Date, dateErr = parseCatalogDate(row.End_Date); dateErr != nil {
+			result.Errors = append(result.Errors, "end_date: "+dateErr.Error())
+		}
+		if !menu.Start_Date.IsZero() && !menu.End_Date.IsZero() && menu.End_Date.Before(menu.Start_Date) {
+			result.Errors = append(result.Errors, "end_date is before start_date")
+		}
+
+		result.Errors = append(result.Errors, validationMessages(validate.Struct(menu))...)
+		result.Errors = append(result.Errors, duplicateRow(seen, name, i)...)
+
+		if id, ok := existing[name]; ok {
+			result.Action = "update"
+			menu.Menu_Id = id
+		}
+
+		menus[i] = menu
+		report.add(result)
+	}
+
+	if dryRun || report.Failed > 0 {
+		return report, nil
+	}
+
+	for i, menu := range menus {
+		now := time.Now().UTC()
+		if menu.Menu_Id != "" {
+			set := bson.M{
+				"name":       menu.Name,
+				"category":   menu.Category,
+				"start_date": menu.Start_Date,
+				"end_date":   menu.End_Date,
+				"updated_at": now,
+			}
+			if _, err := menuModel.UpdateOne(ctx, bson.M{"menu_id": menu.Menu_Id}, bson.M{"$set": set}); err != nil {
+				return report, fmt.Errorf("row %d: %w", i+1, err)
+			}
+			continue
+		}
+
+		menu.ID = primitive.NewObjectID()
+		menu.Menu_Id = menu.ID.Hex()
+		menu.Created_At = now
+		menu.Updated_At = now
+		if _, err := menuModel.InsertOne(ctx, menu); err != nil {
+			return report, fmt.Errorf("row %d: %w", i+1, err)
+		}
+	}
+
+	return report, nil
+}
+
+func importFoods(ctx context.Context, r io.Reader, format string, dryRun bool) (*CatalogImportReport, error) {
+	var rows []FoodRow
+	priceErrors := map[int]string{}
+	if err := decodeCatalogRows(r, format, &rows, foodColumns, func(record map[string]string) error {
+		row := FoodRow{
+			Name:       record["name"],
+			Food_Image: record["food_image"],
+			Menu:       record["menu"],
+		}
+		if value := strings.TrimSpace(record["price"]); value != "" {
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				priceErrors[len(rows)] = "price: not a number"
+			} else {
+				row.Price = &price
+			}
+		}
+		rows = append(rows, row)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return importFoodRows(ctx, rows, priceErrors, dryRun)
+}
+
+// ImportFoodRows upserts foods by name once every row is valid. Menus are
+// referenced by name and must already exist.
+func ImportFoodRows(ctx context.Context, rows []FoodRow, dryRun bool) (*CatalogImportReport, error) {
+	return importFoodRows(ctx, rows, nil, dryRun)
+}
+
+// importFoodRows also reports prices that failed to parse from CSV, keyed
+// by row index.
+func importFoodRows(ctx context.Context, rows []FoodRow, priceErrors map[int]string, dryRun bool) (*CatalogImportReport, error) {
+	menus, err := idsByName(ctx, menuModel, "menu_id")
+	if err != nil {
+		return nil, err
+	}
+	existing, err := idsByName(ctx, foodModel, "food_id")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CatalogImportReport{Dry_Run: dryRun}
+	foods := make([]models.Food, len(rows))
+	seen := map[string]int{}
+
+	for i, row := range rows {
+		result := CatalogRowResult{Row: i + 1, Name: row.Name, Action: "create"}
+		name := strings.TrimSpace(row.Name)
+		image := strings.TrimSpace(row.Food_Image)
+
+		food := models.Food{Name: &name, Food_Image: &image}
+		if image == "" {
+			food.Food_Image = nil
+		}
+		if msg, ok := priceErrors[i]; ok {
+			result.Errors = append(result.Errors, msg)
+		} else if row.Price != nil {
+			if *row.Price < 0 {
+				result.Errors = append(result.Errors, "price: cannot be negative")
+			}
+			price := toFixed(*row.Price, 2)
+			food.Price = &price
+		}
+
+		if menuName := strings.TrimSpace(row.Menu); menuName != "" {
+			if menuId, ok := menus[menuName]; ok {
+				food.Menu_Id = &menuId
+			} else {
+				result.Errors = append(result.Errors, fmt.Sprintf("menu: no menu named %q", menuName))
+			}
+		}
+
+		result.Errors = append(result.Errors, validationMessages(validate.Struct(food))...)
+		result.Errors = append(result.Errors, duplicateRow(seen, name, i)...)
+
+		if id, ok := existing[name]; ok {
+			result.Action = "update"
+			food.Food_Id = id
+		}
+
+		foods[i] = food
+		report.add(result)
+	}
+
+	if dryRun || report.Failed > 0 {
+		return report, nil
+	}
+
+	for i, food := range foods {
+		now := time.Now().UTC()
+		if food.Food_Id != "" {
+			set := bson.M{
+				"name":       food.Name,
+				"price":      food.Price,
+				"food_image": food.Food_Image,
+				"menu_id":    food.Menu_Id,
+				"updated_at": now,
+			}
+			if _, err := foodModel.UpdateOne(ctx, bson.M{"food_id": food.Food_Id}, bson.M{"$set": set}); err != nil {
+				return report, fmt.Errorf("row %d: %w", i+1, err)
+			}
+			continue
+		}
+
+		food.ID = primitive.NewObjectID()
+		food.Food_Id = food.ID.Hex()
+		food.Created_At = now
+		food.Updated_At = now
+		if _, err := foodModel.InsertOne(ctx, food); err != nil {
+			return report, fmt.Errorf("row %d: %w", i+1, err)
+		}
+	}
+
+	return report, nil
+}
+
+func exportMenus(ctx context.Context, w io.Writer, format string) error {
+	cursor, err := menuModel.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	var menus []models.Menu
+	if err := cursor.All(ctx, &menus); err != nil {
+		return err
+	}
+
+	rows := make([]MenuRow, 0, len(menus))
+	for _, menu := range menus {
+		rows = append(rows, MenuRow{
+			Name:       derefString(menu.Name),
+			Category:   derefString(menu.Category),
+			Start_Date: formatCatalogDate(menu.Start_Date),
+			End_Date:   formatCatalogDate(menu.End_Date),
+		})
+	}
+
+	return encodeCatalogRows(w, format, rows, len(rows), menuColumns, func(i int) []string {
+		row := rows[i]
+		return []string{row.Name, row.Category, row.Start_Date, row.End_Date}
+	})
+}
+
+func exportFoods(ctx context.Context, w io.Writer, format string) error {
+	menuIds, err := idsByName(ctx, menuModel, "menu_id")
+	if err != nil {
+		return err
+	}
+	menuNames := map[string]string{}
+	for name, id := range menuIds {
+		menuNames[id] = name
+	}
+
+	cursor, err := foodModel.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	var foods []models.Food
+	if err := cursor.All(ctx, &foods); err != nil {
+		return err
+	}
+
+	rows := make([]FoodRow, 0, len(foods))
+	for _, food := range foods {
+		row := FoodRow{
+			Name:       derefString(food.Name),
+			Price:      food.Price,
+			Food_Image: derefString(food.Food_Image),
+		}
+		if food.Menu_Id != nil {
+			row.Menu = menuNames[*food.Menu_Id]
+		}
+		rows = append(rows, row)
+	}
+
+	return encodeCatalogRows(w, format, rows, len(rows), foodColumns, func(i int) []string {
+		row := rows[i]
+		price := ""
+		if row.Price != nil {
+			price = strconv.FormatFloat(*row.Price, 'f', 2, 64)
+		}
+		return []string{row.Name, price, row.Food_Image, row.Menu}
+	})
+}
+
+// decodeCatalogRows reads a JSON array straight into rows, or a CSV file
+// whose header names the columns, in any order, handing each record to
+// addRecord.
+func decodeCatalogRows(r io.Reader, format string, rows interface{}, columns []string, addRecord func(record map[string]string) error) error {
+	switch format {
+	case "json":
+		return json.NewDecoder(r).Decode(rows)
+	case "csv":
+	default:
+		return errCatalogFormat
+	}
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("reading header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, column := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))] = i
+	}
+	if _, ok := index["name"]; !ok {
+		return errors.New("header must include a name column")
+	}
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		record := map[string]string{}
+		for _, column := range columns {
+			if i, ok := index[column]; ok && i < len(fields) {
+				record[column] = fields[i]
+			}
+		}
+		if err := addRecord(record); err != nil {
+			return err
+		}
+	}
+}
+
+func encodeCatalogRows(w io.Writer, format string, rows interface{}, count int, columns []string, fields func(i int) []string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	case "csv":
+	default:
+		return errCatalogFormat
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+		if err := writer.Write(fields(i)); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (r *CatalogImportReport) add(result CatalogRowResult) {
+	switch {
+	case len(result.Errors) > 0:
+		result.Action = "error"
+		r.Failed++
+	case result.Action == "update":
+		r.Updated++
+	default:
+		r.Created++
+	}
+	r.Rows = append(r.Rows, result)
+}
+
+// idsByName maps each document's name to its id field.
+func idsByName(ctx context.Context, collection *mongo.Collection, idField string) (map[string]string, error) {
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"name": 1, idField: 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []bson.M
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	ids := map[string]string{}
+	for _, doc := range docs {
+		name, _ := doc["name"].(string)
+		id, _ := doc[idField].(string)
+		if name != "" && id != "" {
+			ids[name] = id
+		}
+	}
+	return ids, nil
+}
+
+func duplicateRow(seen map[string]int, name string, i int) []string {
+	if name == "" {
+		return nil
+	}
+	if first, ok := seen[name]; ok {
+		return []string{fmt.Sprintf("name: duplicates row %d", first+1)}
+	}
+	seen[name] = i
+	return nil
+}
+
+// validationMessages flattens validator errors into one message per field.
+func validationMessages(err error) []string {
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return []string{err.Error()}
+	}
+
+	messages := make([]string, 0, len(fieldErrors))
+	for _, fieldError := range fieldErrors {
+		message := strings.ToLower(fieldError.Field()) + ": failed " + fieldError.Tag()
+		if fieldError.Param() != "" {
+			message += "=" + fieldError.Param()
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// parseCatalogDate accepts a plain date or a full RFC 3339 timestamp.
+func parseCatalogDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("use YYYY-MM-DD or an RFC 3339 timestamp")
+	}
+	return t, nil
+}
+
+func formatCatalogDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var couponModel *mongo.Collection = database.OpenCollection(database.MongoClient, "coupon")
+
+var (
+	errCouponUnavailable   = errors.New("coupon is invalid, expired or fully redeemed")
+	errCouponNotApplicable = errors.New("coupon does not apply to anything on this order")
+)
+
+func GetCoupons() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if promotionId := c.Query("promotion_id"); promotionId != "" {
+			filter["promotion_id"] = promotionId
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+		cursor, err := couponModel.Find(ctx, filter, opts)
+		if err != nil {
+			log.Printf("Error fetching coupons: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching coupons"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var coupons []bson.M
+		if err := cursor.All(ctx, &coupons); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding coupons"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": coupons})
+	}
+}
+
+func GetCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		code := normalizeCouponCode(c.Param("code"))
+
+		var coupon models.Coupon
+		err := couponModel.FindOne(ctx, bson.M{"code": code}).Decode(&coupon)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+			} else {
+				log.Printf("Error fetching coupon (code=%s): %v", code, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching coupon"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":   coupon,
+			"usable": couponUsable(&coupon, time.Now()),
+		})
+	}
+}
+
+func CreateCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var coupon models.Coupon
+		if err := c.ShouldBindJSON(&coupon); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(coupon); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		var promotion models.Promotion
+		if err := promotionModel.FindOne(ctx, bson.M{"promotion_id": *coupon.Promotion_Id}).Decode(&promotion); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+
+		code := normalizeCouponCode(*coupon.Code)
+		count, err := couponModel.CountDocuments(ctx, bson.M{"code": code})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking coupon code"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Coupon code already exists"})
+			return
+		}
+
+		now := time.Now().UTC()
+		coupon.ID = primitive.NewObjectID()
+		coupon.Coupon_Id = coupon.ID.Hex()
+		coupon.Code = &code
+		coupon.Usage_Count = 0
+		coupon.Created_At = now
+		coupon.Updated_At = now
+
+		if _, err := couponModel.InsertOne(ctx, coupon); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Coupon code already exists"})
+				return
+			}
+			log.Printf("Error inserting coupon: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create coupon"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Coupon created successfully",
+			"data":    coupon,
+		})
+	}
+}
+
+func UpdateCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		code := normalizeCouponCode(c.Param("code"))
+
+		var coupon models.Coupon
+		if err := c.ShouldBindJSON(&coupon); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		updateObj := bson.D{}
+		if coupon.Usage_Limit != nil {
+			updateObj = append(updateObj, bson.E{Key: "usage_limit", Value: *coupon.Usage_Limit})
+		}
+		if !coupon.Expires_At.IsZero() {
+			updateObj = append(updateObj, bson.E{Key: "expires_at", Value: coupon.Expires_At})
+		}
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: time.Now().UTC()})
+
+		result, err := couponModel.UpdateOne(ctx, bson.M{"code": code}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			log.Printf("Error updating coupon (code=%s): %v", code, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update coupon"})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Coupon updated successfully"})
+	}
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func couponUsable(coupon *models.Coupon, now time.Time) bool {
+	if !coupon.Expires_At.IsZero() && now.After(coupon.Expires_At) {
+		return false
+	}
+	if coupon.Usage_Limit != nil && coupon.Usage_Count >= *coupon.Usage_Limit {
+		return false
+	}
+	return true
+}
+
+// findUsableCoupon looks up a coupon by code without redeeming it.
+func findUsableCoupon(ctx context.Context, code string) (*models.Coupon, error) {
+	var coupon models.Coupon
+	err := couponModel.FindOne(ctx, bson.M{"code": normalizeCouponCode(code)}).Decode(&coupon)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errCouponUnavailable
+		}
+		return nil, err
+	}
+
+	if !couponUsable(&coupon, time.Now()) {
+		return nil, errCouponUnavailable
+	}
+
+	return &coupon, nil
+}
+
+// releaseCoupon gives back a use taken by a checkout that failed without a
+// transaction to roll it back.
+func releaseCoupon(ctx context.Context, coupon *models.Coupon) {
+	_, err := couponModel.UpdateOne(ctx,
+		bson.M{"coupon_id": coupon.Coupon_Id, "usage_count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"usage_count": -1}, "$set": bson.M{"updated_at": time.Now().UTC()}},
+	)
+	if err != nil {
+		log.Printf("Error releasing coupon %s: %v", coupon.Coupon_Id, err)
+	}
+}
+
+// redeemCoupon counts one use. The usage limit is part of the filter so two
+// concurrent checkouts can't both take the last use.
+func redeemCoupon(ctx context.Context, coupon *models.Coupon) error {
+	filter := bson.M{"coupon_id": coupon.Coupon_Id}
+	if coupon.Usage_Limit != nil {
+		filter["usage_count"] = bson.M{"$lt": *coupon.Usage_Limit}
+	}
+
+	result, err := couponModel.UpdateOne(ctx, filter, bson.M{
+		"$inc": bson.M{"usage_count": 1},
+		"$set": bson.M{"updated_at": time.Now().UTC()},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errCouponUnavailable
+	}
+	return nil
+}
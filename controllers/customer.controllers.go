@@ -0,0 +1,446 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var customerModel *mongo.Collection = database.OpenCollection(database.MongoClient, "customer")
+
+var (
+	errNotEnoughPoints = errors.New("not enough loyalty points")
+	errPointsExceedDue = errors.New("points exceed the amount due")
+)
+
+// Loyalty settings are read from the environment on use, since .env is only
+// loaded once main runs: LOYALTY_POINTS_PER_UNIT points are earned per unit of
+// currency paid, LOYALTY_POINT_VALUE is what one point is worth when
+// redeemed, and guests with LOYALTY_REGULAR_VISITS visits count as regulars.
+func loyaltyPointsPerUnit() float64 { return helpers.EnvFloat("LOYALTY_POINTS_PER_UNIT", 1) }
+func loyaltyPointValue() float64    { return helpers.EnvFloat("LOYALTY_POINT_VALUE", 0.01) }
+func loyaltyRegularVisits() int     { return helpers.EnvInt("LOYALTY_REGULAR_VISITS", 5) }
+
+func GetCustomers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil || limit < 1 {
+			limit = 10
+		}
+
+		filter := bson.M{}
+		if search := c.Query("search"); search != "" {
+			pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+			filter["$or"] = []bson.M{
+				{"first_name": pattern},
+				{"last_name": pattern},
+				{"phone": pattern},
+				{"email": pattern},
+			}
+		}
+
+		opts := options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}}).
+			SetSkip(int64((page - 1) * limit)).
+			SetLimit(int64(limit))
+
+		cursor, err := customerModel.Find(ctx, filter, opts)
+		if err != nil {
+			log.Printf("Error fetching customers: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching customers"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var customers []bson.M
+		if err := cursor.All(ctx, &customers); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding customers"})
+			return
+		}
+
+		total, _ := customerModel.CountDocuments(ctx, filter)
+
+		c.JSON(http.StatusOK, gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"data":  customers,
+		})
+	}
+}
+
+func GetCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		customerId := c.Param("customer_id")
+
+		var customer models.Customer
+		err := customerModel.FindOne(ctx, bson.M{"customer_id": customerId}).Decode(&customer)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			} else {
+				log.Printf("Error fetching customer (id=%s): %v", customerId, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching customer"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": customer})
+	}
+}
+
+// LookupCustomer is used at the host stand to recognise a guest by phone
+// number or email.
+func LookupCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if phone := c.Query("phone"); phone != "" {
+			filter["phone"] = phone
+		} else if email := c.Query("email"); email != "" {
+			filter["email"] = email
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "phone or email query parameter is required"})
+			return
+		}
+
+		var customer models.Customer
+		if err := customerModel.FindOne(ctx, filter).Decode(&customer); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":    customer,
+			"regular": customer.Visit_Count >= loyaltyRegularVisits(),
+		})
+	}
+}
+
+func CreateCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var customer models.Customer
+		if err := c.ShouldBindJSON(&customer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(customer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		count, err := customerModel.CountDocuments(ctx, bson.M{"phone": customer.Phone})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking customer existence"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Phone already registered"})
+			return
+		}
+
+		now := time.Now().UTC()
+		customer.ID = primitive.NewObjectID()
+		customer.Customer_Id = customer.ID.Hex()
+		customer.Loyalty_Points = 0
+		customer.Visit_Count = 0
+		customer.Created_At = now
+		customer.Updated_At = now
+
+		if _, err := customerModel.InsertOne(ctx, customer); err != nil {
+			log.Printf("Error inserting customer: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create customer"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Customer created successfully",
+			"data":    customer,
+		})
+	}
+}
+
+func UpdateCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		customerId := c.Param("customer_id")
+
+		var customer models.Customer
+		if err := c.ShouldBindJSON(&customer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		updateObj := bson.D{}
+		if customer.First_Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "first_name", Value: *customer.First_Name})
+		}
+		if customer.Last_Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "last_name", Value: *customer.Last_Name})
+		}
+		if customer.Phone != nil {
+			updateObj = append(updateObj, bson.E{Key: "phone", Value: *customer.Phone})
+		}
+		if customer.Email != nil {
+			updateObj = append(updateObj, bson.E{Key: "email", Value: *customer.Email})
+		}
+		if customer.Preferences != nil {
+			updateObj = append(updateObj, bson.E{Key: "preferences", Value: customer.Preferences})
+		}
+		if customer.Allergies != nil {
+			updateObj = append(updateObj, bson.E{Key: "allergies", Value: customer.Allergies})
+		}
+		if customer.Notes != nil {
+			updateObj = append(updateObj, bson.E{Key: "notes", Value: *customer.Notes})
+		}
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: time.Now().UTC()})
+
+		result, err := customerModel.UpdateOne(
+			ctx,
+			bson.M{"customer_id": customerId},
+			bson.D{{Key: "$set", Value: updateObj}},
+		)
+		if err != nil {
+			log.Printf("Error updating customer (id=%s): %v", customerId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update customer"})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Customer updated successfully"})
+	}
+}
+
+// GetCustomerVisits lists the guest's orders and invoices, newest first.
+func GetCustomerVisits() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		customerId := c.Param("customer_id")
+		filter := bson.M{"customer_id": customerId}
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(50)
+
+		var orders, invoices []bson.M
+
+		cursor, err := orderModel.Find(ctx, filter, opts)
+		if err == nil {
+			err = cursor.All(ctx, &orders)
+		}
+		if err != nil {
+			log.Printf("Error fetching orders for customer %s: %v", customerId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching visit history"})
+			return
+		}
+
+		cursor, err = invoiceModel.Find(ctx, filter, opts)
+		if err == nil {
+			err = cursor.All(ctx, &invoices)
+		}
+		if err != nil {
+			log.Printf("Error fetching invoices for customer %s: %v", customerId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching visit history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"customer_id": customerId,
+			"orders":      orders,
+			"invoices":    invoices,
+		})
+	}
+}
+
+// RedeemLoyaltyPoints tenders loyalty points against an unpaid invoice.
+func RedeemLoyaltyPoints() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+
+		var body struct {
+			Points int `json:"points" validate:"required,gte=1"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		var invoice models.Invoice
+		if err := invoiceModel.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+		if invoice.Customer_Id == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invoice has no customer attached"})
+			return
+		}
+		if invoice.Payment_Status != nil && *invoice.Payment_Status == "PAID" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invoice is already paid"})
+			return
+		}
+
+		value := toFixed(float64(body.Points)*loyaltyPointValue(), 2)
+		if value > invoice.Amount_Due {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Points exceed the amount due"})
+			return
+		}
+
+		// The points and the amount due move together. The invoice update
+		// re-checks the amount due so concurrent redemptions can't both fit.
+		now := time.Now().UTC()
+		customerId := *invoice.Customer_Id
+		pointsTaken := false
+		transactional, err := database.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+			result, err := customerModel.UpdateOne(sc,
+				bson.M{"customer_id": customerId, "loyalty_points": bson.M{"$gte": body.Points}},
+				bson.M{
+					"$inc": bson.M{"loyalty_points": -body.Points},
+					"$set": bson.M{"updated_at": now},
+				},
+			)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return errNotEnoughPoints
+			}
+			pointsTaken = true
+
+			result, err = invoiceModel.UpdateOne(sc,
+				bson.M{
+					"invoice_id":     invoiceId,
+					"payment_status": bson.M{"$nin": bson.A{"PAID", "REFUNDED"}},
+					// Points can't take the amount due below what card
+					// payments already hold.
+					"$expr": bson.M{"$gte": bson.A{
+						bson.M{"$subtract": bson.A{"$amount_due", value}},
+						bson.M{"$ifNull": bson.A{"$card_reserved", 0}},
+					}},
+				},
+				bson.M{
+					"$inc": bson.M{
+						"points_redeemed": body.Points,
+						"points_value":    value,
+						"amount_due":      -value,
+					},
+					"$set": bson.M{"updated_at": now},
+				},
+			)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return errPointsExceedDue
+			}
+			return nil
+		})
+		if err != nil {
+			if !transactional && pointsTaken {
+				refundLoyaltyPoints(ctx, customerId, body.Points)
+			}
+			switch {
+			case errors.Is(err, errNotEnoughPoints):
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Not enough loyalty points"})
+			case errors.Is(err, errPointsExceedDue):
+				c.JSON(http.StatusConflict, gin.H{"error": "Points exceed the amount due or the invoice is already paid"})
+			default:
+				log.Printf("Error redeeming points on invoice %s: %v", invoiceId, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem points"})
+			}
+			return
+		}
+
+		var updated models.Invoice
+		invoiceModel.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&updated)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Loyalty points redeemed successfully",
+			"points":     body.Points,
+			"value":      value,
+			"amount_due": updated.Amount_Due,
+		})
+	}
+}
+
+// refundLoyaltyPoints gives back points debited for a redemption that
+// could not be applied to its invoice.
+func refundLoyaltyPoints(ctx context.Context, customerId string, points int) {
+	_, err := customerModel.UpdateOne(ctx,
+		bson.M{"customer_id": customerId},
+		bson.M{"$inc": bson.M{"loyalty_points": points}, "$set": bson.M{"updated_at": time.Now().UTC()}},
+	)
+	if err != nil {
+		log.Printf("Error returning %d points to customer %s: %v", points, customerId, err)
+	}
+}
+
+// awardLoyaltyPoints credits the customer once the invoice is paid. The
+// points_awarded flag makes repeated PAID updates a no-op.
+func awardLoyaltyPoints(ctx context.Context, invoiceId string) {
+	var invoice models.Invoice
+	err := invoiceModel.FindOne(ctx, bson.M{"invoice_id": invoiceId, "payment_status": "PAID"}).Decode(&invoice)
+	if err != nil || invoice.Customer_Id == nil || invoice.Points_Awarded {
+		return
+	}
+
+	points := int(math.Floor((invoice.Total_Amount - invoice.Points_Value) * loyaltyPointsPerUnit()))
+
+	result, err := invoiceModel.UpdateOne(ctx,
+		bson.M{"invoice_id": invoiceId, "points_awarded": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"points_awarded": true, "points_earned": points}},
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	_, err = customerModel.UpdateOne(ctx, bson.M{"customer_id": *invoice.Customer_Id}, bson.M{
+		"$inc": bson.M{"loyalty_points": points, "visit_count": 1},
+		"$set": bson.M{"last_visit": now, "updated_at": now},
+	})
+	if err != nil {
+		log.Printf("Error awarding loyalty points for invoice %s: %v", invoiceId, err)
+	}
+}
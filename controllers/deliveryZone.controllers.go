@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var deliveryZoneModel *mongo.Collection = database.OpenCollection(database.MongoClient, "delivery_zone")
+
+var errNoDeliveryZone = errors.New("address is outside our delivery area")
+
+func GetDeliveryZones() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if postalCode := c.Query("postal_code"); postalCode != "" {
+			filter["postal_codes"] = normalizePostalCode(postalCode)
+		}
+
+		cursor, err := deliveryZoneModel.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		if err != nil {
+			log.Printf("Error fetching delivery zones: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching delivery zones"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var zones []bson.M
+		if err := cursor.All(ctx, &zones); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding delivery zones"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": zones})
+	}
+}
+
+func GetDeliveryZone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var zone models.DeliveryZone
+		err := deliveryZoneModel.FindOne(ctx, bson.M{"delivery_zone_id": c.Param("delivery_zone_id")}).Decode(&zone)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Delivery zone not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching delivery zone"})
+			return
+		}
+
+		c.JSON(http.StatusOK, zone)
+	}
+}
+
+func CreateDeliveryZone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var zone models.DeliveryZone
+		if err := c.ShouldBindJSON(&zone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(zone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		zone.Postal_Codes = normalizePostalCodes(zone.Postal_Codes)
+		if conflict, err := postalCodeConflict(ctx, zone.Postal_Codes, ""); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking postal codes"})
+			return
+		} else if conflict != "" {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Postal code %s already belongs to another zone", conflict)})
+			return
+		}
+
+		if zone.Active == nil {
+			active := true
+			zone.Active = &active
+		}
+
+		now := time.Now().UTC()
+		zone.ID = primitive.NewObjectID()
+		zone.Delivery_Zone_Id = zone.ID.Hex()
+		zone.Created_At = now
+		zone.Updated_At = now
+
+		if _, err := deliveryZoneModel.InsertOne(ctx, zone); err != nil {
+			log.Printf("Error inserting delivery zone: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create delivery zone"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Delivery zone created successfully",
+			"data":    zone,
+		})
+	}
+}
+
+func UpdateDeliveryZone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		zoneId := c.Param("delivery_zone_id")
+
+		var zone models.DeliveryZone
+		if err := c.ShouldBindJSON(&zone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		updateObj := bson.D{}
+		if zone.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: *zone.Name})
+		}
+		if zone.Postal_Codes != nil {
+			postalCodes := normalizePostalCodes(zone.Postal_Codes)
+			if conflict, err := postalCodeConflict(ctx, postalCodes, zoneId); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking postal codes"})
+				return
+			} else if conflict != "" {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Postal code %s already belongs to another zone", conflict)})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "postal_codes", Value: postalCodes})
+		}
+		if zone.Delivery_Fee != nil {
+			if *zone.Delivery_Fee < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "delivery_fee cannot be negative"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "delivery_fee", Value: *zone.Delivery_Fee})
+		}
+		if zone.Minimum_Order != nil {
+			if *zone.Minimum_Order < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "minimum_order cannot be negative"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "minimum_order", Value: *zone.Minimum_Order})
+		}
+		if zone.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: *zone.Active})
+		}
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: time.Now().UTC()})
+
+		result, err := deliveryZoneModel.UpdateOne(ctx, bson.M{"delivery_zone_id": zoneId}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			log.Printf("Error updating delivery zone (id=%s): %v", zoneId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update delivery zone"})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery zone not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Delivery zone updated successfully"})
+	}
+}
+
+func normalizePostalCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+func normalizePostalCodes(codes []string) []string {
+	normalized := make([]string, 0, len(codes))
+	for _, code := range codes {
+		if code = normalizePostalCode(code); code != "" {
+			normalized = append(normalized, code)
+		}
+	}
+	return normalized
+}
+
+// postalCodeConflict returns the first of codes already served by a zone
+// other than exceptZoneId, so every address resolves to a single zone.
+func postalCodeConflict(ctx context.Context, codes []string, exceptZoneId string) (string, error) {
+	filter := bson.M{"postal_codes": bson.M{"$in": codes}}
+	if exceptZoneId != "" {
+		filter["delivery_zone_id"] = bson.M{"$ne": exceptZoneId}
+	}
+
+	var existing models.DeliveryZone
+	err := deliveryZoneModel.FindOne(ctx, filter).Decode(&existing)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	for _, code := range codes {
+		for _, taken := range existing.Postal_Codes {
+			if code == taken {
+				return code, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// findDeliveryZone resolves the active zone serving the address's postal
+// code. When the caller names a zone, it must be that one.
+func findDeliveryZone(ctx context.Context, zoneId *string, address *models.DeliveryAddress) (*models.DeliveryZone, error) {
+	filter := bson.M{"active": true}
+	if zoneId != nil && *zoneId != "" {
+		filter["delivery_zone_id"] = *zoneId
+	}
+	if address != nil {
+		filter["postal_codes"] = normalizePostalCode(address.Postal_Code)
+	}
+
+	var zone models.DeliveryZone
+	if err := deliveryZoneModel.FindOne(ctx, filter).Decode(&zone); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errNoDeliveryZone
+		}
+		return nil, err
+	}
+	return &zone, nil
+}
+
+// belowMinimumOrderError stops a delivery order from being invoiced while
+// its food total is under the zone's minimum.
+type belowMinimumOrderError struct {
+	Minimum float64
+}
+
+func (e *belowMinimumOrderError) Error() string {
+	return fmt.Sprintf("delivery orders must total at least %.2f", e.Minimum)
+}
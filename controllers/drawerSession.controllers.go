@@ -0,0 +1,521 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var drawerSessionModel *mongo.Collection = database.OpenCollection(database.MongoClient, "drawer_session")
+
+var (
+	errDrawerClosed = errors.New("cash drawer session is closed")
+	errInvoicePaid  = errors.New("invoice is already paid or refunded")
+)
+
+// OpenDrawerSession starts the caller's cash drawer with its opening float.
+// A cashier has at most one open drawer.
+func OpenDrawerSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var body struct {
+			Opening_Float *float64 `json:"opening_float" validate:"required,gte=0"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		now := time.Now().UTC()
+		session := models.DrawerSession{
+			ID:            primitive.NewObjectID(),
+			Cashier_Id:    c.GetString("uid"),
+			Status:        "OPEN",
+			Opening_Float: toFixed(*body.Opening_Float, 2),
+			Movements:     []models.DrawerMovement{},
+			Opened_At:     now,
+			Updated_At:    now,
+		}
+		session.Drawer_Session_Id = session.ID.Hex()
+		if terminalId := c.GetString("terminal_id"); terminalId != "" {
+			session.Terminal_Id = &terminalId
+		}
+
+		if _, err := drawerSessionModel.InsertOne(ctx, session); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "A cash drawer is already open for this cashier or terminal"})
+				return
+			}
+			log.Printf("Error opening drawer session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open cash drawer"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Cash drawer opened successfully",
+			"data":    session,
+		})
+	}
+}
+
+func GetCurrentDrawerSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		session, err := openDrawerSession(ctx, c.GetString("uid"))
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "No cash drawer is open"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching cash drawer"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": session, "expected_total": toFixed(session.Expected(), 2)})
+	}
+}
+
+func GetDrawerSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		from, to, err := parsePeriod(c.Query("from"), c.Query("to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter := bson.M{"opened_at": bson.M{"$gte": from, "$lt": to}}
+		if cashierId := c.Query("cashier_id"); cashierId != "" {
+			filter["cashier_id"] = cashierId
+		}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = strings.ToUpper(status)
+		}
+
+		opts := options.Find().
+			SetSort(bson.D{{Key: "opened_at", Value: -1}}).
+			SetProjection(bson.M{"movements": 0})
+		cursor, err := drawerSessionModel.Find(ctx, filter, opts)
+		if err != nil {
+			log.Printf("Error fetching drawer sessions: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching cash drawers"})
+			return
+		}
+
+		sessions := []models.DrawerSession{}
+		if err := cursor.All(ctx, &sessions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding cash drawers"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "data": sessions})
+	}
+}
+
+func GetDrawerSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		session, ok := loadDrawerSession(ctx, c)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": session, "expected_total": toFixed(session.Expected(), 2)})
+	}
+}
+
+// RecordDrawerMovement pays cash into or out of an open drawer, e.g. extra
+// change from the safe or a supplier paid at the door. A reason is always
+// required.
+func RecordDrawerMovement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var body struct {
+			Type   string  `json:"type" validate:"required,eq=PAY_IN|eq=PAY_OUT"`
+			Amount float64 `json:"amount" validate:"gt=0"`
+			Reason string  `json:"reason" validate:"required,min=3,max=200"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		session, ok := loadDrawerSession(ctx, c)
+		if !ok {
+			return
+		}
+		if session.Status != "OPEN" {
+			c.JSON(http.StatusConflict, gin.H{"error": errDrawerClosed.Error()})
+			return
+		}
+
+		amount := toFixed(body.Amount, 2)
+		total := "pay_ins"
+		if body.Type == "PAY_OUT" {
+			if amount > toFixed(session.Expected(), 2) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Pay-out is more than the cash in the drawer"})
+				return
+			}
+			total = "pay_outs"
+		}
+
+		reason := strings.TrimSpace(body.Reason)
+		movement := models.DrawerMovement{
+			Type:    body.Type,
+			Amount:  amount,
+			Reason:  &reason,
+			User_Id: c.GetString("uid"),
+			At:      time.Now().UTC(),
+		}
+		result, err := drawerSessionModel.UpdateOne(ctx,
+			bson.M{"drawer_session_id": session.Drawer_Session_Id, "status": "OPEN"},
+			bson.M{
+				"$push": bson.M{"movements": movement},
+				"$inc":  bson.M{total: amount},
+				"$set":  bson.M{"updated_at": movement.At},
+			},
+		)
+		if err != nil {
+			log.Printf("Error recording drawer movement (id=%s): %v", session.Drawer_Session_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record cash movement"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": errDrawerClosed.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Cash movement recorded successfully", "data": movement})
+	}
+}
+
+// CloseDrawerSession closes a drawer with the cash counted by denomination
+// and records how far it was over or short of the expected total.
+func CloseDrawerSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var body struct {
+			Counted []models.DenominationCount `json:"counted" validate:"required,dive"`
+			Notes   *string                    `json:"notes" validate:"omitempty,max=500"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		denominations := cashDenominations()
+		var counted float64
+		for _, count := range body.Counted {
+			if !slices.Contains(denominations, toFixed(count.Value, 2)) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%g is not a cash denomination", count.Value), "denominations": denominations})
+				return
+			}
+			counted += toFixed(count.Value, 2) * float64(count.Count)
+		}
+		counted = toFixed(counted, 2)
+
+		session, ok := loadDrawerSession(ctx, c)
+		if !ok {
+			return
+		}
+		if session.Status != "OPEN" {
+			c.JSON(http.StatusConflict, gin.H{"error": errDrawerClosed.Error()})
+			return
+		}
+
+		expected := toFixed(session.Expected(), 2)
+		overShort := toFixed(counted-expected, 2)
+		now := time.Now().UTC()
+		closedBy := c.GetString("uid")
+
+		// Matching on updated_at makes sure no sale landed between reading
+		// the expected total and closing the drawer.
+		result, err := drawerSessionModel.UpdateOne(ctx,
+			bson.M{"drawer_session_id": session.Drawer_Session_Id, "status": "OPEN", "updated_at": session.Updated_At},
+			bson.M{"$set": bson.M{
+				"status":         "CLOSED",
+				"counted":        body.Counted,
+				"counted_total":  counted,
+				"expected_total": expected,
+				"over_short":     overShort,
+				"notes":          body.Notes,
+				"closed_at":      now,
+				"closed_by":      closedBy,
+				"updated_at":     now,
+			}},
+		)
+		if err != nil {
+			log.Printf("Error closing drawer session (id=%s): %v", session.Drawer_Session_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close cash drawer"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cash drawer changed while closing, count it again"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "Cash drawer closed successfully",
+			"counted_total":  counted,
+			"expected_total": expected,
+			"over_short":     overShort,
+		})
+	}
+}
+
+// CreateCashPayment settles an invoice in cash through the caller's open
+// drawer, recording what was tendered, the change given and any tip kept
+// from it. Card payments already captured are netted off the amount.
+func CreateCashPayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+
+		var body struct {
+			Tendered float64  `json:"tendered" validate:"gt=0"`
+			Tip      *float64 `json:"tip" validate:"omitempty,gte=0"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		uid := c.GetString("uid")
+		session, err := openDrawerSession(ctx, uid)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Open a cash drawer before taking cash"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching cash drawer"})
+			return
+		}
+
+		var invoice models.Invoice
+		if err := invoiceModel.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching invoice"})
+			return
+		}
+		if invoice.Payment_Status != nil && (*invoice.Payment_Status == "PAID" || *invoice.Payment_Status == "REFUNDED") {
+			c.JSON(http.StatusConflict, gin.H{"error": errInvoicePaid.Error()})
+			return
+		}
+
+		// On a split tender the cash only covers what the cards haven't.
+		authorized, captured, err := cardPaymentTotals(ctx, invoiceId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching card payments"})
+			return
+		}
+		if authorized > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Capture or void the invoice's authorised card payments before taking cash"})
+			return
+		}
+		amount := toFixed(invoice.Amount_Due-captured, 2)
+		if amount <= 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Card payments already cover the amount due"})
+			return
+		}
+		tendered := toFixed(body.Tendered, 2)
+		var tip float64
+		if body.Tip != nil {
+			tip = toFixed(*body.Tip, 2)
+		}
+		if tendered < toFixed(amount+tip, 2) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cash tendered does not cover the amount due", "amount_due": amount, "tip": tip})
+			return
+		}
+		change := toFixed(tendered-amount-tip, 2)
+
+		now := time.Now().UTC()
+		sessionId := session.Drawer_Session_Id
+		movement := models.DrawerMovement{
+			Type:       "SALE",
+			Amount:     amount,
+			Tip:        tip,
+			Tendered:   tendered,
+			Change:     change,
+			Invoice_Id: &invoiceId,
+			User_Id:    uid,
+			At:         now,
+		}
+
+		invoiceUpdate := bson.M{"$set": bson.M{
+			"payment_status":    "PAID",
+			"payment_method":    "CASH",
+			"drawer_session_id": sessionId,
+			"updated_at":        now,
+		}}
+		if tip > 0 {
+			invoiceUpdate["$push"] = bson.M{"tips": models.InvoiceTip{
+				Amount:      tip,
+				Method:      "CASH",
+				Server_Id:   tipServer(ctx, &invoice),
+				Recorded_By: &uid,
+				At:          now,
+			}}
+			invoiceUpdate["$inc"] = bson.M{"tip_amount": tip}
+		}
+
+		invoiceFilter := bson.M{"invoice_id": invoiceId, "payment_status": bson.M{"$nin": bson.A{"PAID", "REFUNDED"}}}
+		transactional, err := database.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+			result, err := invoiceModel.UpdateOne(sc, invoiceFilter, invoiceUpdate)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return errInvoicePaid
+			}
+
+			result, err = drawerSessionModel.UpdateOne(sc,
+				bson.M{"drawer_session_id": sessionId, "status": "OPEN"},
+				bson.M{
+					"$push": bson.M{"movements": movement},
+					"$inc":  bson.M{"cash_sales": amount, "cash_tips": tip, "change_given": change},
+					"$set":  bson.M{"updated_at": now},
+				},
+			)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return errDrawerClosed
+			}
+
+			return recordDocumentEvent(sc, "invoice.paid", invoiceId, invoiceModel, bson.M{"invoice_id": invoiceId})
+		})
+		if err != nil {
+			if !transactional && errors.Is(err, errDrawerClosed) {
+				revertCashPayment(ctx, &invoice, tip)
+			}
+			if errors.Is(err, errInvoicePaid) || errors.Is(err, errDrawerClosed) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("Error taking cash payment for invoice %s: %v", invoiceId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record cash payment"})
+			return
+		}
+
+		awardLoyaltyPoints(ctx, invoiceId)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":           "Cash payment recorded successfully",
+			"drawer_session_id": sessionId,
+			"amount":            amount,
+			"tip":               tip,
+			"tendered":          tendered,
+			"change":            change,
+		})
+	}
+}
+
+// revertCashPayment puts an invoice back the way it was when a cash payment
+// could not be booked to the drawer without a transaction.
+func revertCashPayment(ctx context.Context, invoice *models.Invoice, tip float64) {
+	update := bson.M{
+		"$set": bson.M{
+			"payment_status":    invoice.Payment_Status,
+			"payment_method":    invoice.Payment_Method,
+			"drawer_session_id": invoice.Drawer_Session_Id,
+			"tips":              invoice.Tips,
+			"updated_at":        invoice.Updated_At,
+		},
+		"$inc": bson.M{"tip_amount": -tip},
+	}
+	if _, err := invoiceModel.UpdateOne(ctx, bson.M{"invoice_id": invoice.Invoice_Id}, update); err != nil {
+		log.Printf("Error reverting cash payment on invoice %s: %v", invoice.Invoice_Id, err)
+	}
+}
+
+func openDrawerSession(ctx context.Context, cashierId string) (*models.DrawerSession, error) {
+	var session models.DrawerSession
+	if err := drawerSessionModel.FindOne(ctx, bson.M{"cashier_id": cashierId, "status": "OPEN"}).Decode(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// loadDrawerSession fetches the session in the path. Cashiers only see their
+// own drawers; managers see everyone's.
+func loadDrawerSession(ctx context.Context, c *gin.Context) (*models.DrawerSession, bool) {
+	var session models.DrawerSession
+	err := drawerSessionModel.FindOne(ctx, bson.M{"drawer_session_id": c.Param("drawer_session_id")}).Decode(&session)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cash drawer not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching cash drawer"})
+		return nil, false
+	}
+
+	role := c.GetString("role")
+	if session.Cashier_Id != c.GetString("uid") && role != "ADMIN" && role != "MANAGER" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This cash drawer belongs to another cashier"})
+		return nil, false
+	}
+	return &session, true
+}
+
+// cashDenominations lists the notes and coins a drawer is counted in, from
+// CASH_DENOMINATIONS.
+func cashDenominations() []float64 {
+	values := strings.Split(helpers.EnvString("CASH_DENOMINATIONS", "100,50,20,10,5,2,1,0.5,0.25,0.1,0.05,0.01"), ",")
+	denominations := make([]float64, 0, len(values))
+	for _, value := range values {
+		if d, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && d > 0 {
+			denominations = append(denominations, toFixed(d, 2))
+		}
+	}
+	return denominations
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var outboxModel *mongo.Collection = database.OpenCollection(database.MongoClient, "outbox")
+
+// RegisterEventSubscribers wires the in-process consumers of domain events.
+func RegisterEventSubscribers() {
+	helpers.Subscribe("webhooks", "*", queueWebhookDeliveries)
+	helpers.Subscribe("realtime", "*", pushRealtimeEvent)
+}
+
+// GetEvents lists outbox events, newest first, for troubleshooting.
+func GetEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if eventType := c.Query("type"); eventType != "" {
+			filter["type"] = eventType
+		}
+		if aggregateId := c.Query("aggregate_id"); aggregateId != "" {
+			filter["aggregate_id"] = aggregateId
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "occurred_at", Value: -1}}).SetLimit(100)
+
+		cursor, err := outboxModel.Find(ctx, filter, opts)
+		if err != nil {
+			log.Printf("Error fetching events: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching events"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var events []bson.M
+		if err := cursor.All(ctx, &events); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding events"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": events})
+	}
+}
+
+// RetryEvent puts a FAILED event back in the queue. Subscribers that
+// already handled it are skipped.
+func RetryEvent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		result, err := outboxModel.UpdateOne(ctx,
+			bson.M{"event_id": c.Param("event_id"), "status": "FAILED"},
+			bson.M{"$set": bson.M{"status": "PENDING", "attempts": 0, "next_attempt_at": time.Now().UTC()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue event"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed event not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Event requeued"})
+	}
+}
+
+// recordDocumentEvent records the current state of a document, used after
+// partial updates where the handler only has the changed fields.
+func recordDocumentEvent(ctx context.Context, eventType, aggregateId string, collection *mongo.Collection, filter bson.M) error {
+	var document bson.M
+	if err := collection.FindOne(ctx, filter).Decode(&document); err != nil {
+		return err
+	}
+	return helpers.RecordEvent(ctx, eventType, aggregateId, document)
+}
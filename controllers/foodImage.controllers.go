@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// foodImageVariants are the thumbnails generated for every upload, keyed by
+// the longest side in pixels.
+var foodImageVariants = map[string]int{
+	"thumbnail": 160,
+	"medium":    640,
+}
+
+// UploadFoodImage stores an image sent as the multipart field "image",
+// generates its thumbnails and points the food at them. Files from an
+// earlier upload are removed once the food is updated.
+func UploadFoodImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		foodId := c.Param("food_id")
+
+		var food models.Food
+		if err := foodModel.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching food"})
+			return
+		}
+
+		upload, ok := readImageUpload(c)
+		if !ok {
+			return
+		}
+
+		storage, err := helpers.GetFileStorage()
+		if err != nil {
+			log.Printf("Error opening file storage: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "File storage is not available"})
+			return
+		}
+
+		base := fmt.Sprintf("foods/%s/%s", foodId, randomFileName())
+		variants, err := saveImageVariants(ctx, storage, base, upload, foodImageVariants)
+		if err != nil {
+			log.Printf("Error saving image for food %s: %v", foodId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+			return
+		}
+
+		imageURL := variants["original"]
+		_, err = foodModel.UpdateOne(ctx,
+			bson.M{"food_id": foodId},
+			bson.M{"$set": bson.M{
+				"food_image":          imageURL,
+				"food_image_variants": variants,
+				"updated_at":          time.Now().UTC(),
+			}},
+		)
+		if err != nil {
+			deleteImageVariants(context.Background(), storage, variants)
+			log.Printf("Error updating image of food %s: %v", foodId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update food"})
+			return
+		}
+
+		deleteImageVariants(ctx, storage, food.Food_Image_Variants)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":             "Image uploaded",
+			"food_image":          imageURL,
+			"food_image_variants": variants,
+		})
+	}
+}
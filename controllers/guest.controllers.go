@@ -0,0 +1,533 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errGuestTableRevoked = errors.New("table code has been revoked")
+
+type GuestItemRequest struct {
+	Food_Id  string  `json:"food_id" validate:"required"`
+	Quantity int     `json:"quantity" validate:"required,min=1,max=20"`
+	Note     *string `json:"note" validate:"omitempty,max=200"`
+}
+
+type GuestOrderRequest struct {
+	Items []GuestItemRequest `json:"items" validate:"required,min=1,max=20,dive"`
+}
+
+// guestOrderApproval reports whether guest items wait for staff before they
+// reach the kitchen. On by default; GUEST_ORDER_APPROVAL=false fires them
+// straight away.
+func guestOrderApproval() bool {
+	return os.Getenv("GUEST_ORDER_APPROVAL") != "false"
+}
+
+// GenerateTableQR issues a new QR code for a table. Every code printed
+// before stops working.
+func GenerateTableQR() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		tableId := c.Param("table_id")
+
+		var table models.Table
+		err := tableModel.FindOneAndUpdate(
+			ctx,
+			bson.M{"table_id": tableId},
+			bson.M{
+				"$inc": bson.M{"qr_version": 1},
+				"$set": bson.M{"updated_at": time.Now()},
+			},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&table)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating table"})
+			return
+		}
+
+		writeTableQR(c, http.StatusCreated, &table)
+	}
+}
+
+// GetTableQR returns the table's current QR code, issuing the first one if
+// the table doesn't have any yet.
+func GetTableQR() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var table models.Table
+		err := tableModel.FindOneAndUpdate(
+			ctx,
+			bson.M{"table_id": c.Param("table_id"), "qr_version": bson.M{"$in": bson.A{nil, 0}}},
+			bson.M{"$set": bson.M{"qr_version": 1, "updated_at": time.Now()}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&table)
+		if err == mongo.ErrNoDocuments {
+			err = tableModel.FindOne(ctx, bson.M{"table_id": c.Param("table_id")}).Decode(&table)
+		}
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching table"})
+			return
+		}
+
+		writeTableQR(c, http.StatusOK, &table)
+	}
+}
+
+func writeTableQR(c *gin.Context, status int, table *models.Table) {
+	token, err := helpers.GenerateGuestToken(table.Table_Id, table.Qr_Version)
+	if err != nil {
+		log.Printf("Error signing table token for table %s: %v", table.Table_Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating table code"})
+		return
+	}
+
+	url := strings.TrimSuffix(helpers.EnvString("GUEST_ORDER_URL", "/guest"), "/") + "/" + token
+	c.JSON(status, gin.H{
+		"table_id":     table.Table_Id,
+		"table_number": table.Table_Number,
+		"qr_version":   table.Qr_Version,
+		"token":        token,
+		"url":          url,
+	})
+}
+
+func GetGuestMenu() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if _, err := loadGuestTable(ctx, c); err != nil {
+			writeGuestTableError(c, err)
+			return
+		}
+
+		now := time.Now()
+		cursor, err := menuModel.Find(ctx, bson.M{
+			"start_date": bson.M{"$lte": now},
+			"end_date":   bson.M{"$gte": now},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching menu"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var menus []models.Menu
+		if err := cursor.All(ctx, &menus); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding menu"})
+			return
+		}
+
+		menuIds := make([]string, 0, len(menus))
+		for _, menu := range menus {
+			menuIds = append(menuIds, menu.Menu_Id)
+		}
+
+		foodCursor, err := foodModel.Find(ctx, bson.M{"menu_id": bson.M{"$in": menuIds}}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching menu"})
+			return
+		}
+		defer foodCursor.Close(ctx)
+
+		var foods []models.Food
+		if err := foodCursor.All(ctx, &foods); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding menu"})
+			return
+		}
+
+		sections := make([]gin.H, 0, len(menus))
+		for _, menu := range menus {
+			items := []gin.H{}
+			for _, food := range foods {
+				if food.Menu_Id != nil && *food.Menu_Id == menu.Menu_Id {
+					items = append(items, gin.H{
+						"food_id":    food.Food_Id,
+						"name":       food.Name,
+						"price":      food.Price,
+						"food_image": food.Food_Image,
+					})
+				}
+			}
+			sections = append(sections, gin.H{
+				"menu_id":  menu.Menu_Id,
+				"name":     menu.Name,
+				"category": menu.Category,
+				"items":    items,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"menus": sections})
+	}
+}
+
+// GetGuestBill previews the table's running bill. Items still waiting for
+// approval are listed but not charged.
+func GetGuestBill() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		table, err := loadGuestTable(ctx, c)
+		if err != nil {
+			writeGuestTableError(c, err)
+			return
+		}
+
+		order, err := findOpenTableOrder(ctx, table.Table_Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching bill"})
+			return
+		}
+		if order == nil {
+			c.JSON(http.StatusOK, gin.H{"order_id": nil, "items": []gin.H{}, "total_amount": 0})
+			return
+		}
+
+		invoice := models.Invoice{Order_Id: order.Order_Id}
+		if _, err := computeInvoice(ctx, &invoice); err != nil {
+			log.Printf("Error computing bill for order %s: %v", order.Order_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error computing bill"})
+			return
+		}
+
+		items, err := ItemsByOrder(order.Order_Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching bill"})
+			return
+		}
+
+		var pending []gin.H
+		for _, item := range items {
+			if status, _ := item["status"].(string); status == "AWAITING_APPROVAL" {
+				name := ""
+				if food, ok := item["food_details"].(bson.M); ok {
+					name, _ = food["name"].(string)
+				}
+				pending = append(pending, gin.H{
+					"order_item_id": item["order_item_id"],
+					"name":          name,
+					"quantity":      item["quantity"],
+				})
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"order_id":           order.Order_Id,
+			"items":              invoice.Line_Items,
+			"awaiting_approval":  pending,
+			"applied_promotions": invoice.Applied_Promotions,
+			"sub_total":          invoice.Sub_Total,
+			"discount_total":     invoice.Discount_Total,
+			"tax_name":           invoice.Tax_Name,
+			"tax_amount":         invoice.Tax_Amount,
+			"total_amount":       invoice.Total_Amount,
+		})
+	}
+}
+
+// AddGuestItems adds items to the table's open order, opening one if the
+// table has none. Prices always come from the menu.
+func AddGuestItems() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		table, err := loadGuestTable(ctx, c)
+		if err != nil {
+			writeGuestTableError(c, err)
+			return
+		}
+
+		var req GuestOrderRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		foods := map[string]models.Food{}
+		for _, item := range req.Items {
+			if _, ok := foods[item.Food_Id]; ok {
+				continue
+			}
+
+			var food models.Food
+			if err := foodModel.FindOne(ctx, bson.M{"food_id": item.Food_Id}).Decode(&food); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown item " + item.Food_Id})
+				return
+			}
+
+			count, err := menuModel.CountDocuments(ctx, bson.M{
+				"menu_id":    food.Menu_Id,
+				"start_date": bson.M{"$lte": now},
+				"end_date":   bson.M{"$gte": now},
+			})
+			if err != nil || count == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Item " + item.Food_Id + " is not available right now"})
+				return
+			}
+			foods[item.Food_Id] = food
+		}
+
+		order, err := findOpenTableOrder(ctx, table.Table_Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching order"})
+			return
+		}
+		if order == nil {
+			order = &models.Order{Table_Id: &table.Table_Id}
+			if _, err := insertOrder(ctx, order); err != nil {
+				log.Printf("Error opening guest order for table %s: %v", table.Table_Id, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while creating order"})
+				return
+			}
+		}
+
+		status := "PENDING"
+		if guestOrderApproval() {
+			status = "AWAITING_APPROVAL"
+		}
+
+		created := make([]models.OrderItem, 0, len(req.Items))
+		for _, item := range req.Items {
+			food := foods[item.Food_Id]
+			quantity := strconv.Itoa(item.Quantity)
+			itemStatus := status
+
+			orderItem := models.OrderItem{
+				Quantity:   &quantity,
+				Unit_Price: food.Price,
+				Food_Id:    &food.Food_Id,
+				Order_Id:   order.Order_Id,
+				Status:     &itemStatus,
+				Source:     "GUEST",
+				Note:       item.Note,
+			}
+			if err := insertOrderItem(ctx, &orderItem); err != nil {
+				log.Printf("Error inserting guest item for order %s: %v", order.Order_Id, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adding items", "added": created})
+				return
+			}
+			created = append(created, orderItem)
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":  "Items added to your order",
+			"order_id": order.Order_Id,
+			"items":    created,
+		})
+	}
+}
+
+func CallWaiter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		table, err := loadGuestTable(ctx, c)
+		if err != nil {
+			writeGuestTableError(c, err)
+			return
+		}
+
+		err = helpers.RecordEvent(ctx, "table.waiter_called", table.Table_Id, gin.H{
+			"table_id":     table.Table_Id,
+			"table_number": table.Table_Number,
+			"called_at":    time.Now().UTC(),
+		})
+		if err != nil {
+			log.Printf("Error recording waiter call for table %s: %v", table.Table_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to call a waiter"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "A waiter is on the way"})
+	}
+}
+
+// GetPendingGuestItems lists guest items waiting for staff approval, oldest
+// first.
+func GetPendingGuestItems() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"status": "AWAITING_APPROVAL"}}},
+			{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}}}},
+			{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "food"},
+				{Key: "localField", Value: "food_id"},
+				{Key: "foreignField", Value: "food_id"},
+				{Key: "as", Value: "food_details"},
+			}}},
+			{{Key: "$unwind", Value: bson.D{
+				{Key: "path", Value: "$food_details"},
+				{Key: "preserveNullAndEmptyArrays", Value: true},
+			}}},
+			{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "order"},
+				{Key: "localField", Value: "order_id"},
+				{Key: "foreignField", Value: "order_id"},
+				{Key: "as", Value: "order"},
+			}}},
+			{{Key: "$unwind", Value: "$order"}},
+			{{Key: "$project", Value: bson.M{
+				"_id":           0,
+				"order_item_id": 1,
+				"order_id":      1,
+				"table_id":      "$order.table_id",
+				"food_id":       1,
+				"name":          "$food_details.name",
+				"quantity":      1,
+				"unit_price":    1,
+				"note":          1,
+				"created_at":    1,
+			}}},
+		}
+
+		cursor, err := orderItemModel.Aggregate(ctx, pipeline)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching guest orders"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var items []bson.M
+		if err := cursor.All(ctx, &items); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding guest orders"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": items})
+	}
+}
+
+// ApproveGuestItem sends a guest item to the kitchen.
+func ApproveGuestItem() gin.HandlerFunc {
+	return reviewGuestItem("PENDING", "Item sent to the kitchen")
+}
+
+func RejectGuestItem() gin.HandlerFunc {
+	return reviewGuestItem("REJECTED", "Item rejected")
+}
+
+func reviewGuestItem(status, message string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		orderItemId := c.Param("order_item_id")
+		filter := bson.M{"order_item_id": orderItemId}
+
+		var result *mongo.UpdateResult
+		_, err := database.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+			var txErr error
+			result, txErr = orderItemModel.UpdateOne(
+				sc,
+				bson.M{"order_item_id": orderItemId, "status": "AWAITING_APPROVAL"},
+				bson.M{"$set": bson.M{"status": status, "updated_at": time.Now()}},
+			)
+			if txErr != nil || result.MatchedCount == 0 {
+				return txErr
+			}
+			return recordDocumentEvent(sc, "order_item.updated", orderItemId, orderItemModel, filter)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating order item"})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			count, _ := orderItemModel.CountDocuments(ctx, filter)
+			if count == 0 {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Order item not found"})
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "Order item is not awaiting approval"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": message})
+	}
+}
+
+// loadGuestTable resolves the table behind the request's QR code, refusing
+// codes from an older QR version.
+func loadGuestTable(ctx context.Context, c *gin.Context) (*models.Table, error) {
+	var table models.Table
+	if err := tableModel.FindOne(ctx, bson.M{"table_id": c.GetString("guest_table_id")}).Decode(&table); err != nil {
+		return nil, err
+	}
+	if table.Qr_Version != c.GetInt("guest_qr_version") {
+		return nil, errGuestTableRevoked
+	}
+	return &table, nil
+}
+
+func writeGuestTableError(c *gin.Context, err error) {
+	if err == mongo.ErrNoDocuments || err == errGuestTableRevoked {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "This table code is no longer valid, please ask a waiter"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching table"})
+}
+
+// findOpenTableOrder returns the table's latest order that hasn't been
+// invoiced yet, or nil when the table has none.
+func findOpenTableOrder(ctx context.Context, tableId string) (*models.Order, error) {
+	cursor, err := orderModel.Find(ctx, bson.M{"table_id": tableId}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(1))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var orders []models.Order
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+
+	invoiced, err := invoiceModel.CountDocuments(ctx, bson.M{"order_id": orders[0].Order_Id})
+	if err != nil {
+		return nil, err
+	}
+	if invoiced > 0 {
+		return nil, nil
+	}
+	return &orders[0], nil
+}
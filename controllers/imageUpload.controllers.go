@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+)
+
+// Uploaded images are checked by content, not by the name or type the
+// client sends. These are the formats the standard library can decode for
+// thumbnails.
+var imageUploadTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// maxImagePixels guards against small files that decode to huge images.
+const maxImagePixels = 40_000_000
+
+const imagePathPrefix = "/images/"
+
+func imageUploadMaxBytes() int64 {
+	return int64(helpers.EnvInt("FOOD_IMAGE_MAX_BYTES", 5<<20))
+}
+
+// uploadedImage is a validated image from a multipart upload.
+type uploadedImage struct {
+	data        []byte
+	contentType string
+	ext         string
+	img         image.Image
+}
+
+// readImageUpload reads and checks the multipart field "image". On failure
+// it has already answered the request.
+func readImageUpload(c *gin.Context) (*uploadedImage, bool) {
+	maxBytes := imageUploadMaxBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+
+	header, err := c.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Images can be at most %d bytes", maxBytes)})
+			return nil, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Send the image as the multipart field \"image\""})
+		return nil, false
+	}
+	if header.Size > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Images can be at most %d bytes", maxBytes)})
+		return nil, false
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the uploaded image"})
+		return nil, false
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	file.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the uploaded image"})
+		return nil, false
+	}
+
+	detected := mimetype.Detect(data)
+	contentType := strings.SplitN(detected.String(), ";", 2)[0]
+	ext, ok := imageUploadTypes[contentType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Only JPEG, PNG and GIF images are accepted", "detected": contentType})
+		return nil, false
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width*config.Height > maxImagePixels {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The image is corrupt or its dimensions are too large"})
+		return nil, false
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The image is corrupt"})
+		return nil, false
+	}
+
+	return &uploadedImage{data: data, contentType: contentType, ext: ext, img: img}, true
+}
+
+// saveImageVariants stores the original under base and a thumbnail for
+// each of sizes, and returns their URLs keyed by variant name. Nothing is
+// left behind when it fails.
+func saveImageVariants(ctx context.Context, storage helpers.FileStorage, base string, upload *uploadedImage, sizes map[string]int) (map[string]string, error) {
+	saved := []string{}
+	cleanup := func() {
+		for _, key := range saved {
+			storage.Delete(context.Background(), key)
+		}
+	}
+
+	originalKey := base + upload.ext
+	if err := storage.Save(ctx, originalKey, bytes.NewReader(upload.data), upload.contentType); err != nil {
+		return nil, err
+	}
+	saved = append(saved, originalKey)
+
+	variants := map[string]string{"original": imagePathPrefix + originalKey}
+	for name, size := range sizes {
+		var buf bytes.Buffer
+		var err error
+		thumbExt, thumbType := ".jpg", "image/jpeg"
+		thumb := helpers.Thumbnail(upload.img, size)
+		if upload.contentType == "image/jpeg" {
+			err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 82})
+		} else {
+			// PNG keeps the transparency of PNG and GIF sources.
+			thumbExt, thumbType = ".png", "image/png"
+			err = png.Encode(&buf, thumb)
+		}
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("encoding %s thumbnail: %w", name, err)
+		}
+
+		key := base + "_" + name + thumbExt
+		if err := storage.Save(ctx, key, &buf, thumbType); err != nil {
+			cleanup()
+			return nil, err
+		}
+		saved = append(saved, key)
+		variants[name] = imagePathPrefix + key
+	}
+	return variants, nil
+}
+
+// deleteImageVariants removes files saved by saveImageVariants. URLs that
+// don't point at stored files are ignored.
+func deleteImageVariants(ctx context.Context, storage helpers.FileStorage, variants map[string]string) {
+	for _, url := range variants {
+		if key, ok := storedImageKey(url); ok {
+			if err := storage.Delete(ctx, key); err != nil {
+				log.Printf("Error removing old image %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// ServeImage streams a stored file. Keys are random per upload, so the
+// files never change and can be cached for good.
+func ServeImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		key, err := helpers.CleanStorageKey(c.Param("key"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+
+		storage, err := helpers.GetFileStorage()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "File storage is not available"})
+			return
+		}
+
+		file, info, err := storage.Open(ctx, key)
+		if err != nil {
+			if errors.Is(err, helpers.ErrFileNotFound) || errors.Is(err, helpers.ErrInvalidKey) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+				return
+			}
+			log.Printf("Error opening image %s: %v", key, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading image"})
+			return
+		}
+		defer file.Close()
+
+		c.Header("Content-Type", info.Content_Type)
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		c.Header("X-Content-Type-Options", "nosniff")
+
+		if seeker, ok := file.(io.ReadSeeker); ok {
+			http.ServeContent(c.Writer, c.Request, "", info.Modified, seeker)
+			return
+		}
+		c.DataFromReader(http.StatusOK, info.Size, info.Content_Type, file, nil)
+	}
+}
+
+func storedImageKey(url string) (string, bool) {
+	if !strings.HasPrefix(url, imagePathPrefix) {
+		return "", false
+	}
+	key, err := helpers.CleanStorageKey(strings.TrimPrefix(url, imagePathPrefix))
+	return key, err == nil
+}
+
+func randomFileName() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -2,11 +2,18 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
 	"github.com/djwhocodes/restaurant_management/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -16,6 +23,14 @@ import (
 )
 
 var invoiceModel *mongo.Collection = database.OpenCollection(database.MongoClient, "invoice")
+var invoiceVoidModel *mongo.Collection = database.OpenCollection(database.MongoClient, "invoice_void")
+var invoiceCounterModel *mongo.Collection = database.OpenCollection(database.MongoClient, "invoice_counter")
+
+// TAX_RATE is a percentage added on top of the discounted subtotal and
+// TAX_NAME is how it is labelled on receipts.
+func taxSettings() (name string, rate float64) {
+	return helpers.EnvString("TAX_NAME", "Tax"), helpers.EnvFloat("TAX_RATE", 0)
+}
 
 func GetInvoices() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -37,7 +52,21 @@ func GetInvoices() gin.HandlerFunc {
 		findOptions.SetSkip(int64(skip))
 		findOptions.SetLimit(int64(limit))
 
-		cursor, err := invoiceModel.Find(ctx, bson.M{}, findOptions)
+		filter := bson.M{}
+		if number := strings.TrimSpace(c.Query("invoice_number")); number != "" {
+			filter["invoice_number"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.ToUpper(number)), Options: ""}
+		}
+		if locationId := c.Query("location_id"); locationId != "" {
+			filter["location_id"] = locationId
+		}
+		if year, err := strconv.Atoi(c.Query("fiscal_year")); err == nil {
+			filter["fiscal_year"] = year
+		}
+		if status := c.Query("payment_status"); status != "" {
+			filter["payment_status"] = status
+		}
+
+		cursor, err := invoiceModel.Find(ctx, filter, findOptions)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching invoices"})
 			return
@@ -50,7 +79,7 @@ func GetInvoices() gin.HandlerFunc {
 			return
 		}
 
-		total, _ := invoiceModel.CountDocuments(ctx, bson.M{})
+		total, _ := invoiceModel.CountDocuments(ctx, filter)
 
 		c.JSON(http.StatusOK, gin.H{
 			"data":       invoices,
@@ -98,18 +127,104 @@ func CreateInvoice() gin.HandlerFunc {
 			return
 		}
 
+		if invoice.Customer_Id == nil {
+			invoice.Customer_Id = order.Customer_Id
+		}
+
+		invoice.Created_By = nil
+		if uid := c.GetString("uid"); uid != "" {
+			invoice.Created_By = &uid
+		}
+
+		// Payment and loyalty state only ever changes through their own
+		// endpoints, never from the body of a new invoice.
+		pending := "PENDING"
+		invoice.Payment_Status = &pending
+		invoice.Drawer_Session_Id = nil
+		invoice.Tips = nil
+		invoice.Tip_Amount = 0
+		invoice.Card_Reserved = 0
+		invoice.Points_Redeemed = 0
+		invoice.Points_Value = 0
+		invoice.Points_Earned = 0
+		invoice.Points_Awarded = false
+
+		coupon, err := computeInvoice(ctx, &invoice)
+		if err != nil {
+			if errors.Is(err, errCouponUnavailable) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon is invalid, expired or fully redeemed"})
+				return
+			}
+			if errors.Is(err, errCouponNotApplicable) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon does not apply to anything on this order"})
+				return
+			}
+			var belowMinimum *belowMinimumOrderError
+			if errors.As(err, &belowMinimum) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": belowMinimum.Error(), "minimum_order": belowMinimum.Minimum})
+				return
+			}
+			log.Printf("Error computing invoice for order %s: %v", invoice.Order_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error computing invoice"})
+			return
+		}
+
 		invoice.ID = primitive.NewObjectID()
 		invoice.Invoice_Id = invoice.ID.Hex()
 		invoice.Created_At = time.Now()
 		invoice.Updated_At = invoice.Created_At
 
-		if invoice.Payment_Status == nil {
-			status := "PENDING"
-			invoice.Payment_Status = &status
+		if invoice.Location_Id == "" {
+			invoice.Location_Id = defaultLocationId()
 		}
+		invoice.Fiscal_Year = fiscalYear(invoice.Created_At)
+
+		// The coupon use, the invoice number and the invoice itself are
+		// committed together, so a failed checkout never burns a number.
+		// Without transactions the steps are undone by hand instead, and a
+		// number that can't be given back is recorded as void.
+		var result *mongo.InsertOneResult
+		var seq int64
+		var couponRedeemed, inserted bool
+		transactional, err := database.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+			if coupon != nil {
+				if err := redeemCoupon(sc, coupon); err != nil {
+					return err
+				}
+				couponRedeemed = true
+			}
+
+			var txErr error
+			seq, txErr = nextInvoiceSeq(sc, invoice.Location_Id, invoice.Fiscal_Year)
+			if txErr != nil {
+				return txErr
+			}
+			invoice.Invoice_Number = formatInvoiceNumber(invoice.Location_Id, invoice.Fiscal_Year, seq)
 
-		result, err := invoiceModel.InsertOne(ctx, invoice)
+			if result, txErr = invoiceModel.InsertOne(sc, invoice); txErr != nil {
+				return txErr
+			}
+			inserted = true
+
+			return helpers.RecordEvent(sc, "invoice.created", invoice.Invoice_Id, invoice)
+		})
 		if err != nil {
+			if !transactional {
+				if inserted {
+					invoiceModel.DeleteOne(ctx, bson.M{"invoice_id": invoice.Invoice_Id})
+				}
+				if seq > 0 {
+					voidInvoiceSeq(ctx, &invoice, seq, err)
+				}
+				if couponRedeemed {
+					releaseCoupon(ctx, coupon)
+				}
+			}
+			if errors.Is(err, errCouponUnavailable) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon is invalid, expired or fully redeemed"})
+				return
+			}
+			log.Printf("Error creating invoice for order %s: %v", invoice.Order_Id, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating invoice"})
 			return
 		}
@@ -133,20 +248,33 @@ func UpdateInvoice() gin.HandlerFunc {
 			return
 		}
 
-		updateFields := bson.D{}
-		if invoice.Payment_Status != nil {
-			updateFields = append(updateFields, bson.E{Key: "payment_status", Value: invoice.Payment_Status})
+		// Payment state only moves through the card, cash and refund
+		// endpoints, which take the money, settle the drawer and award
+		// loyalty points.
+		if invoice.Payment_Status != nil || invoice.Payment_Method != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "payment_status and payment_method can't be set directly; take a card or cash payment instead"})
+			return
 		}
-		if invoice.Payment_Method != nil {
-			updateFields = append(updateFields, bson.E{Key: "payment_method", Value: invoice.Payment_Method})
+
+		updateFields := bson.D{}
+		if invoice.Customer_Id != nil {
+			updateFields = append(updateFields, bson.E{Key: "customer_id", Value: invoice.Customer_Id})
 		}
 		updateFields = append(updateFields, bson.E{Key: "updated_at", Value: time.Now()})
 
 		filter := bson.M{"invoice_id": invoiceId}
 		update := bson.D{{Key: "$set", Value: updateFields}}
 
-		result, err := invoiceModel.UpdateOne(ctx, filter, update)
+		var result *mongo.UpdateResult
+		_, err := database.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+			var txErr error
+			if result, txErr = invoiceModel.UpdateOne(sc, filter, update); txErr != nil || result.MatchedCount == 0 {
+				return txErr
+			}
+			return recordDocumentEvent(sc, "invoice.updated", invoiceId, invoiceModel, filter)
+		})
 		if err != nil {
+			log.Printf("Error updating invoice (id=%s): %v", invoiceId, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating invoice"})
 			return
 		}
@@ -159,3 +287,177 @@ func UpdateInvoice() gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "Invoice updated successfully"})
 	}
 }
+
+// computeInvoice prices the order's items and applies the running promotions
+// and the invoice's coupon, if any. Delivery orders add their zone's fee and
+// must meet its minimum. The coupon is returned unredeemed.
+func computeInvoice(ctx context.Context, invoice *models.Invoice) (*models.Coupon, error) {
+	var order models.Order
+	if err := orderModel.FindOne(ctx, bson.M{"order_id": invoice.Order_Id}).Decode(&order); err != nil {
+		return nil, err
+	}
+
+	items, err := ItemsByOrder(invoice.Order_Id)
+	if err != nil {
+		return nil, err
+	}
+
+	var coupon *models.Coupon
+	if invoice.Coupon_Code != nil && *invoice.Coupon_Code != "" {
+		coupon, err = findUsableCoupon(ctx, *invoice.Coupon_Code)
+		if err != nil {
+			return nil, err
+		}
+		invoice.Coupon_Code = coupon.Code
+	}
+
+	lines := invoiceLines(items)
+
+	promotions, err := loadActivePromotions(ctx, time.Now(), coupon)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := priceInvoice(invoice, &order, lines, promotions, coupon); err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// priceInvoice fills in the line items, discounts, delivery fee, tax and
+// totals from the order and the promotions running now.
+func priceInvoice(invoice *models.Invoice, order *models.Order, lines []models.InvoiceLineItem, promotions []models.Promotion, coupon *models.Coupon) error {
+	couponCode := ""
+	if coupon != nil {
+		couponCode = *coupon.Code
+	}
+
+	var subTotal float64
+	for _, line := range lines {
+		subTotal += line.Line_Total
+	}
+
+	applied, discount := applyPromotions(lines, promotions, couponCode)
+	if coupon != nil && !slices.ContainsFunc(applied, func(p models.AppliedPromotion) bool {
+		return p.Promotion_Id == *coupon.Promotion_Id
+	}) {
+		return errCouponNotApplicable
+	}
+
+	invoice.Server_Id = order.Server_Id
+	invoice.Order_Type = order.Order_Type
+	if invoice.Order_Type == "" {
+		invoice.Order_Type = "DINE_IN"
+	}
+	invoice.Delivery_Fee = 0
+	if order.Order_Type == "DELIVERY" {
+		if subTotal-discount < order.Minimum_Order {
+			return &belowMinimumOrderError{Minimum: order.Minimum_Order}
+		}
+		invoice.Delivery_Fee = order.Delivery_Fee
+	}
+
+	taxable := subTotal - discount + invoice.Delivery_Fee
+
+	invoice.Line_Items = lines
+	invoice.Applied_Promotions = applied
+	invoice.Sub_Total = toFixed(subTotal, 2)
+	invoice.Discount_Total = discount
+	invoice.Tax_Name, invoice.Tax_Rate = taxSettings()
+	invoice.Tax_Amount = toFixed(taxable*invoice.Tax_Rate/100, 2)
+	invoice.Total_Amount = toFixed(taxable+invoice.Tax_Amount, 2)
+	invoice.Amount_Due = invoice.Total_Amount
+
+	return nil
+}
+
+func invoiceLines(items []bson.M) []models.InvoiceLineItem {
+	lines := make([]models.InvoiceLineItem, 0, len(items))
+	for _, item := range items {
+		if status, _ := item["status"].(string); status == "AWAITING_APPROVAL" || status == "REJECTED" {
+			continue
+		}
+
+		line := models.InvoiceLineItem{}
+		line.Food_Id, _ = item["food_id"].(string)
+		line.Unit_Price, _ = item["unit_price"].(float64)
+
+		if quantity, ok := item["quantity"].(string); ok {
+			line.Quantity, _ = strconv.Atoi(quantity)
+		}
+
+		if food, ok := item["food_details"].(bson.M); ok {
+			line.Name, _ = food["name"].(string)
+			line.Menu_Id, _ = food["menu_id"].(string)
+		}
+
+		line.Line_Total = toFixed(line.Unit_Price*float64(line.Quantity), 2)
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// defaultLocationId is used for invoices that don't name a location.
+func defaultLocationId() string {
+	return helpers.EnvString("LOCATION_ID", "MAIN")
+}
+
+// fiscalYear is the calendar year in which the fiscal year containing t
+// began. FISCAL_YEAR_START_MONTH defaults to January.
+func fiscalYear(t time.Time) int {
+	startMonth := time.Month(helpers.EnvInt("FISCAL_YEAR_START_MONTH", 1))
+	if t.Month() < startMonth {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// formatInvoiceNumber builds numbers like INV-2026-000123. Locations other
+// than the default one get their id in the prefix so numbers stay unique.
+func formatInvoiceNumber(locationId string, year int, seq int64) string {
+	prefix := helpers.EnvString("INVOICE_NUMBER_PREFIX", "INV")
+	if locationId != defaultLocationId() {
+		prefix += "-" + locationId
+	}
+	return fmt.Sprintf("%s-%d-%06d", prefix, year, seq)
+}
+
+// nextInvoiceSeq atomically takes the next number from the counter for the
+// location and fiscal year, creating the counter on first use.
+func nextInvoiceSeq(ctx context.Context, locationId string, year int) (int64, error) {
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	var counter models.InvoiceCounter
+	err := invoiceCounterModel.FindOneAndUpdate(ctx,
+		bson.M{"_id": fmt.Sprintf("%s:%d", locationId, year)},
+		bson.M{
+			"$inc":         bson.M{"seq": 1},
+			"$setOnInsert": bson.M{"location_id": locationId, "fiscal_year": year},
+		},
+		opts,
+	).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+// voidInvoiceSeq records a number taken by a checkout that then failed
+// without a transaction to roll it back. Handing it back to the counter
+// isn't safe once another checkout may have taken the next one.
+func voidInvoiceSeq(ctx context.Context, invoice *models.Invoice, seq int64, cause error) {
+	void := models.InvoiceVoid{
+		Invoice_Number: formatInvoiceNumber(invoice.Location_Id, invoice.Fiscal_Year, seq),
+		Location_Id:    invoice.Location_Id,
+		Fiscal_Year:    invoice.Fiscal_Year,
+		Seq:            seq,
+		Order_Id:       invoice.Order_Id,
+		Reason:         cause.Error(),
+		Created_At:     time.Now().UTC(),
+	}
+	if _, err := invoiceVoidModel.InsertOne(ctx, void); err != nil {
+		log.Printf("Invoice number %s left a gap and could not be voided: %v", void.Invoice_Number, err)
+	}
+}
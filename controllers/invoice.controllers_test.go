@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/djwhocodes/restaurant_management/models"
+)
+
+func promotion(id, typ, scope string, value float64) models.Promotion {
+	return models.Promotion{Promotion_Id: id, Name: &id, Type: &typ, Scope: &scope, Value: &value}
+}
+
+func TestPriceInvoice(t *testing.T) {
+	t.Setenv("TAX_RATE", "10")
+
+	lines := []models.InvoiceLineItem{
+		{Food_Id: "burger", Menu_Id: "mains", Quantity: 2, Unit_Price: 10, Line_Total: 20},
+		{Food_Id: "soda", Menu_Id: "drinks", Quantity: 3, Unit_Price: 2, Line_Total: 6},
+	}
+
+	halfBurgers := promotion("half-burgers", "PERCENTAGE", "ITEM", 50)
+	halfBurgers.Food_Id = ptr("burger")
+
+	sodaDeal := promotion("soda-deal", "BUY_X_GET_Y", "ITEM", 0)
+	sodaDeal.Food_Id = ptr("soda")
+	sodaDeal.Buy_Quantity, sodaDeal.Get_Quantity = ptrInt(2), ptrInt(1)
+
+	couponOnly := promotion("coupon-only", "FIXED", "ORDER", 5)
+	couponOnly.Requires_Coupon = true
+	coupon := &models.Coupon{Code: ptr("SAVE5"), Promotion_Id: ptr("coupon-only")}
+
+	saladOnly := promotion("salad-only", "PERCENTAGE", "ITEM", 20)
+	saladOnly.Food_Id = ptr("salad")
+	saladCoupon := &models.Coupon{Code: ptr("SALAD"), Promotion_Id: ptr("salad-only")}
+
+	tests := []struct {
+		name       string
+		order      models.Order
+		promotions []models.Promotion
+		coupon     *models.Coupon
+		discount   float64
+		fee        float64
+		tax        float64
+		total      float64
+		wantErr    error
+	}{
+		{name: "no promotions", tax: 2.6, total: 28.6},
+		{name: "item percentage", promotions: []models.Promotion{halfBurgers}, discount: 10, tax: 1.6, total: 17.6},
+		{name: "buy two get one", promotions: []models.Promotion{sodaDeal}, discount: 2, tax: 2.4, total: 26.4},
+		{
+			name:       "order discount after item discounts",
+			promotions: []models.Promotion{halfBurgers, promotion("five-off", "FIXED", "ORDER", 5)},
+			discount:   15, tax: 1.1, total: 12.1,
+		},
+		{
+			name:       "order discount capped at what is left",
+			promotions: []models.Promotion{promotion("big", "FIXED", "ORDER", 100)},
+			discount:   26, tax: 0, total: 0,
+		},
+		{name: "coupon promotion", promotions: []models.Promotion{couponOnly}, coupon: coupon, discount: 5, tax: 2.1, total: 23.1},
+		{name: "coupon promotion that matches nothing", promotions: []models.Promotion{saladOnly}, coupon: saladCoupon, wantErr: errCouponNotApplicable},
+		{
+			name:  "delivery fee is taxed",
+			order: models.Order{Order_Type: "DELIVERY", Delivery_Fee: 4, Minimum_Order: 20},
+			fee:   4, tax: 3, total: 33,
+		},
+		{
+			name:       "delivery below the minimum after discounts",
+			order:      models.Order{Order_Type: "DELIVERY", Delivery_Fee: 4, Minimum_Order: 20},
+			promotions: []models.Promotion{halfBurgers},
+			wantErr:    &belowMinimumOrderError{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var invoice models.Invoice
+			err := priceInvoice(&invoice, &tt.order, lines, tt.promotions, tt.coupon)
+
+			var belowMinimum *belowMinimumOrderError
+			switch {
+			case errors.As(tt.wantErr, &belowMinimum):
+				if !errors.As(err, &belowMinimum) || belowMinimum.Minimum != tt.order.Minimum_Order {
+					t.Fatalf("priceInvoice() error = %v, want below minimum %v", err, tt.order.Minimum_Order)
+				}
+				return
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("priceInvoice() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case err != nil:
+				t.Fatalf("priceInvoice() error = %v", err)
+			}
+
+			if invoice.Sub_Total != 26 || invoice.Discount_Total != tt.discount || invoice.Delivery_Fee != tt.fee ||
+				invoice.Tax_Amount != tt.tax || invoice.Total_Amount != tt.total || invoice.Amount_Due != tt.total {
+				t.Errorf("priceInvoice() sub=%v discount=%v fee=%v tax=%v total=%v due=%v, want sub=26 discount=%v fee=%v tax=%v total=%v",
+					invoice.Sub_Total, invoice.Discount_Total, invoice.Delivery_Fee, invoice.Tax_Amount, invoice.Total_Amount, invoice.Amount_Due,
+					tt.discount, tt.fee, tt.tax, tt.total)
+			}
+			if tt.order.Order_Type == "" && invoice.Order_Type != "DINE_IN" {
+				t.Errorf("Order_Type = %q, want DINE_IN", invoice.Order_Type)
+			}
+			if tt.coupon != nil {
+				if len(invoice.Applied_Promotions) != 1 || invoice.Applied_Promotions[0].Coupon_Code != *tt.coupon.Code {
+					t.Errorf("Applied_Promotions = %+v, want one entry for coupon %s", invoice.Applied_Promotions, *tt.coupon.Code)
+				}
+			}
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func ptrInt(i int) *int {
+	return &i
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var invoiceDocumentModel *mongo.Collection = database.OpenCollection(database.MongoClient, "invoice_document")
+
+// GetInvoicePDF returns the issued PDF when one has been stored, so reissues
+// are byte-for-byte identical. Otherwise it renders a fresh copy.
+func GetInvoicePDF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+
+		var stored models.InvoiceDocument
+		err := invoiceDocumentModel.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&stored)
+		if err == nil {
+			c.Header("X-Invoice-Document", stored.Invoice_Document_Id)
+			writeInvoicePDF(c, invoiceId, stored.Data)
+			return
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("Error fetching stored invoice PDF (id=%s): %v", invoiceId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching invoice PDF"})
+			return
+		}
+
+		data, err := renderInvoicePDF(ctx, invoiceId)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+				return
+			}
+			log.Printf("Error rendering invoice PDF (id=%s): %v", invoiceId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rendering invoice PDF"})
+			return
+		}
+
+		writeInvoicePDF(c, invoiceId, data)
+	}
+}
+
+// IssueInvoicePDF renders the invoice and stores the result. An invoice is
+// only issued once; later requests get the stored copy from GetInvoicePDF.
+func IssueInvoicePDF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+
+		count, err := invoiceDocumentModel.CountDocuments(ctx, bson.M{"invoice_id": invoiceId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking issued invoice"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Invoice PDF has already been issued"})
+			return
+		}
+
+		data, err := renderInvoicePDF(ctx, invoiceId)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+				return
+			}
+			log.Printf("Error rendering invoice PDF (id=%s): %v", invoiceId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rendering invoice PDF"})
+			return
+		}
+
+		checksum := sha256.Sum256(data)
+		document := models.InvoiceDocument{
+			ID:           primitive.NewObjectID(),
+			Invoice_Id:   invoiceId,
+			Content_Type: "application/pdf",
+			Data:         data,
+			Checksum:     hex.EncodeToString(checksum[:]),
+			Created_At:   time.Now().UTC(),
+		}
+		document.Invoice_Document_Id = document.ID.Hex()
+
+		if _, err := invoiceDocumentModel.InsertOne(ctx, document); err != nil {
+			log.Printf("Error storing invoice PDF (id=%s): %v", invoiceId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store invoice PDF"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Invoice PDF issued successfully",
+			"data":    document,
+		})
+	}
+}
+
+func renderInvoicePDF(ctx context.Context, invoiceId string) ([]byte, error) {
+	var invoice models.Invoice
+	if err := invoiceModel.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		return nil, err
+	}
+
+	var customer *models.Customer
+	if invoice.Customer_Id != nil {
+		var found models.Customer
+		if err := customerModel.FindOne(ctx, bson.M{"customer_id": *invoice.Customer_Id}).Decode(&found); err == nil {
+			customer = &found
+		}
+	}
+
+	return helpers.InvoicePDF(&invoice, customer, helpers.RestaurantDetailsFromEnv()), nil
+}
+
+func writeInvoicePDF(c *gin.Context, invoiceId string, data []byte) {
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"invoice-%s.pdf\"", invoiceId))
+	c.Data(http.StatusOK, "application/pdf", data)
+}
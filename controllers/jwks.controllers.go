@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS publishes the public keys tokens are signed with, so other
+// services can verify them without a shared secret. Keys are announced
+// ahead of use, so a few minutes of caching is safe.
+func GetJWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{"keys": helpers.JWKS()})
+	}
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var loginAttemptModel *mongo.Collection = database.OpenCollection(database.MongoClient, "login_attempt")
+
+const (
+	loginSuccess   = "SUCCESS"
+	loginFailure   = "FAILURE"
+	loginThrottled = "THROTTLED"
+	loginUnlocked  = "UNLOCKED"
+)
+
+// maxLoginDelay caps the growing pause between failed attempts before an
+// account is locked outright.
+const maxLoginDelay = 30 * time.Second
+
+func loginMaxAttempts() int {
+	return helpers.EnvInt("LOGIN_MAX_ATTEMPTS", 5)
+}
+
+func loginMaxIPAttempts() int {
+	return helpers.EnvInt("LOGIN_MAX_IP_ATTEMPTS", 50)
+}
+
+// loginLockout is both how far back failures are counted and how long a
+// lock lasts.
+func loginLockout() time.Duration {
+	return time.Duration(helpers.EnvInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute
+}
+
+var (
+	dummyPasswordOnce sync.Once
+	dummyPasswordHash string
+)
+
+// compareDummyPassword spends as long as a real password check, so a login
+// for an unknown email can't be told apart by its response time.
+func compareDummyPassword(password string) {
+	dummyPasswordOnce.Do(func() {
+		dummyPasswordHash = HashPassword("not-a-real-password")
+	})
+	VerifyPassword(password, dummyPasswordHash)
+}
+
+// loginRetryAfter returns how long the email or address must wait before
+// another attempt. Each failure since the account's last success or unlock
+// doubles the delay, starting from the second one; the limit locks the
+// account for loginLockout. Addresses are locked after too many failures
+// across all accounts.
+func loginRetryAfter(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := time.Now().UTC()
+	since := now.Add(-loginLockout())
+
+	cursor, err := loginAttemptModel.Find(ctx,
+		bson.M{"email": email, "outcome": bson.M{"$ne": loginThrottled}, "created_at": bson.M{"$gte": since}},
+		options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}}).
+			SetLimit(int64(loginMaxAttempts())).
+			SetProjection(bson.M{"outcome": 1, "created_at": 1}),
+	)
+	if err != nil {
+		return 0, err
+	}
+	var recent []models.LoginAttempt
+	if err := cursor.All(ctx, &recent); err != nil {
+		return 0, err
+	}
+
+	failures := 0
+	var lastFailure time.Time
+	for _, attempt := range recent {
+		if attempt.Outcome != loginFailure {
+			break
+		}
+		if failures == 0 {
+			lastFailure = attempt.Created_At
+		}
+		failures++
+	}
+
+	var wait time.Duration
+	switch {
+	case failures >= loginMaxAttempts():
+		wait = lastFailure.Add(loginLockout()).Sub(now)
+	case failures >= 2:
+		delay := min(time.Second<<(failures-2), maxLoginDelay)
+		wait = lastFailure.Add(delay).Sub(now)
+	}
+
+	ipFilter := bson.M{"ip": ip, "outcome": loginFailure, "created_at": bson.M{"$gte": since}}
+	ipFailures, err := loginAttemptModel.CountDocuments(ctx, ipFilter)
+	if err != nil {
+		return 0, err
+	}
+	if ipFailures >= int64(loginMaxIPAttempts()) {
+		var oldest models.LoginAttempt
+		err := loginAttemptModel.FindOne(ctx, ipFilter, options.FindOne().SetSort(bson.D{{Key: "created_at", Value: 1}})).Decode(&oldest)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, err
+		}
+		wait = max(wait, oldest.Created_At.Add(loginLockout()).Sub(now))
+	}
+
+	return max(wait, 0), nil
+}
+
+func recordLoginAttempt(ctx context.Context, c *gin.Context, email, userId, outcome string) {
+	attempt := models.LoginAttempt{
+		ID:         primitive.NewObjectID(),
+		Email:      email,
+		User_Id:    userId,
+		Ip:         c.ClientIP(),
+		User_Agent: c.Request.UserAgent(),
+		Outcome:    outcome,
+		Actor_Id:   c.GetString("uid"),
+		Created_At: time.Now().UTC(),
+	}
+	if _, err := loginAttemptModel.InsertOne(ctx, attempt); err != nil {
+		log.Printf("Error recording login attempt for %s: %v", email, err)
+	}
+}
+
+// GetLoginAttempts lists the login audit trail, newest first, filtered by
+// email, ip or outcome.
+func GetLoginAttempts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if email := c.Query("email"); email != "" {
+			filter["email"] = NormalizeEmail(email)
+		}
+		if ip := c.Query("ip"); ip != "" {
+			filter["ip"] = ip
+		}
+		if outcome := c.Query("outcome"); outcome != "" {
+			filter["outcome"] = strings.ToUpper(outcome)
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit < 1 || limit > 500 {
+			limit = 50
+		}
+
+		cursor, err := loginAttemptModel.Find(ctx, filter, options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}}).
+			SetLimit(int64(limit)))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching login attempts"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var attempts []models.LoginAttempt
+		if err := cursor.All(ctx, &attempts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding login attempts"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": attempts})
+	}
+}
+
+// UnlockUser clears a user's failed password and PIN attempts. The unlock
+// is recorded in the login audit trail.
+func UnlockUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var user models.User
+		err := userModel.FindOneAndUpdate(ctx,
+			bson.M{"user_id": c.Param("user_id")},
+			bson.M{
+				"$set":   bson.M{"pin_failures": 0, "updated_at": time.Now()},
+				"$unset": bson.M{"pin_locked_until": ""},
+			},
+		).Decode(&user)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error unlocking user"})
+			return
+		}
+
+		recordLoginAttempt(ctx, c, NormalizeEmail(*user.Email), user.User_Id, loginUnlocked)
+
+		c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+	}
+}
@@ -2,12 +2,15 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
 	"github.com/djwhocodes/restaurant_management/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -40,7 +43,12 @@ func GetOrders() gin.HandlerFunc {
 		findOptions.SetSkip(int64(skip))
 		findOptions.SetLimit(int64(limit))
 
-		cursor, err := orderModel.Find(ctx, bson.M{}, findOptions)
+		filter := bson.M{}
+		if orderType := c.Query("order_type"); orderType != "" {
+			filter["order_type"] = orderType
+		}
+
+		cursor, err := orderModel.Find(ctx, filter, findOptions)
 		if err != nil {
 			log.Printf("Error fetching orders: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching orders"})
@@ -102,6 +110,11 @@ func CreateOrder() gin.HandlerFunc {
 			return
 		}
 
+		if err := validate.Struct(order); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
 		if order.Table_Id != nil {
 			err := tableModel.FindOne(ctx, bson.M{"table_id": *order.Table_Id}).Decode(&table)
 			if err != nil {
@@ -110,14 +123,27 @@ func CreateOrder() gin.HandlerFunc {
 			}
 		}
 
-		order.Created_At = time.Now()
-		order.Updated_At = order.Created_At
-		order.Order_Date = order.Created_At
-		order.ID = primitive.NewObjectID()
-		order.Order_Id = order.ID.Hex()
+		if order.Customer_Id != nil {
+			count, err := customerModel.CountDocuments(ctx, bson.M{"customer_id": *order.Customer_Id})
+			if err != nil || count == 0 {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+				return
+			}
+		}
 
-		result, err := orderModel.InsertOne(ctx, order)
+		if status, msg := prepareOrderType(ctx, &order); status != 0 {
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
+
+		order.Server_Id = nil
+		if uid := c.GetString("uid"); uid != "" {
+			order.Server_Id = &uid
+		}
+
+		result, err := insertOrder(ctx, &order)
 		if err != nil {
+			log.Printf("Error creating order: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while creating order"})
 			return
 		}
@@ -155,14 +181,71 @@ func UpdateOrder() gin.HandlerFunc {
 			}
 		}
 
+		if order.Customer_Id != nil {
+			count, err := customerModel.CountDocuments(ctx, bson.M{"customer_id": *order.Customer_Id})
+			if err != nil || count == 0 {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+				return
+			}
+		}
+
 		updateObj := bson.D{}
 
 		if order.Table_Id != nil {
 			updateObj = append(updateObj, bson.E{Key: "table_id", Value: *order.Table_Id})
 		}
+		if order.Customer_Id != nil {
+			updateObj = append(updateObj, bson.E{Key: "customer_id", Value: *order.Customer_Id})
+		}
 		if !order.Order_Date.IsZero() {
 			updateObj = append(updateObj, bson.E{Key: "order_date", Value: order.Order_Date})
 		}
+		if order.Promised_Time != nil {
+			if order.Promised_Time.Before(time.Now()) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "promised_time must be in the future"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "promised_time", Value: *order.Promised_Time})
+		}
+		if order.Contact != nil {
+			if err := validate.Struct(order.Contact); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "contact", Value: *order.Contact})
+		}
+		if order.Delivery_Address != nil {
+			if err := validate.Struct(order.Delivery_Address); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+				return
+			}
+
+			var existing models.Order
+			if err := orderModel.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&existing); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+				return
+			}
+			if existing.Order_Type != "DELIVERY" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Only delivery orders have a delivery address"})
+				return
+			}
+
+			zone, err := findDeliveryZone(ctx, nil, order.Delivery_Address)
+			if err != nil {
+				if errors.Is(err, errNoDeliveryZone) {
+					c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "We don't deliver to this address"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error finding delivery zone"})
+				return
+			}
+			updateObj = append(updateObj,
+				bson.E{Key: "delivery_address", Value: *order.Delivery_Address},
+				bson.E{Key: "delivery_zone_id", Value: zone.Delivery_Zone_Id},
+				bson.E{Key: "delivery_fee", Value: *zone.Delivery_Fee},
+				bson.E{Key: "minimum_order", Value: *zone.Minimum_Order},
+			)
+		}
 
 		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: time.Now().UTC()})
 
@@ -174,7 +257,14 @@ func UpdateOrder() gin.HandlerFunc {
 		filter := bson.M{"order_id": orderId}
 		update := bson.D{{Key: "$set", Value: updateObj}}
 
-		result, err := orderModel.UpdateOne(ctx, filter, update)
+		var result *mongo.UpdateResult
+		_, err := database.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+			var txErr error
+			if result, txErr = orderModel.UpdateOne(sc, filter, update); txErr != nil || result.MatchedCount == 0 {
+				return txErr
+			}
+			return recordDocumentEvent(sc, "order.updated", orderId, orderModel, filter)
+		})
 		if err != nil {
 			log.Printf("Error updating order (id=%s): %v", orderId, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
@@ -192,3 +282,97 @@ func UpdateOrder() gin.HandlerFunc {
 		})
 	}
 }
+
+// insertOrder stamps a new order and saves it together with its
+// order.created event. Callers validate the table and customer first.
+func insertOrder(ctx context.Context, order *models.Order) (*mongo.InsertOneResult, error) {
+	if order.Order_Type == "" {
+		order.Order_Type = "DINE_IN"
+	}
+	order.Created_At = time.Now()
+	order.Updated_At = order.Created_At
+	order.Order_Date = order.Created_At
+	order.ID = primitive.NewObjectID()
+	order.Order_Id = order.ID.Hex()
+
+	var result *mongo.InsertOneResult
+	_, err := database.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		var txErr error
+		if result, txErr = orderModel.InsertOne(sc, *order); txErr != nil {
+			return txErr
+		}
+		return helpers.RecordEvent(sc, "order.created", order.Order_Id, order)
+	})
+	return result, err
+}
+
+// prepareOrderType checks what each order type needs and prices deliveries
+// from their zone. A non-zero status is returned with a message for the
+// caller when the order can't be taken.
+func prepareOrderType(ctx context.Context, order *models.Order) (int, string) {
+	if order.Order_Type == "" {
+		order.Order_Type = "DINE_IN"
+	}
+
+	order.Delivery_Fee = 0
+	order.Minimum_Order = 0
+
+	if order.Promised_Time != nil && order.Promised_Time.Before(time.Now()) {
+		return http.StatusBadRequest, "promised_time must be in the future"
+	}
+
+	if order.Order_Type == "DINE_IN" {
+		order.Delivery_Address = nil
+		order.Delivery_Zone_Id = nil
+		return 0, ""
+	}
+
+	if order.Table_Id != nil {
+		return http.StatusBadRequest, "Takeaway and delivery orders cannot be seated at a table"
+	}
+
+	// Known customers don't have to repeat their contact details.
+	if order.Contact == nil && order.Customer_Id != nil {
+		var customer models.Customer
+		if err := customerModel.FindOne(ctx, bson.M{"customer_id": *order.Customer_Id}).Decode(&customer); err == nil && customer.Phone != nil {
+			contact := models.OrderContact{Phone: *customer.Phone}
+			if customer.First_Name != nil {
+				contact.Name = *customer.First_Name
+			}
+			if customer.Last_Name != nil {
+				contact.Name = strings.TrimSpace(contact.Name + " " + *customer.Last_Name)
+			}
+			if customer.Email != nil {
+				contact.Email = *customer.Email
+			}
+			order.Contact = &contact
+		}
+	}
+	if order.Contact == nil {
+		return http.StatusBadRequest, "contact is required for takeaway and delivery orders"
+	}
+
+	if order.Order_Type == "TAKEAWAY" {
+		order.Delivery_Address = nil
+		order.Delivery_Zone_Id = nil
+		return 0, ""
+	}
+
+	if order.Delivery_Address == nil {
+		return http.StatusBadRequest, "delivery_address is required for delivery orders"
+	}
+
+	zone, err := findDeliveryZone(ctx, order.Delivery_Zone_Id, order.Delivery_Address)
+	if err != nil {
+		if errors.Is(err, errNoDeliveryZone) {
+			return http.StatusUnprocessableEntity, "We don't deliver to this address"
+		}
+		log.Printf("Error finding delivery zone: %v", err)
+		return http.StatusInternalServerError, "Error finding delivery zone"
+	}
+
+	order.Delivery_Zone_Id = &zone.Delivery_Zone_Id
+	order.Delivery_Fee = *zone.Delivery_Fee
+	order.Minimum_Order = *zone.Minimum_Order
+	return 0, ""
+}
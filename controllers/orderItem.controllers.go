@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
 	"github.com/djwhocodes/restaurant_management/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -98,13 +99,12 @@ func CreateOrderItem() gin.HandlerFunc {
 			return
 		}
 
-		orderItem.ID = primitive.NewObjectID()
-		orderItem.Order_Item_Id = orderItem.ID.Hex()
-		orderItem.Created_At = time.Now()
-		orderItem.Updated_At = time.Now()
+		if err := validate.Struct(orderItem); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
 
-		_, err := orderItemModel.InsertOne(ctx, orderItem)
-		if err != nil {
+		if err := insertOrderItem(ctx, &orderItem); err != nil {
 			log.Printf("Error inserting order item: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating order item"})
 			return
@@ -144,14 +144,26 @@ func UpdateOrderItem() gin.HandlerFunc {
 		if orderItem.Order_Id != "" {
 			updateObj = append(updateObj, bson.E{Key: "order_id", Value: orderItem.Order_Id})
 		}
+		if orderItem.Status != nil {
+			if err := validate.Var(*orderItem.Status, "eq=PENDING|eq=PREPARING|eq=READY|eq=SERVED|eq=REJECTED"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "status", Value: *orderItem.Status})
+		}
 
 		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: time.Now()})
 
-		result, err := orderItemModel.UpdateOne(
-			ctx,
-			bson.M{"order_item_id": orderItemId},
-			bson.D{{Key: "$set", Value: updateObj}},
-		)
+		filter := bson.M{"order_item_id": orderItemId}
+
+		var result *mongo.UpdateResult
+		_, err := database.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+			var txErr error
+			if result, txErr = orderItemModel.UpdateOne(sc, filter, bson.D{{Key: "$set", Value: updateObj}}); txErr != nil || result.MatchedCount == 0 {
+				return txErr
+			}
+			return recordDocumentEvent(sc, "order_item.updated", orderItemId, orderItemModel, filter)
+		})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating order item"})
 			return
@@ -192,6 +204,28 @@ func GetOrderItemsByOrder() gin.HandlerFunc {
 	}
 }
 
+// insertOrderItem stamps a new order item, defaulting its status to PENDING,
+// and saves it together with its order_item.created event.
+func insertOrderItem(ctx context.Context, orderItem *models.OrderItem) error {
+	if orderItem.Status == nil {
+		status := "PENDING"
+		orderItem.Status = &status
+	}
+
+	orderItem.ID = primitive.NewObjectID()
+	orderItem.Order_Item_Id = orderItem.ID.Hex()
+	orderItem.Created_At = time.Now()
+	orderItem.Updated_At = time.Now()
+
+	_, err := database.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := orderItemModel.InsertOne(sc, *orderItem); err != nil {
+			return err
+		}
+		return helpers.RecordEvent(sc, "order_item.created", orderItem.Order_Item_Id, orderItem)
+	})
+	return err
+}
+
 func ItemsByOrder(orderID string) (orderItems []bson.M, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
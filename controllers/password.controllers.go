@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var passwordResetModel *mongo.Collection = database.OpenCollection(database.MongoClient, "password_reset")
+
+var errInvalidResetToken = errors.New("reset token is invalid or has expired")
+
+type changePasswordRequest struct {
+	Current_Password string `json:"current_password" validate:"required"`
+	New_Password     string `json:"new_password" validate:"required,min=6"`
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type resetPasswordRequest struct {
+	Token        string `json:"token" validate:"required"`
+	New_Password string `json:"new_password" validate:"required,min=6"`
+}
+
+// forgotPasswordResponse is sent whether or not the email is registered,
+// so the endpoint can't be used to discover accounts.
+const forgotPasswordResponse = "If the email is registered, a reset link has been sent"
+
+func passwordResetTTL() time.Duration {
+	return time.Duration(helpers.EnvInt("PASSWORD_RESET_TTL_MINUTES", 30)) * time.Minute
+}
+
+// ChangePassword sets a new password for the signed-in user after checking
+// the current one. Every other session is signed out and fresh tokens are
+// returned for this one.
+func ChangePassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var request changePasswordRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		var user models.User
+		if err := userModel.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		if valid, _ := VerifyPassword(request.Current_Password, *user.Password); !valid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+			return
+		}
+		if request.Current_Password == request.New_Password {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "New password must differ from the current one"})
+			return
+		}
+
+		if err := setPassword(ctx, user.User_Id, request.New_Password); err != nil {
+			log.Printf("Error changing password of user %s: %v", user.User_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+			return
+		}
+
+		token, refreshToken, err := helpers.GenerateAllTokens(*user.Email, *user.First_Name, *user.Last_Name, user.User_Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Password changed, but signing in again failed"})
+			return
+		}
+		helpers.UpdateAllTokens(token, refreshToken, user.User_Id)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Password changed",
+			"token":   token,
+			"refresh": refreshToken,
+		})
+	}
+}
+
+// ForgotPassword sends a single-use reset link through the configured
+// notifier. Earlier links for the same user stop working.
+func ForgotPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var request forgotPasswordRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		var user models.User
+		err := userModel.FindOne(ctx, bson.M{"email": NormalizeEmail(request.Email)}, options.FindOne().SetCollation(emailCollation)).Decode(&user)
+		if err != nil {
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				log.Printf("Error looking up user for password reset: %v", err)
+			}
+			c.JSON(http.StatusAccepted, gin.H{"message": forgotPasswordResponse})
+			return
+		}
+
+		if !user.IsActive() {
+			c.JSON(http.StatusAccepted, gin.H{"message": forgotPasswordResponse})
+			return
+		}
+
+		if err := sendPasswordReset(ctx, user); err != nil {
+			log.Printf("Error sending password reset to user %s: %v", user.User_Id, err)
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": forgotPasswordResponse})
+	}
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword. The
+// token is spent even if the rest fails, and all of the user's existing
+// tokens are revoked.
+func ResetPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var request resetPasswordRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		var reset models.PasswordReset
+		now := time.Now().UTC()
+		err := passwordResetModel.FindOneAndUpdate(ctx,
+			bson.M{
+				"token_hash": hashSecretToken(request.Token),
+				"used_at":    nil,
+				"expires_at": bson.M{"$gt": now},
+			},
+			bson.M{"$set": bson.M{"used_at": now}},
+		).Decode(&reset)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidResetToken.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking reset token"})
+			return
+		}
+
+		if err := setPassword(ctx, reset.User_Id, request.New_Password); err != nil {
+			log.Printf("Error resetting password of user %s: %v", reset.User_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password reset, please sign in again"})
+	}
+}
+
+// setPassword stores a new password hash and revokes the user's tokens.
+func setPassword(ctx context.Context, userId, password string) error {
+	result, err := userModel.UpdateOne(ctx,
+		bson.M{"user_id": userId},
+		bson.M{"$set": bson.M{"password": HashPassword(password), "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	_, err = helpers.RevokeTokens(ctx, bson.M{"user_id": userId})
+	return err
+}
+
+func sendPasswordReset(ctx context.Context, user models.User) error {
+	token, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	if _, err := passwordResetModel.DeleteMany(ctx, bson.M{"user_id": user.User_Id, "used_at": nil}); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	reset := models.PasswordReset{
+		ID:         primitive.NewObjectID(),
+		Token_Hash: hashSecretToken(token),
+		User_Id:    user.User_Id,
+		Expires_At: now.Add(passwordResetTTL()),
+		Created_At: now,
+	}
+	if _, err := passwordResetModel.InsertOne(ctx, reset); err != nil {
+		return err
+	}
+
+	notifier, err := helpers.GetNotifier()
+	if err != nil {
+		return err
+	}
+
+	link := helpers.EnvString("PASSWORD_RESET_URL", "http://localhost:8080/reset-password") + "?token=" + token
+	return notifier.Send(ctx, helpers.Notification{
+		To:      *user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Use this link to choose a new password. It expires in %d minutes and works once.\n\n%s",
+			int(passwordResetTTL().Minutes()), link),
+	})
+}
+
+func newSecretToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
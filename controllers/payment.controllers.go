@@ -0,0 +1,701 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var paymentModel *mongo.Collection = database.OpenCollection(database.MongoClient, "payment")
+
+func GetInvoicePayments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+		cursor, err := paymentModel.Find(ctx, bson.M{"invoice_id": invoiceId}, opts)
+		if err != nil {
+			log.Printf("Error fetching payments for invoice %s: %v", invoiceId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching payments"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var payments []bson.M
+		if err := cursor.All(ctx, &payments); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding payments"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"invoice_id": invoiceId, "data": payments})
+	}
+}
+
+func GetPayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var payment models.Payment
+		if err := paymentModel.FindOne(ctx, bson.M{"payment_id": c.Param("payment_id")}).Decode(&payment); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": payment})
+	}
+}
+
+// CreateCardPayment starts a card payment for an invoice. The payment is
+// recorded as PENDING before the gateway is called, then moves to
+// AUTHORIZED (and CAPTURED straight away when capture is true) or DECLINED.
+// A tip is charged on top of the amount and credited to the invoice once
+// the payment is captured.
+func CreateCardPayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+
+		var body struct {
+			Card_Token string   `json:"card_token" validate:"required"`
+			Amount     *float64 `json:"amount" validate:"omitempty,gt=0"`
+			Tip        *float64 `json:"tip" validate:"omitempty,gte=0"`
+			Provider   string   `json:"provider"`
+			Capture    bool     `json:"capture"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		provider, err := helpers.GetPaymentProvider(body.Provider)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var invoice models.Invoice
+		if err := invoiceModel.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+		if invoice.Payment_Status != nil && *invoice.Payment_Status == "PAID" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invoice is already paid"})
+			return
+		}
+
+		authorized, captured, err := cardPaymentTotals(ctx, invoiceId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching existing payments"})
+			return
+		}
+		outstanding := toFixed(invoice.Amount_Due-authorized-captured, 2)
+
+		amount := outstanding
+		if body.Amount != nil {
+			amount = toFixed(*body.Amount, 2)
+		}
+		if amount <= 0 || amount > outstanding {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive and no more than the amount still due", "outstanding": outstanding})
+			return
+		}
+
+		var tip float64
+		if body.Tip != nil {
+			tip = toFixed(*body.Tip, 2)
+		}
+
+		// Hold the amount on the invoice before anything else, so two
+		// terminals authorising at once can't together exceed what is due.
+		reserved, err := invoiceModel.UpdateOne(ctx,
+			bson.M{
+				"invoice_id":     invoiceId,
+				"payment_status": bson.M{"$ne": "PAID"},
+				"$expr": bson.M{"$lte": bson.A{
+					bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$card_reserved", 0}}, amount}},
+					bson.M{"$add": bson.A{"$amount_due", 0.005}},
+				}},
+			},
+			bson.M{"$inc": bson.M{"card_reserved": amount}},
+		)
+		if err != nil {
+			log.Printf("Error reserving card payment for invoice %s: %v", invoiceId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+			return
+		}
+		if reserved.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Another payment already covers the amount still due"})
+			return
+		}
+
+		now := time.Now().UTC()
+		payment := models.Payment{
+			ID:         primitive.NewObjectID(),
+			Invoice_Id: invoiceId,
+			Provider:   provider.Name(),
+			Amount:     amount,
+			Tip_Amount: tip,
+			Currency:   helpers.EnvString("CURRENCY", "USD"),
+			Status:     "PENDING",
+			History:    []models.PaymentEvent{{Status: "PENDING", At: now}},
+			Created_At: now,
+			Updated_At: now,
+		}
+		payment.Payment_Id = payment.ID.Hex()
+		payment.Authorized_Amount = toFixed(amount+tip, 2)
+
+		if _, err := paymentModel.InsertOne(ctx, payment); err != nil {
+			releaseCardReservation(ctx, &payment)
+			log.Printf("Error recording payment for invoice %s: %v", invoiceId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+			return
+		}
+
+		callCtx, callCancel := context.WithTimeout(ctx, helpers.PaymentCallTimeout())
+		result, err := provider.Authorize(callCtx, helpers.PaymentRequest{
+			Amount:          payment.Authorized_Amount,
+			Currency:        payment.Currency,
+			Card_Token:      body.Card_Token,
+			Idempotency_Key: payment.Payment_Id,
+			Description:     "Invoice " + helpers.InvoiceDisplayNumber(&invoice),
+		})
+		callCancel()
+
+		if err != nil {
+			status := "FAILED"
+			code := http.StatusBadGateway
+			if errors.Is(err, helpers.ErrPaymentDeclined) {
+				status = "DECLINED"
+				code = http.StatusPaymentRequired
+			} else if errors.Is(err, helpers.ErrPaymentTimeout) {
+				code = http.StatusGatewayTimeout
+			}
+			if moveCardPayment(ctx, payment.Payment_Id, "PENDING", status, result, nil) == nil {
+				releaseCardReservation(ctx, &payment)
+			}
+
+			c.JSON(code, gin.H{
+				"error":        result.Message,
+				"decline_code": result.Decline_Code,
+				"payment_id":   payment.Payment_Id,
+				"status":       status,
+			})
+			return
+		}
+
+		if err := moveCardPayment(ctx, payment.Payment_Id, "PENDING", "AUTHORIZED", result, nil); err != nil {
+			log.Printf("Error saving authorisation for payment %s: %v", payment.Payment_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Payment authorised but could not be saved"})
+			return
+		}
+
+		if body.Capture {
+			payment.Provider_Reference = result.Reference
+			payment.Status = "AUTHORIZED"
+			if code, msg := captureCardPayment(ctx, provider, &payment); code != http.StatusOK {
+				c.JSON(code, gin.H{"error": msg, "payment_id": payment.Payment_Id})
+				return
+			}
+		}
+
+		var saved models.Payment
+		paymentModel.FindOne(ctx, bson.M{"payment_id": payment.Payment_Id}).Decode(&saved)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Payment " + saved.Status,
+			"data":    saved,
+		})
+	}
+}
+
+func CapturePayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var body struct {
+			Tip *float64 `json:"tip" validate:"omitempty,gte=0"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		payment, provider, ok := loadCardPayment(ctx, c)
+		if !ok {
+			return
+		}
+
+		// A tip written on the slip after authorisation replaces the one
+		// given when the card was taken, as long as the authorisation
+		// covers it. It is saved with the capture.
+		if body.Tip != nil && payment.Status == "AUTHORIZED" {
+			tip := toFixed(*body.Tip, 2)
+			if authorized := authorizedAmount(payment); toFixed(payment.Amount+tip, 2) > authorized {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Tip is more than the authorisation covers", "max_tip": toFixed(authorized-payment.Amount, 2)})
+				return
+			}
+			payment.Tip_Amount = tip
+		}
+
+		if code, msg := captureCardPayment(ctx, provider, payment); code != http.StatusOK {
+			c.JSON(code, gin.H{"error": msg})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Payment captured successfully"})
+	}
+}
+
+func VoidPayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		payment, provider, ok := loadCardPayment(ctx, c)
+		if !ok {
+			return
+		}
+		if payment.Status != "AUTHORIZED" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only authorised payments can be voided"})
+			return
+		}
+
+		callCtx, callCancel := context.WithTimeout(ctx, helpers.PaymentCallTimeout())
+		result, err := provider.Void(callCtx, payment.Provider_Reference)
+		callCancel()
+		if err != nil {
+			log.Printf("Error voiding payment %s: %v", payment.Payment_Id, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider refused the void"})
+			return
+		}
+
+		if err := moveCardPayment(ctx, payment.Payment_Id, "AUTHORIZED", "VOIDED", result, nil); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Payment changed while voiding"})
+			return
+		}
+		releaseCardReservation(ctx, payment)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Payment voided successfully"})
+	}
+}
+
+func RefundPayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var body struct {
+			Amount *float64 `json:"amount" validate:"omitempty,gt=0"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		payment, provider, ok := loadCardPayment(ctx, c)
+		if !ok {
+			return
+		}
+		if payment.Status != "CAPTURED" && payment.Status != "PARTIALLY_REFUNDED" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only captured payments can be refunded"})
+			return
+		}
+
+		charged := toFixed(payment.Amount+payment.Tip_Amount, 2)
+		amount := toFixed(charged-payment.Refunded_Amount, 2)
+		if body.Amount != nil {
+			amount = toFixed(*body.Amount, 2)
+		}
+		if amount <= 0 || amount > toFixed(charged-payment.Refunded_Amount, 2) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Refund exceeds the captured amount"})
+			return
+		}
+
+		// Reserve the amount before calling the gateway, so two refunds
+		// racing each other can't both fit under what was charged. Half a
+		// cent of slack absorbs float drift in the running total.
+		now := time.Now().UTC()
+		var reserved models.Payment
+		err := paymentModel.FindOneAndUpdate(ctx,
+			bson.M{
+				"payment_id":      payment.Payment_Id,
+				"status":          bson.M{"$in": bson.A{"CAPTURED", "PARTIALLY_REFUNDED"}},
+				"refunded_amount": bson.M{"$lte": charged - amount + 0.005},
+			},
+			bson.M{"$inc": bson.M{"refunded_amount": amount}, "$set": bson.M{"updated_at": now}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&reserved)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Payment changed while refunding; the refund no longer fits"})
+				return
+			}
+			log.Printf("Error reserving refund for payment %s: %v", payment.Payment_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording refund"})
+			return
+		}
+
+		callCtx, callCancel := context.WithTimeout(ctx, helpers.PaymentCallTimeout())
+		result, err := provider.Refund(callCtx, payment.Provider_Reference, amount)
+		callCancel()
+		if err != nil {
+			log.Printf("Error refunding payment %s: %v", payment.Payment_Id, err)
+			if _, err := paymentModel.UpdateOne(ctx,
+				bson.M{"payment_id": payment.Payment_Id},
+				bson.M{"$inc": bson.M{"refunded_amount": -amount}, "$set": bson.M{"updated_at": time.Now().UTC()}},
+			); err != nil {
+				log.Printf("Error releasing refund reservation for payment %s: %v", payment.Payment_Id, err)
+			}
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider refused the refund"})
+			return
+		}
+
+		status := "PARTIALLY_REFUNDED"
+		if toFixed(reserved.Refunded_Amount, 2) >= charged {
+			status = "REFUNDED"
+		}
+
+		// A refund that finishes after the one completing the payment must
+		// not move it back to PARTIALLY_REFUNDED.
+		now = time.Now().UTC()
+		_, err = paymentModel.UpdateOne(ctx,
+			bson.M{"payment_id": payment.Payment_Id, "status": bson.M{"$ne": "REFUNDED"}},
+			bson.M{
+				"$set":  bson.M{"status": status, "updated_at": now},
+				"$push": bson.M{"history": models.PaymentEvent{Status: status, Message: result.Message, At: now}},
+			},
+		)
+		if err != nil {
+			log.Printf("Error saving refund for payment %s: %v", payment.Payment_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Refund succeeded but could not be saved"})
+			return
+		}
+
+		if status == "REFUNDED" {
+			removeCardTip(ctx, payment)
+			markInvoiceRefundedByCard(ctx, payment.Invoice_Id)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Payment refunded successfully", "amount": amount, "status": status})
+	}
+}
+
+// PaymentWebhook receives asynchronous status updates from a gateway, e.g.
+// a capture that settled late or a chargeback.
+func PaymentWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		provider, err := helpers.GetPaymentProvider(c.Param("provider"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+			return
+		}
+
+		payload, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		event, err := provider.VerifyWebhook(payload, c.GetHeader("X-Signature"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+			return
+		}
+
+		previous, ok := webhookTransitions[event.Status]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown payment status"})
+			return
+		}
+
+		// Only forward moves apply, so a late or replayed event can't undo a
+		// capture or refund, or settle the invoice twice.
+		now := time.Now().UTC()
+		result, err := paymentModel.UpdateOne(ctx,
+			bson.M{
+				"provider":           provider.Name(),
+				"provider_reference": event.Reference,
+				"status":             bson.M{"$in": previous},
+			},
+			bson.M{
+				"$set":  bson.M{"status": event.Status, "message": event.Message, "updated_at": now},
+				"$push": bson.M{"history": models.PaymentEvent{Status: event.Status, Message: "webhook: " + event.Message, At: now}},
+			},
+		)
+		if err != nil {
+			log.Printf("Error applying %s webhook for %s: %v", provider.Name(), event.Reference, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error applying webhook"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			count, err := paymentModel.CountDocuments(ctx, bson.M{"provider": provider.Name(), "provider_reference": event.Reference})
+			if err == nil && count == 0 {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Webhook ignored, payment already moved on"})
+			return
+		}
+
+		if result.ModifiedCount == 1 {
+			var payment models.Payment
+			if err := paymentModel.FindOne(ctx, bson.M{"provider_reference": event.Reference}).Decode(&payment); err == nil {
+				switch event.Status {
+				case "CAPTURED":
+					recordCardTip(ctx, &payment)
+					markInvoicePaidByCard(ctx, payment.Invoice_Id)
+				case "DECLINED", "FAILED", "VOIDED":
+					releaseCardReservation(ctx, &payment)
+				}
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook processed"})
+	}
+}
+
+// webhookTransitions lists, for each status a gateway can report, the
+// statuses a payment may move to it from.
+var webhookTransitions = map[string]bson.A{
+	"AUTHORIZED":         {"PENDING"},
+	"DECLINED":           {"PENDING"},
+	"FAILED":             {"PENDING"},
+	"CAPTURED":           {"PENDING", "AUTHORIZED"},
+	"VOIDED":             {"AUTHORIZED"},
+	"PARTIALLY_REFUNDED": {"CAPTURED", "PARTIALLY_REFUNDED"},
+	"REFUNDED":           {"CAPTURED", "PARTIALLY_REFUNDED"},
+}
+
+func loadCardPayment(ctx context.Context, c *gin.Context) (*models.Payment, helpers.PaymentProvider, bool) {
+	var payment models.Payment
+	if err := paymentModel.FindOne(ctx, bson.M{"payment_id": c.Param("payment_id")}).Decode(&payment); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return nil, nil, false
+	}
+
+	provider, err := helpers.GetPaymentProvider(payment.Provider)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+
+	return &payment, provider, true
+}
+
+// captureCardPayment captures an authorised payment, tip included, and once
+// captured credits the tip and marks the invoice as paid by card.
+func captureCardPayment(ctx context.Context, provider helpers.PaymentProvider, payment *models.Payment) (int, string) {
+	if payment.Status != "AUTHORIZED" {
+		return http.StatusConflict, "Only authorised payments can be captured"
+	}
+
+	callCtx, callCancel := context.WithTimeout(ctx, helpers.PaymentCallTimeout())
+	result, err := provider.Capture(callCtx, payment.Provider_Reference, toFixed(payment.Amount+payment.Tip_Amount, 2))
+	callCancel()
+	if err != nil {
+		log.Printf("Error capturing payment %s: %v", payment.Payment_Id, err)
+		if errors.Is(err, helpers.ErrPaymentTimeout) {
+			return http.StatusGatewayTimeout, "Payment provider timed out"
+		}
+		return http.StatusBadGateway, "Payment provider refused the capture"
+	}
+
+	if err := moveCardPayment(ctx, payment.Payment_Id, "AUTHORIZED", "CAPTURED", result, bson.M{"tip_amount": payment.Tip_Amount}); err != nil {
+		return http.StatusConflict, "Payment changed while capturing"
+	}
+
+	recordCardTip(ctx, payment)
+	markInvoicePaidByCard(ctx, payment.Invoice_Id)
+	return http.StatusOK, ""
+}
+
+// moveCardPayment records a status change, along with any extra fields,
+// but only if the payment is still in the expected state, so concurrent
+// captures and voids can't both win.
+func moveCardPayment(ctx context.Context, paymentId, from, to string, result helpers.PaymentResult, fields bson.M) error {
+	now := time.Now().UTC()
+	set := bson.M{
+		"status":       to,
+		"decline_code": result.Decline_Code,
+		"message":      result.Message,
+		"updated_at":   now,
+	}
+	for key, value := range fields {
+		set[key] = value
+	}
+	if result.Reference != "" {
+		set["provider_reference"] = result.Reference
+	}
+
+	res, err := paymentModel.UpdateOne(ctx,
+		bson.M{"payment_id": paymentId, "status": from},
+		bson.M{
+			"$set":  set,
+			"$push": bson.M{"history": models.PaymentEvent{Status: to, Message: result.Message, At: now}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return helpers.ErrPaymentState
+	}
+	return nil
+}
+
+// authorizedAmount is what the gateway approved for the payment. Payments
+// from before it was stored were authorised for their amount and tip.
+func authorizedAmount(payment *models.Payment) float64 {
+	if payment.Authorized_Amount > 0 {
+		return payment.Authorized_Amount
+	}
+	return toFixed(payment.Amount+payment.Tip_Amount, 2)
+}
+
+// releaseCardReservation gives back the part of the amount due a payment
+// was holding, once it has been declined, failed or voided.
+func releaseCardReservation(ctx context.Context, payment *models.Payment) {
+	_, err := invoiceModel.UpdateOne(ctx,
+		bson.M{"invoice_id": payment.Invoice_Id},
+		bson.M{"$inc": bson.M{"card_reserved": -payment.Amount}},
+	)
+	if err != nil {
+		log.Printf("Error releasing card reservation for payment %s: %v", payment.Payment_Id, err)
+	}
+}
+
+// cardPaymentTotals sums the invoice's payments that are authorised but not
+// yet captured, and those already captured, not counting tips.
+func cardPaymentTotals(ctx context.Context, invoiceId string) (authorized, captured float64, err error) {
+	cursor, err := paymentModel.Find(ctx, bson.M{
+		"invoice_id": invoiceId,
+		"status":     bson.M{"$in": bson.A{"AUTHORIZED", "CAPTURED"}},
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	var payments []models.Payment
+	if err := cursor.All(ctx, &payments); err != nil {
+		return 0, 0, err
+	}
+
+	for _, payment := range payments {
+		if payment.Status == "AUTHORIZED" {
+			authorized += payment.Amount
+		} else {
+			captured += payment.Amount
+		}
+	}
+	return toFixed(authorized, 2), toFixed(captured, 2), nil
+}
+
+// markInvoicePaidByCard settles the invoice once its captured card payments
+// cover the amount due.
+func markInvoicePaidByCard(ctx context.Context, invoiceId string) {
+	var invoice models.Invoice
+	if err := invoiceModel.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		return
+	}
+
+	cursor, err := paymentModel.Find(ctx, bson.M{"invoice_id": invoiceId, "status": "CAPTURED"})
+	if err != nil {
+		return
+	}
+	var captured []models.Payment
+	if err := cursor.All(ctx, &captured); err != nil {
+		return
+	}
+
+	var paid float64
+	for _, payment := range captured {
+		paid += payment.Amount
+	}
+	if toFixed(paid, 2) < invoice.Amount_Due {
+		return
+	}
+
+	var result *mongo.UpdateResult
+	_, err = database.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		var txErr error
+		result, txErr = invoiceModel.UpdateOne(sc,
+			bson.M{"invoice_id": invoiceId, "payment_status": bson.M{"$ne": "PAID"}},
+			bson.M{"$set": bson.M{"payment_status": "PAID", "payment_method": "CARD", "updated_at": time.Now().UTC()}},
+		)
+		if txErr != nil || result.ModifiedCount == 0 {
+			return txErr
+		}
+		return recordDocumentEvent(sc, "invoice.paid", invoiceId, invoiceModel, bson.M{"invoice_id": invoiceId})
+	})
+	if err != nil {
+		log.Printf("Error marking invoice %s paid: %v", invoiceId, err)
+		return
+	}
+	if result.ModifiedCount == 0 {
+		return
+	}
+
+	awardLoyaltyPoints(ctx, invoiceId)
+}
+
+// markInvoiceRefundedByCard marks a card-settled invoice as refunded once
+// nothing is left of its captured payments. With a split tender, refunding
+// one card leaves the invoice paid by the others.
+func markInvoiceRefundedByCard(ctx context.Context, invoiceId string) {
+	cursor, err := paymentModel.Find(ctx, bson.M{
+		"invoice_id": invoiceId,
+		"status":     bson.M{"$in": bson.A{"CAPTURED", "PARTIALLY_REFUNDED", "REFUNDED"}},
+	})
+	if err != nil {
+		return
+	}
+	var payments []models.Payment
+	if err := cursor.All(ctx, &payments); err != nil {
+		return
+	}
+
+	var kept float64
+	for _, payment := range payments {
+		kept += payment.Amount + payment.Tip_Amount - payment.Refunded_Amount
+	}
+	if toFixed(kept, 2) > 0 {
+		return
+	}
+
+	// Cash taken for the rest of a split bill isn't refunded here.
+	_, err = invoiceModel.UpdateOne(ctx,
+		bson.M{"invoice_id": invoiceId, "payment_method": "CARD"},
+		bson.M{"$set": bson.M{"payment_status": "REFUNDED", "updated_at": time.Now().UTC()}},
+	)
+	if err != nil {
+		log.Printf("Error marking invoice %s refunded: %v", invoiceId, err)
+	}
+}
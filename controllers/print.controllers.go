@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var printJobModel *mongo.Collection = database.OpenCollection(database.MongoClient, "print_job")
+
+// A claimed job that hasn't been acknowledged within this window is handed
+// out again, so a print agent that dies mid-job doesn't lose the ticket.
+const printJobClaimTimeout = 2 * time.Minute
+
+func GetInvoiceReceipt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		doc, err := receiptDocument(ctx, c.Param("invoice_id"))
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+				return
+			}
+			log.Printf("Error rendering receipt: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rendering receipt"})
+			return
+		}
+
+		writePrintDocument(c, doc)
+	}
+}
+
+func GetKitchenTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		doc, err := kitchenTicketDocument(ctx, c.Param("order_id"))
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+				return
+			}
+			log.Printf("Error rendering kitchen ticket: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rendering kitchen ticket"})
+			return
+		}
+
+		writePrintDocument(c, doc)
+	}
+}
+
+func GetPrintJobs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if printer := c.Query("printer"); printer != "" {
+			filter["printer"] = printer
+		}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+
+		opts := options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}}).
+			SetLimit(100).
+			SetProjection(bson.M{"data": 0})
+
+		cursor, err := printJobModel.Find(ctx, filter, opts)
+		if err != nil {
+			log.Printf("Error fetching print jobs: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching print jobs"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var jobs []bson.M
+		if err := cursor.All(ctx, &jobs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding print jobs"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": jobs})
+	}
+}
+
+func CreatePrintJob() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var job models.PrintJob
+		if err := c.ShouldBindJSON(&job); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(job); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		created, err := enqueuePrintJob(ctx, *job.Type, *job.Printer, *job.Reference_Id)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Nothing to print for reference_id"})
+				return
+			}
+			log.Printf("Error creating print job: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create print job"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":      "Print job queued",
+			"print_job_id": created.Print_Job_Id,
+		})
+	}
+}
+
+// ClaimPrintJob is polled by local print agents. It hands out the oldest
+// queued job for the printer, or 204 when there is nothing to print.
+func ClaimPrintJob() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		printer := c.Query("printer")
+		if printer == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "printer query parameter is required"})
+			return
+		}
+
+		now := time.Now().UTC()
+		filter := bson.M{
+			"printer": printer,
+			"$or": []bson.M{
+				{"status": "QUEUED"},
+				{"status": "PRINTING", "claimed_at": bson.M{"$lt": now.Add(-printJobClaimTimeout)}},
+			},
+		}
+		update := bson.M{
+			"$set": bson.M{"status": "PRINTING", "claimed_at": now, "claimed_by": c.GetString("terminal_id"), "updated_at": now},
+			"$inc": bson.M{"attempts": 1},
+		}
+		opts := options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "created_at", Value: 1}}).
+			SetReturnDocument(options.After)
+
+		var job models.PrintJob
+		err := printJobModel.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.Status(http.StatusNoContent)
+				return
+			}
+			log.Printf("Error claiming print job: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error claiming print job"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": job})
+	}
+}
+
+func AckPrintJob() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		printJobId := c.Param("print_job_id")
+
+		var body struct {
+			Status string `json:"status" validate:"required,eq=DONE|eq=FAILED"`
+			Error  string `json:"error"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		// Only the terminal holding the claim may finish the job; once a claim
+		// times out and another terminal takes it, the old ack no longer matches.
+		result, err := printJobModel.UpdateOne(ctx,
+			bson.M{"print_job_id": printJobId, "status": "PRINTING", "claimed_by": c.GetString("terminal_id")},
+			bson.M{"$set": bson.M{"status": body.Status, "error": body.Error, "updated_at": time.Now().UTC()}},
+		)
+		if err != nil {
+			log.Printf("Error acknowledging print job (id=%s): %v", printJobId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge print job"})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Print job not found or not claimed by this terminal"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Print job acknowledged"})
+	}
+}
+
+func enqueuePrintJob(ctx context.Context, jobType, printer, referenceId string) (*models.PrintJob, error) {
+	var doc *helpers.PrintDocument
+	var err error
+
+	switch jobType {
+	case "RECEIPT":
+		doc, err = receiptDocument(ctx, referenceId)
+	case "KITCHEN":
+		doc, err = kitchenTicketDocument(ctx, referenceId)
+	default:
+		err = fmt.Errorf("unknown print job type %q", jobType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	job := models.PrintJob{
+		ID:           primitive.NewObjectID(),
+		Type:         &jobType,
+		Printer:      &printer,
+		Reference_Id: &referenceId,
+		Data:         doc.ESCPOS(),
+		Status:       "QUEUED",
+		Created_At:   now,
+		Updated_At:   now,
+	}
+	job.Print_Job_Id = job.ID.Hex()
+
+	if _, err := printJobModel.InsertOne(ctx, job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func receiptDocument(ctx context.Context, invoiceId string) (*helpers.PrintDocument, error) {
+	var invoice models.Invoice
+	if err := invoiceModel.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		return nil, err
+	}
+	return helpers.ReceiptDocument(&invoice, helpers.ReceiptConfigFromEnv()), nil
+}
+
+func kitchenTicketDocument(ctx context.Context, orderId string) (*helpers.PrintDocument, error) {
+	var order models.Order
+	if err := orderModel.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return nil, err
+	}
+
+	header := helpers.KitchenTicketHeader{
+		OrderId:    order.Order_Id,
+		OrderType:  order.Order_Type,
+		PlacedAt:   order.Order_Date,
+		PromisedAt: order.Promised_Time,
+	}
+	if order.Contact != nil {
+		header.Customer = order.Contact.Name
+	}
+	if order.Table_Id != nil {
+		var table models.Table
+		if err := tableModel.FindOne(ctx, bson.M{"table_id": *order.Table_Id}).Decode(&table); err == nil && table.Table_Number != nil {
+			header.Table = fmt.Sprint(*table.Table_Number)
+		}
+	}
+
+	items, err := ItemsByOrder(orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	var ticketItems []helpers.KitchenTicketItem
+	for _, line := range invoiceLines(items) {
+		ticketItems = append(ticketItems, helpers.KitchenTicketItem{
+			Name:     line.Name,
+			Quantity: fmt.Sprint(line.Quantity),
+		})
+	}
+
+	return helpers.KitchenTicketDocument(header, ticketItems, helpers.ReceiptConfigFromEnv()), nil
+}
+
+// writePrintDocument answers with raw ESC/POS bytes for ?format=escpos and
+// with a plain-text preview otherwise.
+func writePrintDocument(c *gin.Context, doc *helpers.PrintDocument) {
+	if c.Query("format") == "escpos" {
+		c.Data(http.StatusOK, "application/octet-stream", doc.ESCPOS())
+		return
+	}
+	c.String(http.StatusOK, doc.PlainText())
+}
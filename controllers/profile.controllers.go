@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// avatarVariants are the sizes stored for profile pictures.
+var avatarVariants = map[string]int{
+	"small":  64,
+	"medium": 256,
+}
+
+// updateProfileRequest holds the fields users may change themselves. Email
+// and role are managed by an admin.
+type updateProfileRequest struct {
+	First_Name *string `json:"first_name" validate:"omitempty,min=2,max=100"`
+	Last_Name  *string `json:"last_name" validate:"omitempty,min=2,max=100"`
+	Phone      *string `json:"phone" validate:"omitempty,min=1"`
+	Avatar     *string `json:"avatar" validate:"omitempty,url"`
+}
+
+func GetMe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		user, ok := signedInUser(ctx, c)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, user.Public())
+	}
+}
+
+func UpdateMe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var request updateProfileRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		updateObj := bson.D{}
+		if request.First_Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "first_name", Value: *request.First_Name})
+		}
+		if request.Last_Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "last_name", Value: *request.Last_Name})
+		}
+		if request.Phone != nil {
+			updateObj = append(updateObj, bson.E{Key: "phone", Value: *request.Phone})
+		}
+		if request.Avatar != nil {
+			updateObj = append(updateObj, bson.E{Key: "avatar", Value: *request.Avatar})
+		}
+		if len(updateObj) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+			return
+		}
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: time.Now()})
+
+		update := bson.D{{Key: "$set", Value: updateObj}}
+		if request.Avatar != nil {
+			// An avatar set by URL replaces any uploaded one.
+			update = append(update, bson.E{Key: "$unset", Value: bson.M{"avatar_variants": ""}})
+		}
+
+		var previous struct {
+			Avatar_Variants map[string]string `bson:"avatar_variants"`
+		}
+		err := userModel.FindOneAndUpdate(ctx, bson.M{"user_id": c.GetString("uid")}, update).Decode(&previous)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Phone number already registered"})
+				return
+			}
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+
+		if request.Avatar != nil && previous.Avatar_Variants != nil {
+			if storage, err := helpers.GetFileStorage(); err == nil {
+				deleteImageVariants(ctx, storage, previous.Avatar_Variants)
+			}
+		}
+
+		user, ok := signedInUser(ctx, c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Profile updated", "data": user.Public()})
+	}
+}
+
+// UploadAvatar stores a profile picture sent as the multipart field
+// "image" and replaces the previous one.
+func UploadAvatar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		user, ok := signedInUser(ctx, c)
+		if !ok {
+			return
+		}
+
+		upload, ok := readImageUpload(c)
+		if !ok {
+			return
+		}
+
+		storage, err := helpers.GetFileStorage()
+		if err != nil {
+			log.Printf("Error opening file storage: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "File storage is not available"})
+			return
+		}
+
+		base := fmt.Sprintf("avatars/%s/%s", user.User_Id, randomFileName())
+		variants, err := saveImageVariants(ctx, storage, base, upload, avatarVariants)
+		if err != nil {
+			log.Printf("Error saving avatar for user %s: %v", user.User_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+			return
+		}
+
+		var updated struct {
+			Avatar          *string           `bson:"avatar"`
+			Avatar_Variants map[string]string `bson:"avatar_variants"`
+		}
+		err = userModel.FindOneAndUpdate(ctx,
+			bson.M{"user_id": user.User_Id},
+			bson.M{"$set": bson.M{
+				"avatar":          variants["medium"],
+				"avatar_variants": variants,
+				"updated_at":      time.Now(),
+			}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+		if err != nil {
+			deleteImageVariants(context.Background(), storage, variants)
+			log.Printf("Error updating avatar of user %s: %v", user.User_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+
+		deleteImageVariants(ctx, storage, user.Avatar_Variants)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":         "Avatar uploaded",
+			"avatar":          updated.Avatar,
+			"avatar_variants": updated.Avatar_Variants,
+		})
+	}
+}
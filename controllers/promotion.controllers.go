@@ -0,0 +1,461 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var promotionModel *mongo.Collection = database.OpenCollection(database.MongoClient, "promotion")
+
+func GetPromotions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil || limit < 1 {
+			limit = 10
+		}
+
+		filter := bson.M{}
+		if active := c.Query("active"); active != "" {
+			filter["active"] = active == "true"
+		}
+
+		opts := options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}}).
+			SetSkip(int64((page - 1) * limit)).
+			SetLimit(int64(limit))
+
+		cursor, err := promotionModel.Find(ctx, filter, opts)
+		if err != nil {
+			log.Printf("Error fetching promotions: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching promotions"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var promotions []bson.M
+		if err := cursor.All(ctx, &promotions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding promotions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"page":  page,
+			"limit": limit,
+			"data":  promotions,
+		})
+	}
+}
+
+func GetPromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		promotionId := c.Param("promotion_id")
+
+		var promotion models.Promotion
+		err := promotionModel.FindOne(ctx, bson.M{"promotion_id": promotionId}).Decode(&promotion)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			} else {
+				log.Printf("Error fetching promotion (id=%s): %v", promotionId, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching promotion"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": promotion})
+	}
+}
+
+func CreatePromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var promotion models.Promotion
+		if err := c.ShouldBindJSON(&promotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(promotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		if msg := checkPromotionRules(&promotion); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if promotion.Menu_Id != nil {
+			var menu models.Menu
+			if err := menuModel.FindOne(ctx, bson.M{"menu_id": *promotion.Menu_Id}).Decode(&menu); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
+				return
+			}
+		}
+
+		if promotion.Active == nil {
+			active := true
+			promotion.Active = &active
+		}
+
+		now := time.Now().UTC()
+		promotion.ID = primitive.NewObjectID()
+		promotion.Promotion_Id = promotion.ID.Hex()
+		promotion.Created_At = now
+		promotion.Updated_At = now
+
+		if _, err := promotionModel.InsertOne(ctx, promotion); err != nil {
+			log.Printf("Error inserting promotion: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create promotion"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Promotion created successfully",
+			"data":    promotion,
+		})
+	}
+}
+
+func UpdatePromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		promotionId := c.Param("promotion_id")
+
+		var promotion models.Promotion
+		if err := c.ShouldBindJSON(&promotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		var current models.Promotion
+		if err := promotionModel.FindOne(ctx, bson.M{"promotion_id": promotionId}).Decode(&current); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+
+		// Check the promotion as it will be saved, with the same rules
+		// CreatePromotion applies.
+		merged := current
+		if promotion.Name != nil {
+			merged.Name = promotion.Name
+		}
+		if promotion.Value != nil {
+			merged.Value = promotion.Value
+		}
+		if promotion.Start_Time != nil {
+			merged.Start_Time = promotion.Start_Time
+		}
+		if promotion.End_Time != nil {
+			merged.End_Time = promotion.End_Time
+		}
+		if promotion.Days_Of_Week != nil {
+			merged.Days_Of_Week = promotion.Days_Of_Week
+		}
+		if err := validate.Struct(merged); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+		if msg := checkPromotionRules(&merged); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		updateObj := bson.D{}
+
+		if promotion.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: *promotion.Name})
+		}
+		if promotion.Value != nil {
+			updateObj = append(updateObj, bson.E{Key: "value", Value: *promotion.Value})
+		}
+		if promotion.Start_Time != nil {
+			updateObj = append(updateObj, bson.E{Key: "start_time", Value: *promotion.Start_Time})
+		}
+		if promotion.End_Time != nil {
+			updateObj = append(updateObj, bson.E{Key: "end_time", Value: *promotion.End_Time})
+		}
+		if promotion.Days_Of_Week != nil {
+			updateObj = append(updateObj, bson.E{Key: "days_of_week", Value: promotion.Days_Of_Week})
+		}
+		if !promotion.Start_Date.IsZero() {
+			updateObj = append(updateObj, bson.E{Key: "start_date", Value: promotion.Start_Date})
+		}
+		if !promotion.End_Date.IsZero() {
+			updateObj = append(updateObj, bson.E{Key: "end_date", Value: promotion.End_Date})
+		}
+		if promotion.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: *promotion.Active})
+		}
+
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: time.Now().UTC()})
+
+		result, err := promotionModel.UpdateOne(
+			ctx,
+			bson.M{"promotion_id": promotionId},
+			bson.D{{Key: "$set", Value: updateObj}},
+		)
+		if err != nil {
+			log.Printf("Error updating promotion (id=%s): %v", promotionId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update promotion"})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Promotion updated successfully"})
+	}
+}
+
+// checkPromotionRules validates the combinations the struct tags can't express.
+func checkPromotionRules(promotion *models.Promotion) string {
+	switch *promotion.Type {
+	case "PERCENTAGE":
+		if promotion.Value == nil || *promotion.Value > 100 {
+			return "Percentage promotions need a value between 0 and 100"
+		}
+	case "FIXED":
+		if promotion.Value == nil {
+			return "Fixed promotions need a value"
+		}
+	case "BUY_X_GET_Y":
+		if *promotion.Scope != "ITEM" {
+			return "Buy X get Y promotions must have ITEM scope"
+		}
+		if promotion.Buy_Quantity == nil || promotion.Get_Quantity == nil {
+			return "Buy X get Y promotions need buy_quantity and get_quantity"
+		}
+	}
+
+	if (promotion.Start_Time == nil) != (promotion.End_Time == nil) {
+		return "start_time and end_time must be provided together"
+	}
+
+	return ""
+}
+
+// loadActivePromotions returns the automatic promotions running at the given
+// time, plus the promotion behind the coupon when one is supplied.
+func loadActivePromotions(ctx context.Context, now time.Time, coupon *models.Coupon) ([]models.Promotion, error) {
+	cursor, err := promotionModel.Find(ctx, bson.M{"active": true, "requires_coupon": false})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var candidates []models.Promotion
+	if err := cursor.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
+
+	if coupon != nil && !containsPromotion(candidates, *coupon.Promotion_Id) {
+		var couponPromotion models.Promotion
+		err := promotionModel.FindOne(ctx, bson.M{"promotion_id": *coupon.Promotion_Id, "active": true}).Decode(&couponPromotion)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, errCouponUnavailable
+			}
+			return nil, err
+		}
+		candidates = append(candidates, couponPromotion)
+	}
+
+	menus := map[string]*models.Menu{}
+	var promotions []models.Promotion
+	for _, promotion := range candidates {
+		if promotion.Menu_Id != nil {
+			menu, ok := menus[*promotion.Menu_Id]
+			if !ok {
+				var found models.Menu
+				if err := menuModel.FindOne(ctx, bson.M{"menu_id": *promotion.Menu_Id}).Decode(&found); err == nil {
+					menu = &found
+				}
+				menus[*promotion.Menu_Id] = menu
+			}
+			if menu == nil || !inTimeSpan(menu.Start_Date, menu.End_Date, now) {
+				continue
+			}
+		}
+
+		if promotionRunning(&promotion, now) {
+			promotions = append(promotions, promotion)
+		}
+	}
+
+	// A coupon whose promotion isn't running must not be redeemed for
+	// nothing.
+	if coupon != nil && !containsPromotion(promotions, *coupon.Promotion_Id) {
+		return nil, errCouponUnavailable
+	}
+
+	return promotions, nil
+}
+
+func containsPromotion(promotions []models.Promotion, promotionId string) bool {
+	for _, promotion := range promotions {
+		if promotion.Promotion_Id == promotionId {
+			return true
+		}
+	}
+	return false
+}
+
+// promotionRunning checks the date range, weekdays and daily time window.
+// Windows such as 22:00-02:00 wrap past midnight.
+func promotionRunning(promotion *models.Promotion, now time.Time) bool {
+	if !promotion.Start_Date.IsZero() && now.Before(promotion.Start_Date) {
+		return false
+	}
+	if !promotion.End_Date.IsZero() && now.After(promotion.End_Date) {
+		return false
+	}
+
+	if len(promotion.Days_Of_Week) > 0 {
+		today := false
+		for _, day := range promotion.Days_Of_Week {
+			if time.Weekday(day) == now.Weekday() {
+				today = true
+				break
+			}
+		}
+		if !today {
+			return false
+		}
+	}
+
+	if promotion.Start_Time != nil && promotion.End_Time != nil {
+		start, err1 := time.Parse("15:04", *promotion.Start_Time)
+		end, err2 := time.Parse("15:04", *promotion.End_Time)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+
+		minute := now.Hour()*60 + now.Minute()
+		from := start.Hour()*60 + start.Minute()
+		to := end.Hour()*60 + end.Minute()
+
+		if from <= to {
+			return minute >= from && minute < to
+		}
+		return minute >= from || minute < to
+	}
+
+	return true
+}
+
+// applyPromotions discounts the invoice lines. Item-level promotions run
+// first, order-level promotions then apply to what is left.
+func applyPromotions(lines []models.InvoiceLineItem, promotions []models.Promotion, couponCode string) ([]models.AppliedPromotion, float64) {
+	var applied []models.AppliedPromotion
+	var subTotal, discountTotal float64
+
+	remaining := make([]float64, len(lines))
+	for i, line := range lines {
+		remaining[i] = line.Line_Total
+		subTotal += line.Line_Total
+	}
+
+	for _, promotion := range promotions {
+		if *promotion.Scope != "ITEM" {
+			continue
+		}
+
+		for i, line := range lines {
+			if promotion.Food_Id != nil && *promotion.Food_Id != line.Food_Id {
+				continue
+			}
+			if promotion.Menu_Id != nil && *promotion.Menu_Id != line.Menu_Id {
+				continue
+			}
+
+			var amount float64
+			switch *promotion.Type {
+			case "PERCENTAGE":
+				amount = remaining[i] * *promotion.Value / 100
+			case "FIXED":
+				amount = *promotion.Value * float64(line.Quantity)
+			case "BUY_X_GET_Y":
+				group := *promotion.Buy_Quantity + *promotion.Get_Quantity
+				free := line.Quantity / group * *promotion.Get_Quantity
+				amount = float64(free) * line.Unit_Price
+			}
+
+			amount = toFixed(min(amount, remaining[i]), 2)
+			if amount <= 0 {
+				continue
+			}
+
+			remaining[i] -= amount
+			discountTotal += amount
+			applied = append(applied, appliedPromotion(&promotion, couponCode, line.Food_Id, amount))
+		}
+	}
+
+	for _, promotion := range promotions {
+		if *promotion.Scope != "ORDER" {
+			continue
+		}
+
+		left := subTotal - discountTotal
+
+		var amount float64
+		switch *promotion.Type {
+		case "PERCENTAGE":
+			amount = left * *promotion.Value / 100
+		case "FIXED":
+			amount = *promotion.Value
+		}
+
+		amount = toFixed(min(amount, left), 2)
+		if amount <= 0 {
+			continue
+		}
+
+		discountTotal += amount
+		applied = append(applied, appliedPromotion(&promotion, couponCode, "", amount))
+	}
+
+	return applied, toFixed(discountTotal, 2)
+}
+
+func appliedPromotion(promotion *models.Promotion, couponCode, foodId string, amount float64) models.AppliedPromotion {
+	entry := models.AppliedPromotion{
+		Promotion_Id: promotion.Promotion_Id,
+		Name:         *promotion.Name,
+		Food_Id:      foodId,
+		Amount:       amount,
+	}
+	if promotion.Requires_Coupon {
+		entry.Coupon_Code = couponCode
+	}
+	return entry
+}
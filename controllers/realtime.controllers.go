@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	realtimeHeartbeat   = 25 * time.Second
+	realtimeReadTimeout = 90 * time.Second
+)
+
+var errRealtimeOrigin = errors.New("origin not allowed")
+
+type realtimeCommand struct {
+	Action string   `json:"action"`
+	Topics []string `json:"topics"`
+}
+
+// RealtimeSocket upgrades to a WebSocket for waiter tablets and the kitchen.
+// Browsers can't set headers on WebSocket requests, so the JWT may also be
+// passed as ?token=. Initial topics come from ?topics=table:<id>,kitchen and
+// ?since=<seq> replays what was missed since the last connection. Clients
+// send {"action":"subscribe"|"unsubscribe","topics":[...]} or
+// {"action":"ping"}, and receive a heartbeat every 25 seconds.
+func RealtimeSocket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader("token")
+		if token == "" {
+			token = c.Query("token")
+		}
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No authorization token provided"})
+			return
+		}
+
+		claims, msg := helpers.ValidateToken(token)
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		topics, ok := parseRealtimeTopics(strings.Split(c.Query("topics"), ","))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Topics must be table:<id>, order:<id> or kitchen"})
+			return
+		}
+		since, _ := strconv.ParseUint(c.Query("since"), 10, 64)
+
+		server := websocket.Server{
+			// Any web page can open a WebSocket here, so browser origins
+			// are checked as well as the JWT.
+			Handshake: func(_ *websocket.Config, r *http.Request) error {
+				if !helpers.RealtimeOriginAllowed(r.Header.Get("Origin"), r.Host) {
+					return errRealtimeOrigin
+				}
+				return nil
+			},
+			Handler: func(conn *websocket.Conn) {
+				serveRealtime(conn, claims.Uid, topics, since)
+			},
+		}
+		server.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+func serveRealtime(conn *websocket.Conn, uid string, topics []string, since uint64) {
+	defer conn.Close()
+
+	client, replay, resync := helpers.Realtime.Register(topics, since)
+	defer helpers.Realtime.Unregister(client)
+
+	log.Printf("Realtime client connected (uid=%s, topics=%v)", uid, topics)
+
+	send := func(message helpers.RealtimeMessage) bool {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return websocket.JSON.Send(conn, message) == nil
+	}
+
+	hello := helpers.RealtimeMessage{Type: "subscribed", Seq: helpers.Realtime.Seq(), Topics: topics, At: time.Now().UTC()}
+	if !send(hello) {
+		return
+	}
+	if resync {
+		if !send(helpers.RealtimeMessage{Type: "resync_required", Seq: helpers.Realtime.Seq(), At: time.Now().UTC()}) {
+			return
+		}
+	}
+	for _, message := range replay {
+		if !send(message) {
+			return
+		}
+	}
+
+	go readRealtimeCommands(conn, client)
+
+	heartbeat := time.NewTicker(realtimeHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-client.Closed:
+			return
+		case message := <-client.Send:
+			if !send(message) {
+				return
+			}
+		case <-heartbeat.C:
+			if !send(helpers.RealtimeMessage{Type: "heartbeat", Seq: helpers.Realtime.Seq(), At: time.Now().UTC()}) {
+				return
+			}
+		}
+	}
+}
+
+// readRealtimeCommands handles subscription changes. A client that stays
+// silent past the read timeout is treated as gone.
+func readRealtimeCommands(conn *websocket.Conn, client *helpers.RealtimeClient) {
+	defer helpers.Realtime.Unregister(client)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(realtimeReadTimeout))
+
+		var command realtimeCommand
+		if err := websocket.JSON.Receive(conn, &command); err != nil {
+			return
+		}
+
+		reply := helpers.RealtimeMessage{At: time.Now().UTC(), Seq: helpers.Realtime.Seq()}
+		switch command.Action {
+		case "subscribe", "unsubscribe":
+			topics, ok := parseRealtimeTopics(command.Topics)
+			if !ok {
+				reply.Type = "error"
+				break
+			}
+			if command.Action == "subscribe" {
+				helpers.Realtime.Subscribe(client, topics)
+			} else {
+				helpers.Realtime.Unsubscribe(client, topics)
+			}
+			reply.Type = "subscribed"
+			reply.Topics = helpers.Realtime.Topics(client)
+		case "ping":
+			reply.Type = "pong"
+		default:
+			reply.Type = "error"
+		}
+
+		select {
+		case client.Send <- reply:
+		case <-client.Closed:
+			return
+		}
+	}
+}
+
+func parseRealtimeTopics(raw []string) ([]string, bool) {
+	var topics []string
+	for _, topic := range raw {
+		topic = strings.TrimSpace(topic)
+		switch {
+		case topic == "":
+			continue
+		case topic == "kitchen":
+		case strings.HasPrefix(topic, "table:") && len(topic) > len("table:"):
+		case strings.HasPrefix(topic, "order:") && len(topic) > len("order:"):
+		default:
+			return nil, false
+		}
+		topics = append(topics, topic)
+	}
+	return topics, true
+}
+
+// pushRealtimeEvent is the event bus subscriber that routes domain events
+// to WebSocket topics.
+func pushRealtimeEvent(ctx context.Context, event helpers.DomainEvent) error {
+	var payload struct {
+		Order_Id string  `json:"order_id"`
+		Table_Id *string `json:"table_id"`
+		Status   *string `json:"status"`
+	}
+	json.Unmarshal(event.Payload, &payload)
+
+	topics := map[string]bool{}
+
+	switch {
+	case strings.HasPrefix(event.Type, "table."):
+		topics["table:"+event.Aggregate_Id] = true
+	case strings.HasPrefix(event.Type, "order."):
+		topics["order:"+event.Aggregate_Id] = true
+		topics["kitchen"] = true
+		if payload.Table_Id != nil {
+			topics["table:"+*payload.Table_Id] = true
+		}
+	case strings.HasPrefix(event.Type, "order_item."), strings.HasPrefix(event.Type, "invoice."):
+		if payload.Order_Id == "" {
+			break
+		}
+		topics["order:"+payload.Order_Id] = true
+		// Guest items only reach the kitchen once staff approve them.
+		if strings.HasPrefix(event.Type, "order_item.") && (payload.Status == nil || *payload.Status != "AWAITING_APPROVAL") {
+			topics["kitchen"] = true
+		}
+
+		var order models.Order
+		if err := orderModel.FindOne(ctx, bson.M{"order_id": payload.Order_Id}).Decode(&order); err == nil && order.Table_Id != nil {
+			topics["table:"+*order.Table_Id] = true
+		}
+	}
+
+	list := make([]string, 0, len(topics))
+	for topic := range topics {
+		list = append(list, topic)
+	}
+	sort.Strings(list)
+	helpers.Realtime.Publish(list, event)
+	return nil
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ServerSales struct {
+	Server_Id    string  `bson:"_id" json:"server_id"`
+	Name         string  `bson:"-" json:"name"`
+	Invoices     int     `bson:"invoices" json:"invoices"`
+	Sub_Total    float64 `bson:"sub_total" json:"sub_total"`
+	Total_Amount float64 `bson:"total_amount" json:"total_amount"`
+	Paid_Amount  float64 `bson:"paid_amount" json:"paid_amount"`
+}
+
+// GetSalesByServer totals invoices per server for a from/to date range,
+// the current pay period by default. Invoices for orders nobody took,
+// such as guest and delivery platform orders, are grouped under an empty
+// server_id. Refunded invoices are left out.
+func GetSalesByServer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		from, to, err := parsePeriod(c.Query("from"), c.Query("to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{
+				"created_at":     bson.M{"$gte": from, "$lt": to},
+				"payment_status": bson.M{"$ne": "REFUNDED"},
+			}}},
+			{{Key: "$group", Value: bson.M{
+				"_id":          bson.M{"$ifNull": bson.A{"$server_id", ""}},
+				"invoices":     bson.M{"$sum": 1},
+				"sub_total":    bson.M{"$sum": "$sub_total"},
+				"total_amount": bson.M{"$sum": "$total_amount"},
+				"paid_amount": bson.M{"$sum": bson.M{"$cond": bson.A{
+					bson.M{"$eq": bson.A{"$payment_status", "PAID"}}, "$total_amount", 0,
+				}}},
+			}}},
+			{{Key: "$sort", Value: bson.M{"total_amount": -1}}},
+		}
+
+		cursor, err := invoiceModel.Aggregate(ctx, pipeline)
+		if err != nil {
+			log.Printf("Error aggregating sales by server: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error building report"})
+			return
+		}
+		var sales []ServerSales
+		if err := cursor.All(ctx, &sales); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding report"})
+			return
+		}
+
+		serverIds := make([]string, 0, len(sales))
+		for _, row := range sales {
+			serverIds = append(serverIds, row.Server_Id)
+		}
+		cursor, err = userModel.Find(ctx, bson.M{"user_id": bson.M{"$in": serverIds}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching servers"})
+			return
+		}
+		var users []models.User
+		if err := cursor.All(ctx, &users); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding servers"})
+			return
+		}
+		names := map[string]string{}
+		for _, user := range users {
+			names[user.User_Id] = fmt.Sprintf("%s %s", derefString(user.First_Name), derefString(user.Last_Name))
+		}
+		for i := range sales {
+			sales[i].Name = names[sales[i].Server_Id]
+			sales[i].Sub_Total = toFixed(sales[i].Sub_Total, 2)
+			sales[i].Total_Amount = toFixed(sales[i].Total_Amount, 2)
+			sales[i].Paid_Amount = toFixed(sales[i].Paid_Amount, 2)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "data": sales})
+	}
+}
+
+type CashierOverShort struct {
+	Cashier_Id     string  `bson:"_id" json:"cashier_id"`
+	Name           string  `bson:"-" json:"name"`
+	Sessions       int     `bson:"sessions" json:"sessions"`
+	Expected_Total float64 `bson:"expected_total" json:"expected_total"`
+	Counted_Total  float64 `bson:"counted_total" json:"counted_total"`
+	Over_Short     float64 `bson:"over_short" json:"over_short"`
+	Worst_Short    float64 `bson:"worst_short" json:"worst_short"`
+}
+
+// GetOverShort totals the closed cash drawers per cashier for a from/to date
+// range, the current pay period by default. A negative over_short means the
+// drawers came up short.
+func GetOverShort() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		from, to, err := parsePeriod(c.Query("from"), c.Query("to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{
+				"status":    "CLOSED",
+				"closed_at": bson.M{"$gte": from, "$lt": to},
+			}}},
+			{{Key: "$group", Value: bson.M{
+				"_id":            "$cashier_id",
+				"sessions":       bson.M{"$sum": 1},
+				"expected_total": bson.M{"$sum": "$expected_total"},
+				"counted_total":  bson.M{"$sum": "$counted_total"},
+				"over_short":     bson.M{"$sum": "$over_short"},
+				"worst_short":    bson.M{"$min": "$over_short"},
+			}}},
+			{{Key: "$sort", Value: bson.M{"over_short": 1}}},
+		}
+
+		cursor, err := drawerSessionModel.Aggregate(ctx, pipeline)
+		if err != nil {
+			log.Printf("Error aggregating over/short: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error building report"})
+			return
+		}
+		rows := []CashierOverShort{}
+		if err := cursor.All(ctx, &rows); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding report"})
+			return
+		}
+
+		cashierIds := make([]string, 0, len(rows))
+		for _, row := range rows {
+			cashierIds = append(cashierIds, row.Cashier_Id)
+		}
+		cursor, err = userModel.Find(ctx, bson.M{"user_id": bson.M{"$in": cashierIds}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching cashiers"})
+			return
+		}
+		var users []models.User
+		if err := cursor.All(ctx, &users); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding cashiers"})
+			return
+		}
+		names := map[string]string{}
+		for _, user := range users {
+			names[user.User_Id] = fmt.Sprintf("%s %s", derefString(user.First_Name), derefString(user.Last_Name))
+		}
+		for i := range rows {
+			rows[i].Name = names[rows[i].Cashier_Id]
+			rows[i].Expected_Total = toFixed(rows[i].Expected_Total, 2)
+			rows[i].Counted_Total = toFixed(rows[i].Counted_Total, 2)
+			rows[i].Over_Short = toFixed(rows[i].Over_Short, 2)
+			rows[i].Worst_Short = toFixed(rows[i].Worst_Short, 2)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "data": rows})
+	}
+}
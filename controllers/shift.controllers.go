@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var shiftModel *mongo.Collection = database.OpenCollection(database.MongoClient, "shift")
+
+var errShiftOverlap = errors.New("the user already has a shift at that time")
+
+// GetShifts lists scheduled shifts, filtered by user_id, role and a from/to
+// date range on the start time that defaults to the current pay period.
+func GetShifts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if userId := c.Query("user_id"); userId != "" {
+			filter["user_id"] = userId
+		}
+		if role := c.Query("role"); role != "" {
+			filter["role"] = strings.ToUpper(role)
+		}
+
+		from, to, err := parsePeriod(c.Query("from"), c.Query("to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter["start_time"] = bson.M{"$gte": from, "$lt": to}
+
+		cursor, err := shiftModel.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "start_time", Value: 1}}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching shifts"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var shifts []models.Shift
+		if err := cursor.All(ctx, &shifts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding shifts"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "data": shifts})
+	}
+}
+
+func CreateShift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var shift models.Shift
+		if err := c.ShouldBindJSON(&shift); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if shift.Role != nil {
+			role := strings.ToUpper(*shift.Role)
+			shift.Role = &role
+		}
+		if err := validate.Struct(shift); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		count, err := userModel.CountDocuments(ctx, bson.M{"user_id": *shift.User_Id, "active": bson.M{"$ne": false}})
+		if err != nil || count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if err := checkShiftOverlap(ctx, *shift.User_Id, *shift.Start_Time, *shift.End_Time, ""); err != nil {
+			writeShiftError(c, err)
+			return
+		}
+
+		now := time.Now().UTC()
+		shift.ID = primitive.NewObjectID()
+		shift.Shift_Id = shift.ID.Hex()
+		shift.Created_At = now
+		shift.Updated_At = now
+
+		if _, err := shiftModel.InsertOne(ctx, shift); err != nil {
+			log.Printf("Error creating shift: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shift"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Shift created", "data": shift})
+	}
+}
+
+func UpdateShift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		shiftId := c.Param("shift_id")
+
+		var existing models.Shift
+		if err := shiftModel.FindOne(ctx, bson.M{"shift_id": shiftId}).Decode(&existing); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Shift not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching shift"})
+			return
+		}
+
+		var shift models.Shift
+		if err := c.ShouldBindJSON(&shift); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		// Validate the shift as it will be after the change.
+		merged := existing
+		if shift.User_Id != nil {
+			merged.User_Id = shift.User_Id
+		}
+		if shift.Role != nil {
+			role := strings.ToUpper(*shift.Role)
+			merged.Role = &role
+		}
+		if shift.Start_Time != nil {
+			merged.Start_Time = shift.Start_Time
+		}
+		if shift.End_Time != nil {
+			merged.End_Time = shift.End_Time
+		}
+		if shift.Notes != nil {
+			merged.Notes = shift.Notes
+		}
+		if err := validate.Struct(merged); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		if err := checkShiftOverlap(ctx, *merged.User_Id, *merged.Start_Time, *merged.End_Time, shiftId); err != nil {
+			writeShiftError(c, err)
+			return
+		}
+
+		_, err := shiftModel.UpdateOne(ctx,
+			bson.M{"shift_id": shiftId},
+			bson.M{"$set": bson.M{
+				"user_id":    merged.User_Id,
+				"role":       merged.Role,
+				"start_time": merged.Start_Time,
+				"end_time":   merged.End_Time,
+				"notes":      merged.Notes,
+				"updated_at": time.Now().UTC(),
+			}},
+		)
+		if err != nil {
+			log.Printf("Error updating shift (id=%s): %v", shiftId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update shift"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Shift updated"})
+	}
+}
+
+func DeleteShift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		result, err := shiftModel.DeleteOne(ctx, bson.M{"shift_id": c.Param("shift_id")})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete shift"})
+			return
+		}
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shift not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Shift deleted"})
+	}
+}
+
+func checkShiftOverlap(ctx context.Context, userId string, start, end time.Time, exceptShiftId string) error {
+	filter := bson.M{
+		"user_id":    userId,
+		"start_time": bson.M{"$lt": end},
+		"end_time":   bson.M{"$gt": start},
+	}
+	if exceptShiftId != "" {
+		filter["shift_id"] = bson.M{"$ne": exceptShiftId}
+	}
+
+	count, err := shiftModel.CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errShiftOverlap
+	}
+	return nil
+}
+
+func writeShiftError(c *gin.Context, err error) {
+	if errors.Is(err, errShiftOverlap) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking shifts"})
+}
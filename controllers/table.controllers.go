@@ -2,11 +2,13 @@ package controllers
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
 	"github.com/djwhocodes/restaurant_management/models"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -105,7 +107,12 @@ func CreateTable() gin.HandlerFunc {
 		table.Updated_At = time.Now()
 		table.Table_Id = table.ID.Hex()
 
-		_, err := tableModel.InsertOne(ctx, table)
+		_, err := database.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+			if _, txErr := tableModel.InsertOne(sc, table); txErr != nil {
+				return txErr
+			}
+			return helpers.RecordEvent(sc, "table.created", table.Table_Id, table)
+		})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating table"})
 			return
@@ -142,11 +149,16 @@ func UpdateTable() gin.HandlerFunc {
 			updateData["table_number"] = table.Table_Number
 		}
 
-		result, err := tableModel.UpdateOne(
-			ctx,
-			bson.M{"table_id": tableId},
-			bson.M{"$set": updateData},
-		)
+		filter := bson.M{"table_id": tableId}
+
+		var result *mongo.UpdateResult
+		_, err := database.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+			var txErr error
+			if result, txErr = tableModel.UpdateOne(sc, filter, bson.M{"$set": updateData}); txErr != nil || result.MatchedCount == 0 {
+				return txErr
+			}
+			return recordDocumentEvent(sc, "table.updated", tableId, tableModel, filter)
+		})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating table"})
 			return
@@ -160,3 +172,32 @@ func UpdateTable() gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "Table updated successfully"})
 	}
 }
+
+// RequestBill lets the floor know a table is ready to pay.
+func RequestBill() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		tableId := c.Param("table_id")
+
+		var table models.Table
+		if err := tableModel.FindOne(ctx, bson.M{"table_id": tableId}).Decode(&table); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+			return
+		}
+
+		err := helpers.RecordEvent(ctx, "table.bill_requested", tableId, gin.H{
+			"table_id":     table.Table_Id,
+			"table_number": table.Table_Number,
+			"requested_at": time.Now().UTC(),
+		})
+		if err != nil {
+			log.Printf("Error recording bill request for table %s: %v", tableId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request the bill"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Bill requested"})
+	}
+}
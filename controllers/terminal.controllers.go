@@ -0,0 +1,371 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var terminalModel *mongo.Collection = database.OpenCollection(database.MongoClient, "terminal")
+
+type setPinRequest struct {
+	Password string `json:"password" validate:"required"`
+	Pin      string `json:"pin" validate:"required,numeric,min=4,max=6"`
+}
+
+// pinLoginRequest also carries a TOTP or recovery code for users with 2FA
+// switched on.
+type pinLoginRequest struct {
+	User_Id       string `json:"user_id" validate:"required"`
+	Pin           string `json:"pin" validate:"required,numeric,min=4,max=6"`
+	Code          string `json:"code"`
+	Recovery_Code string `json:"recovery_code"`
+}
+
+func pinMaxAttempts() int {
+	return helpers.EnvInt("PIN_MAX_ATTEMPTS", 5)
+}
+
+func pinLockout() time.Duration {
+	return time.Duration(helpers.EnvInt("PIN_LOCKOUT_MINUTES", 15)) * time.Minute
+}
+
+// RegisterTerminal creates a terminal and returns its key. The key is only
+// shown here; a lost key means registering the device again.
+func RegisterTerminal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var terminal models.Terminal
+		if err := c.ShouldBindJSON(&terminal); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(terminal); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		key, err := newSecretToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate terminal key"})
+			return
+		}
+
+		active := true
+		now := time.Now().UTC()
+		terminal.ID = primitive.NewObjectID()
+		terminal.Terminal_Id = terminal.ID.Hex()
+		terminal.Key_Hash = helpers.HashTerminalKey(key)
+		terminal.Active = &active
+		terminal.Session = nil
+		terminal.Last_Seen_At = nil
+		terminal.Created_At = now
+		terminal.Updated_At = now
+
+		if _, err := terminalModel.InsertOne(ctx, terminal); err != nil {
+			log.Printf("Error registering terminal: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register terminal"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":      "Terminal registered, store the key on the device",
+			"data":         terminal,
+			"terminal_key": key,
+		})
+	}
+}
+
+func GetTerminals() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		cursor, err := terminalModel.Find(ctx, bson.M{}, options.Find().
+			SetSort(bson.D{{Key: "name", Value: 1}}).
+			SetProjection(bson.M{"key_hash": 0}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching terminals"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var terminals []models.Terminal
+		if err := cursor.All(ctx, &terminals); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding terminals"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": terminals})
+	}
+}
+
+// DeactivateTerminal stops a lost or retired device from being used and
+// ends its session.
+func DeactivateTerminal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		result, err := terminalModel.UpdateOne(ctx,
+			bson.M{"terminal_id": c.Param("terminal_id")},
+			bson.M{"$set": bson.M{"active": false, "session": nil, "updated_at": time.Now().UTC()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate terminal"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Terminal not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Terminal deactivated"})
+	}
+}
+
+// GetTerminalStaff lists the users who can sign in with a PIN, for the
+// terminal's user picker.
+func GetTerminalStaff() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		cursor, err := userModel.Find(ctx,
+			bson.M{"pin_hash": bson.M{"$type": "string"}, "active": bson.M{"$ne": false}},
+			options.Find().
+				SetSort(bson.D{{Key: "first_name", Value: 1}, {Key: "last_name", Value: 1}}).
+				SetProjection(bson.M{"_id": 0, "user_id": 1, "first_name": 1, "last_name": 1, "role": 1}),
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching staff"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var staff []bson.M
+		if err := cursor.All(ctx, &staff); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding staff"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": staff})
+	}
+}
+
+// SetPin sets the signed-in user's PIN, confirmed with their password.
+func SetPin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var request setPinRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "PINs are 4 to 6 digits", "details": err.Error()})
+			return
+		}
+
+		var user models.User
+		if err := userModel.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+		if valid, _ := VerifyPassword(request.Password, *user.Password); !valid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+			return
+		}
+
+		_, err := userModel.UpdateOne(ctx,
+			bson.M{"user_id": user.User_Id},
+			bson.M{
+				"$set":   bson.M{"pin_hash": HashPassword(request.Pin), "pin_failures": 0, "updated_at": time.Now()},
+				"$unset": bson.M{"pin_locked_until": ""},
+			},
+		)
+		if err != nil {
+			log.Printf("Error setting PIN of user %s: %v", user.User_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set PIN"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "PIN set"})
+	}
+}
+
+// PinLogin signs a user in on a registered terminal. It replaces whoever
+// was signed in there before, which is how staff switch users, and returns
+// an access token that only works on this terminal while the session is
+// active. Repeated wrong PINs lock the user's PIN for a while. Users with
+// 2FA must send a code along with the PIN, and users whose role requires
+// 2FA must have enrolled with a password login first.
+func PinLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var request pinLoginRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		var user models.User
+		err := userModel.FindOne(ctx, bson.M{"user_id": request.User_Id}).Decode(&user)
+		if err != nil || user.Pin_Hash == nil || !user.IsActive() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user or PIN"})
+			return
+		}
+
+		now := time.Now().UTC()
+		if user.Pin_Locked_Until != nil && user.Pin_Locked_Until.After(now) {
+			retryAfter := int(user.Pin_Locked_Until.Sub(now).Seconds()) + 1
+			c.Header("Retry-After", fmt.Sprint(retryAfter))
+			c.JSON(http.StatusLocked, gin.H{"error": "Too many wrong PINs, try again later or sign in with your password", "retry_after": retryAfter})
+			return
+		}
+
+		if valid, _ := VerifyPassword(request.Pin, *user.Pin_Hash); !valid {
+			remaining, err := recordPinFailure(ctx, user.User_Id)
+			if err != nil {
+				log.Printf("Error recording PIN failure of user %s: %v", user.User_Id, err)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user or PIN", "attempts_remaining": remaining})
+			return
+		}
+
+		if !user.Totp_Enabled && requiresTwoFactor(user) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role, sign in with your password to enrol"})
+			return
+		}
+		if user.Totp_Enabled {
+			if request.Code == "" && request.Recovery_Code == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor code required", "two_factor_required": true})
+				return
+			}
+			if err := verifySecondFactor(ctx, user, request.Code, request.Recovery_Code); err != nil {
+				if errors.Is(err, errInvalidTotpCode) {
+					if _, err := recordPinFailure(ctx, user.User_Id); err != nil {
+						log.Printf("Error recording PIN failure of user %s: %v", user.User_Id, err)
+					}
+				}
+				writeSecondFactorError(c, err)
+				return
+			}
+		}
+
+		if user.Pin_Failures > 0 {
+			userModel.UpdateOne(ctx, bson.M{"user_id": user.User_Id}, bson.M{"$set": bson.M{"pin_failures": 0}})
+		}
+
+		terminalId := c.GetString("terminal_id")
+		sessionId, err := newSecretToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+			return
+		}
+		session := models.TerminalSession{
+			Session_Id:       sessionId,
+			User_Id:          user.User_Id,
+			Started_At:       now,
+			Last_Activity_At: now,
+		}
+		_, err = terminalModel.UpdateOne(ctx,
+			bson.M{"terminal_id": terminalId, "active": true},
+			bson.M{"$set": bson.M{"session": session, "last_seen_at": now, "updated_at": now}},
+		)
+		if err != nil {
+			log.Printf("Error starting session on terminal %s: %v", terminalId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+			return
+		}
+
+		token, expiresAt, err := helpers.GenerateTerminalToken(*user.Email, *user.First_Name, *user.Last_Name, user.User_Id, terminalId, sessionId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":              "Login successful",
+			"token":                token,
+			"expires_at":           expiresAt,
+			"idle_timeout_seconds": int(helpers.TerminalIdleTimeout().Seconds()),
+			"user": gin.H{
+				"user_id":    user.User_Id,
+				"first_name": user.First_Name,
+				"last_name":  user.Last_Name,
+				"role":       user.Role,
+			},
+		})
+	}
+}
+
+// LockTerminal ends the terminal's session, so the next person has to
+// enter their PIN.
+func LockTerminal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		_, err := terminalModel.UpdateOne(ctx,
+			bson.M{"terminal_id": c.GetString("terminal_id")},
+			bson.M{"$set": bson.M{"session": nil, "updated_at": time.Now().UTC()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock terminal"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Terminal locked"})
+	}
+}
+
+// recordPinFailure counts a wrong PIN and locks the PIN once the limit is
+// reached. It returns the attempts left before the lock.
+func recordPinFailure(ctx context.Context, userId string) (int, error) {
+	var user models.User
+	err := userModel.FindOneAndUpdate(ctx,
+		bson.M{"user_id": userId},
+		bson.M{"$inc": bson.M{"pin_failures": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err != nil {
+		return 0, err
+	}
+
+	remaining := pinMaxAttempts() - user.Pin_Failures
+	if remaining > 0 {
+		return remaining, nil
+	}
+
+	_, err = userModel.UpdateOne(ctx,
+		bson.M{"user_id": userId},
+		bson.M{"$set": bson.M{"pin_failures": 0, "pin_locked_until": time.Now().UTC().Add(pinLockout())}},
+	)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, err
+	}
+	return 0, nil
+}
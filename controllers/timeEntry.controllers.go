@@ -0,0 +1,487 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var timeEntryModel *mongo.Collection = database.OpenCollection(database.MongoClient, "time_entry")
+
+// shiftMatchWindow is how early or late a clock-in may be and still count
+// against a scheduled shift.
+const shiftMatchWindow = time.Hour
+
+type updateTimeEntryRequest struct {
+	Clock_In  *time.Time `json:"clock_in"`
+	Clock_Out *time.Time `json:"clock_out"`
+}
+
+type TimesheetRow struct {
+	User_Id         string             `json:"user_id"`
+	Name            string             `json:"name"`
+	Role            string             `json:"role"`
+	Scheduled_Hours float64            `json:"scheduled_hours"`
+	Worked_Hours    float64            `json:"worked_hours"`
+	Break_Hours     float64            `json:"break_hours"`
+	Entries         []models.TimeEntry `json:"entries"`
+}
+
+// ClockIn opens a time entry for the signed-in user, linked to the
+// scheduled shift it falls in if there is one.
+func ClockIn() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		userId := c.GetString("uid")
+		now := time.Now().UTC()
+
+		entry := models.TimeEntry{
+			ID:         primitive.NewObjectID(),
+			User_Id:    userId,
+			Status:     "OPEN",
+			Clock_In:   now,
+			Breaks:     []models.TimeEntryBreak{},
+			Created_At: now,
+			Updated_At: now,
+		}
+		entry.Time_Entry_Id = entry.ID.Hex()
+		if terminalId := c.GetString("terminal_id"); terminalId != "" {
+			entry.Terminal_Id = &terminalId
+		}
+
+		var shift models.Shift
+		err := shiftModel.FindOne(ctx, bson.M{
+			"user_id":    userId,
+			"start_time": bson.M{"$lte": now.Add(shiftMatchWindow)},
+			"end_time":   bson.M{"$gt": now},
+		}, options.FindOne().SetSort(bson.D{{Key: "start_time", Value: 1}})).Decode(&shift)
+		if err == nil {
+			entry.Shift_Id = &shift.Shift_Id
+		}
+
+		// A unique index on open entries stops double clock-ins.
+		if _, err := timeEntryModel.InsertOne(ctx, entry); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "You are already clocked in"})
+				return
+			}
+			log.Printf("Error clocking in user %s: %v", userId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clock in"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Clocked in", "data": entry})
+	}
+}
+
+// ClockOut closes the signed-in user's time entry, ending any break.
+func ClockOut() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		entry, ok := openTimeEntry(ctx, c)
+		if !ok {
+			return
+		}
+
+		now := time.Now().UTC()
+		for i := range entry.Breaks {
+			if entry.Breaks[i].End == nil {
+				entry.Breaks[i].End = &now
+			}
+		}
+		entry.Clock_Out = &now
+		entry.Status = "CLOSED"
+		entry.Break_Minutes, entry.Worked_Minutes = entry.Minutes(now)
+
+		result, err := timeEntryModel.UpdateOne(ctx,
+			bson.M{"time_entry_id": entry.Time_Entry_Id, "status": "OPEN"},
+			bson.M{"$set": bson.M{
+				"status":         entry.Status,
+				"clock_out":      entry.Clock_Out,
+				"breaks":         entry.Breaks,
+				"break_minutes":  entry.Break_Minutes,
+				"worked_minutes": entry.Worked_Minutes,
+				"updated_at":     now,
+			}},
+		)
+		if err != nil {
+			log.Printf("Error clocking out user %s: %v", entry.User_Id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clock out"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "You are not clocked in"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Clocked out", "data": entry})
+	}
+}
+
+func StartBreak() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		entry, ok := openTimeEntry(ctx, c)
+		if !ok {
+			return
+		}
+		if helpers.OnBreak(entry) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You are already on a break"})
+			return
+		}
+
+		now := time.Now().UTC()
+		_, err := timeEntryModel.UpdateOne(ctx,
+			bson.M{"time_entry_id": entry.Time_Entry_Id, "status": "OPEN"},
+			bson.M{
+				"$push": bson.M{"breaks": models.TimeEntryBreak{Start: now}},
+				"$set":  bson.M{"updated_at": now},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start break"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Break started", "started_at": now})
+	}
+}
+
+func EndBreak() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		entry, ok := openTimeEntry(ctx, c)
+		if !ok {
+			return
+		}
+
+		now := time.Now().UTC()
+		result, err := timeEntryModel.UpdateOne(ctx,
+			bson.M{"time_entry_id": entry.Time_Entry_Id, "status": "OPEN", "breaks.end": nil},
+			bson.M{"$set": bson.M{"breaks.$.end": now, "updated_at": now}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end break"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "You are not on a break"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Break ended", "ended_at": now})
+	}
+}
+
+// GetClockStatus shows whether the signed-in user is clocked in, on a
+// break, and for how long.
+func GetClockStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		entry, err := helpers.OpenTimeEntry(ctx, c.GetString("uid"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching clock status"})
+			return
+		}
+		if entry == nil {
+			c.JSON(http.StatusOK, gin.H{"clocked_in": false})
+			return
+		}
+
+		entry.Break_Minutes, entry.Worked_Minutes = entry.Minutes(time.Now().UTC())
+		c.JSON(http.StatusOK, gin.H{
+			"clocked_in": true,
+			"on_break":   helpers.OnBreak(entry),
+			"data":       entry,
+		})
+	}
+}
+
+// UpdateTimeEntry lets a manager correct clock times, for example when
+// someone forgot to clock out. Setting clock_out closes the entry.
+func UpdateTimeEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		entryId := c.Param("time_entry_id")
+
+		var request updateTimeEntryRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		var entry models.TimeEntry
+		if err := timeEntryModel.FindOne(ctx, bson.M{"time_entry_id": entryId}).Decode(&entry); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Time entry not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching time entry"})
+			return
+		}
+
+		if request.Clock_In != nil {
+			entry.Clock_In = request.Clock_In.UTC()
+		}
+		if request.Clock_Out != nil {
+			clockOut := request.Clock_Out.UTC()
+			entry.Clock_Out = &clockOut
+			entry.Status = "CLOSED"
+			for i := range entry.Breaks {
+				if entry.Breaks[i].End == nil || entry.Breaks[i].End.After(clockOut) {
+					entry.Breaks[i].End = &clockOut
+				}
+			}
+		}
+		if entry.Clock_Out != nil && !entry.Clock_Out.After(entry.Clock_In) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "clock_out must be after clock_in"})
+			return
+		}
+
+		now := time.Now().UTC()
+		if entry.Status == "CLOSED" {
+			entry.Break_Minutes, entry.Worked_Minutes = entry.Minutes(now)
+		}
+		editor := c.GetString("uid")
+		entry.Edited_By = &editor
+
+		_, err := timeEntryModel.UpdateOne(ctx,
+			bson.M{"time_entry_id": entryId},
+			bson.M{"$set": bson.M{
+				"clock_in":       entry.Clock_In,
+				"clock_out":      entry.Clock_Out,
+				"status":         entry.Status,
+				"breaks":         entry.Breaks,
+				"break_minutes":  entry.Break_Minutes,
+				"worked_minutes": entry.Worked_Minutes,
+				"edited_by":      entry.Edited_By,
+				"updated_at":     now,
+			}},
+		)
+		if err != nil {
+			log.Printf("Error updating time entry (id=%s): %v", entryId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update time entry"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Time entry updated", "data": entry})
+	}
+}
+
+// GetTimesheets totals scheduled and worked hours per user for a pay
+// period, the current one unless from and to are given. ?format=csv
+// exports one row per time entry for payroll.
+func GetTimesheets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		from, to, err := parsePeriod(c.Query("from"), c.Query("to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := buildTimesheets(ctx, from, to, c.Query("user_id"))
+		if err != nil {
+			log.Printf("Error building timesheets: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error building timesheets"})
+			return
+		}
+
+		if c.Query("format") == "csv" {
+			writeTimesheetCSV(c, from, to, rows)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "data": rows})
+	}
+}
+
+func buildTimesheets(ctx context.Context, from, to time.Time, userId string) ([]*TimesheetRow, error) {
+	entryFilter := bson.M{"clock_in": bson.M{"$gte": from, "$lt": to}}
+	shiftFilter := bson.M{"start_time": bson.M{"$gte": from, "$lt": to}}
+	if userId != "" {
+		entryFilter["user_id"] = userId
+		shiftFilter["user_id"] = userId
+	}
+
+	cursor, err := timeEntryModel.Find(ctx, entryFilter, options.Find().SetSort(bson.D{{Key: "clock_in", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var entries []models.TimeEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	cursor, err = shiftModel.Find(ctx, shiftFilter)
+	if err != nil {
+		return nil, err
+	}
+	var shifts []models.Shift
+	if err := cursor.All(ctx, &shifts); err != nil {
+		return nil, err
+	}
+
+	byUser := map[string]*TimesheetRow{}
+	row := func(userId string) *TimesheetRow {
+		if byUser[userId] == nil {
+			byUser[userId] = &TimesheetRow{User_Id: userId, Entries: []models.TimeEntry{}}
+		}
+		return byUser[userId]
+	}
+
+	now := time.Now().UTC()
+	for _, entry := range entries {
+		if entry.Status == "OPEN" {
+			entry.Break_Minutes, entry.Worked_Minutes = entry.Minutes(now)
+		}
+		r := row(entry.User_Id)
+		r.Entries = append(r.Entries, entry)
+		r.Worked_Hours += entry.Worked_Minutes / 60
+		r.Break_Hours += entry.Break_Minutes / 60
+	}
+	for _, shift := range shifts {
+		r := row(*shift.User_Id)
+		r.Scheduled_Hours += shift.End_Time.Sub(*shift.Start_Time).Hours()
+	}
+
+	userIds := make([]string, 0, len(byUser))
+	for id := range byUser {
+		userIds = append(userIds, id)
+	}
+	cursor, err = userModel.Find(ctx, bson.M{"user_id": bson.M{"$in": userIds}})
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		r := byUser[user.User_Id]
+		r.Name = fmt.Sprintf("%s %s", derefString(user.First_Name), derefString(user.Last_Name))
+		r.Role = derefString(user.Role)
+	}
+
+	rows := make([]*TimesheetRow, 0, len(byUser))
+	for _, r := range byUser {
+		r.Worked_Hours = roundHours(r.Worked_Hours)
+		r.Break_Hours = roundHours(r.Break_Hours)
+		r.Scheduled_Hours = roundHours(r.Scheduled_Hours)
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+	return rows, nil
+}
+
+func writeTimesheetCSV(c *gin.Context, from, to time.Time, rows []*TimesheetRow) {
+	filename := fmt.Sprintf("timesheet-%s-%s.csv", from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"user_id", "name", "role", "date", "clock_in", "clock_out", "break_minutes", "worked_minutes", "shift_id", "status"})
+	for _, r := range rows {
+		for _, entry := range r.Entries {
+			clockOut := ""
+			if entry.Clock_Out != nil {
+				clockOut = entry.Clock_Out.Local().Format(time.RFC3339)
+			}
+			w.Write([]string{
+				r.User_Id,
+				r.Name,
+				r.Role,
+				entry.Clock_In.Local().Format("2006-01-02"),
+				entry.Clock_In.Local().Format(time.RFC3339),
+				clockOut,
+				strconv.FormatFloat(entry.Break_Minutes, 'f', 0, 64),
+				strconv.FormatFloat(entry.Worked_Minutes, 'f', 0, 64),
+				derefString(entry.Shift_Id),
+				entry.Status,
+			})
+		}
+	}
+	w.Flush()
+}
+
+// parsePeriod reads an inclusive from/to date range in server local time
+// and returns it as [from, to). Without dates it is the current pay
+// period: PAY_PERIOD_DAYS (default 14) long, counted from
+// PAY_PERIOD_START (default 2024-01-01).
+func parsePeriod(fromValue, toValue string) (time.Time, time.Time, error) {
+	if fromValue == "" && toValue == "" {
+		anchor, err := time.ParseInLocation("2006-01-02", helpers.EnvString("PAY_PERIOD_START", "2024-01-01"), time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("PAY_PERIOD_START must be a date like 2024-01-01")
+		}
+		days := helpers.EnvInt("PAY_PERIOD_DAYS", 14)
+		if days < 1 {
+			days = 14
+		}
+		elapsed := int(time.Since(anchor).Hours() / 24)
+		from := anchor.AddDate(0, 0, elapsed-elapsed%days)
+		if elapsed < 0 {
+			from = anchor
+		}
+		return from, from.AddDate(0, 0, days), nil
+	}
+
+	from, err := time.ParseInLocation("2006-01-02", fromValue, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("from must be a date like 2024-01-31")
+	}
+	to, err := time.ParseInLocation("2006-01-02", toValue, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("to must be a date like 2024-01-31")
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("to must not be before from")
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+func roundHours(hours float64) float64 {
+	return float64(int(hours*100+0.5)) / 100
+}
+
+func openTimeEntry(ctx context.Context, c *gin.Context) (*models.TimeEntry, bool) {
+	entry, err := helpers.OpenTimeEntry(ctx, c.GetString("uid"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching clock status"})
+		return nil, false
+	}
+	if entry == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are not clocked in"})
+		return nil, false
+	}
+	return entry, true
+}
@@ -0,0 +1,635 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var tipPoolModel *mongo.Collection = database.OpenCollection(database.MongoClient, "tip_pool")
+
+// AddInvoiceTip records a tip that didn't go through a card payment here,
+// usually cash left on the table.
+func AddInvoiceTip() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+
+		var body struct {
+			Amount float64 `json:"amount" validate:"gt=0"`
+			Method string  `json:"method" validate:"omitempty,eq=CASH|eq=CARD"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+		if body.Method == "" {
+			body.Method = "CASH"
+		}
+
+		var invoice models.Invoice
+		if err := invoiceModel.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching invoice"})
+			return
+		}
+		if invoice.Payment_Status != nil && *invoice.Payment_Status == "REFUNDED" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Invoice has been refunded"})
+			return
+		}
+
+		tip := models.InvoiceTip{
+			Amount:    toFixed(body.Amount, 2),
+			Method:    body.Method,
+			Server_Id: tipServer(ctx, &invoice),
+			At:        time.Now().UTC(),
+		}
+		if uid := c.GetString("uid"); uid != "" {
+			tip.Recorded_By = &uid
+		}
+
+		_, err := invoiceModel.UpdateOne(ctx,
+			bson.M{"invoice_id": invoiceId},
+			bson.M{
+				"$push": bson.M{"tips": tip},
+				"$inc":  bson.M{"tip_amount": tip.Amount},
+				"$set":  bson.M{"updated_at": tip.At},
+			},
+		)
+		if err != nil {
+			log.Printf("Error recording tip on invoice %s: %v", invoiceId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record tip"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Tip recorded successfully", "data": tip})
+	}
+}
+
+// tipServer returns the user a tip on the invoice belongs to: the server
+// on the invoice, or on its order for invoices raised before servers were
+// tracked.
+func tipServer(ctx context.Context, invoice *models.Invoice) *string {
+	if invoice.Server_Id != nil {
+		return invoice.Server_Id
+	}
+	var order models.Order
+	if err := orderModel.FindOne(ctx, bson.M{"order_id": invoice.Order_Id}).Decode(&order); err != nil {
+		return nil
+	}
+	return order.Server_Id
+}
+
+// recordCardTip adds a captured payment's tip to its invoice. It is safe to
+// call again for the same payment, e.g. when a webhook repeats a capture.
+func recordCardTip(ctx context.Context, payment *models.Payment) {
+	if payment.Tip_Amount <= 0 {
+		return
+	}
+
+	var invoice models.Invoice
+	if err := invoiceModel.FindOne(ctx, bson.M{"invoice_id": payment.Invoice_Id}).Decode(&invoice); err != nil {
+		return
+	}
+
+	paymentId := payment.Payment_Id
+	tip := models.InvoiceTip{
+		Amount:     payment.Tip_Amount,
+		Method:     "CARD",
+		Payment_Id: &paymentId,
+		Server_Id:  tipServer(ctx, &invoice),
+		At:         time.Now().UTC(),
+	}
+	_, err := invoiceModel.UpdateOne(ctx,
+		bson.M{"invoice_id": payment.Invoice_Id, "tips.payment_id": bson.M{"$ne": paymentId}},
+		bson.M{
+			"$push": bson.M{"tips": tip},
+			"$inc":  bson.M{"tip_amount": tip.Amount},
+			"$set":  bson.M{"updated_at": tip.At},
+		},
+	)
+	if err != nil {
+		log.Printf("Error recording tip for payment %s: %v", paymentId, err)
+	}
+}
+
+// removeCardTip takes a fully refunded payment's tip back off its invoice.
+func removeCardTip(ctx context.Context, payment *models.Payment) {
+	if payment.Tip_Amount <= 0 {
+		return
+	}
+
+	_, err := invoiceModel.UpdateOne(ctx,
+		bson.M{"invoice_id": payment.Invoice_Id, "tips.payment_id": payment.Payment_Id},
+		bson.M{
+			"$pull": bson.M{"tips": bson.M{"payment_id": payment.Payment_Id}},
+			"$inc":  bson.M{"tip_amount": -payment.Tip_Amount},
+			"$set":  bson.M{"updated_at": time.Now().UTC()},
+		},
+	)
+	if err != nil {
+		log.Printf("Error removing tip for payment %s: %v", payment.Payment_Id, err)
+	}
+}
+
+func GetTipPools() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		cursor, err := tipPoolModel.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		if err != nil {
+			log.Printf("Error fetching tip pools: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching tip pools"})
+			return
+		}
+
+		pools := []models.TipPool{}
+		if err := cursor.All(ctx, &pools); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding tip pools"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": pools})
+	}
+}
+
+func CreateTipPool() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var pool models.TipPool
+		if err := c.ShouldBindJSON(&pool); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(pool); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		if pool.Active == nil {
+			active := true
+			pool.Active = &active
+		}
+		if *pool.Active {
+			if ok := checkTipPoolPercent(ctx, c, "", *pool.Percent); !ok {
+				return
+			}
+		}
+
+		now := time.Now().UTC()
+		pool.ID = primitive.NewObjectID()
+		pool.Tip_Pool_Id = pool.ID.Hex()
+		pool.Created_At = now
+		pool.Updated_At = now
+
+		if _, err := tipPoolModel.InsertOne(ctx, pool); err != nil {
+			log.Printf("Error inserting tip pool: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tip pool"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Tip pool created successfully",
+			"data":    pool,
+		})
+	}
+}
+
+func UpdateTipPool() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		poolId := c.Param("tip_pool_id")
+
+		var current models.TipPool
+		if err := tipPoolModel.FindOne(ctx, bson.M{"tip_pool_id": poolId}).Decode(&current); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Tip pool not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching tip pool"})
+			return
+		}
+
+		var pool models.TipPool
+		if err := c.ShouldBindJSON(&pool); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		// Validate the pool as it will be once the update is applied.
+		merged := current
+		updateObj := bson.D{}
+		if pool.Name != nil {
+			merged.Name = pool.Name
+			updateObj = append(updateObj, bson.E{Key: "name", Value: *pool.Name})
+		}
+		if pool.Roles != nil {
+			merged.Roles = pool.Roles
+			updateObj = append(updateObj, bson.E{Key: "roles", Value: pool.Roles})
+		}
+		if pool.Percent != nil {
+			merged.Percent = pool.Percent
+			updateObj = append(updateObj, bson.E{Key: "percent", Value: *pool.Percent})
+		}
+		if pool.Split != nil {
+			merged.Split = pool.Split
+			updateObj = append(updateObj, bson.E{Key: "split", Value: *pool.Split})
+		}
+		if pool.Active != nil {
+			merged.Active = pool.Active
+			updateObj = append(updateObj, bson.E{Key: "active", Value: *pool.Active})
+		}
+		if err := validate.Struct(merged); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+		if merged.Active != nil && *merged.Active {
+			if ok := checkTipPoolPercent(ctx, c, poolId, *merged.Percent); !ok {
+				return
+			}
+		}
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: time.Now().UTC()})
+
+		result, err := tipPoolModel.UpdateOne(ctx, bson.M{"tip_pool_id": poolId}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			log.Printf("Error updating tip pool (id=%s): %v", poolId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tip pool"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tip pool not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Tip pool updated successfully"})
+	}
+}
+
+// checkTipPoolPercent rejects a pool that would have servers tip out more
+// than all of their tips across the active pools.
+func checkTipPoolPercent(ctx context.Context, c *gin.Context, exceptPoolId string, percent float64) bool {
+	pools, err := activeTipPools(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking tip pools"})
+		return false
+	}
+
+	total := percent
+	for _, pool := range pools {
+		if pool.Tip_Pool_Id != exceptPoolId {
+			total += *pool.Percent
+		}
+	}
+	if total > 100 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Active tip pools would take %.2f%% of tips, the most is 100%%", total)})
+		return false
+	}
+	return true
+}
+
+func activeTipPools(ctx context.Context) ([]models.TipPool, error) {
+	cursor, err := tipPoolModel.Find(ctx, bson.M{"active": true}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var pools []models.TipPool
+	if err := cursor.All(ctx, &pools); err != nil {
+		return nil, err
+	}
+	return pools, nil
+}
+
+type TipOutRow struct {
+	User_Id      string  `json:"user_id"`
+	Name         string  `json:"name"`
+	Role         string  `json:"role"`
+	Worked_Hours float64 `json:"worked_hours"`
+	Tips_Earned  float64 `json:"tips_earned"`
+	Tip_Out      float64 `json:"tip_out"`
+	Tip_In       float64 `json:"tip_in"`
+	Net_Tips     float64 `json:"net_tips"`
+}
+
+type tipCents struct {
+	earned, out, in int64
+}
+
+type TipPoolPayout struct {
+	Tip_Pool_Id string   `json:"tip_pool_id"`
+	Name        string   `json:"name"`
+	Percent     float64  `json:"percent"`
+	Split       string   `json:"split"`
+	Amount      float64  `json:"amount"`
+	Members     []string `json:"members"`
+}
+
+// GetTipOut works out the tip-out for one business day (?date=2024-01-31,
+// today by default). Each server's tips are attributed from their invoices,
+// each active pool takes its percentage from every server and shares it
+// among the staff in its roles who clocked in that day. A pool nobody
+// worked takes nothing.
+func GetTipOut() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		from, to, err := businessDay(c.Query("date"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		earned, err := tipsByServer(ctx, from, to)
+		if err != nil {
+			log.Printf("Error totalling tips: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error totalling tips"})
+			return
+		}
+		worked, err := minutesWorked(ctx, from, to)
+		if err != nil {
+			log.Printf("Error totalling hours worked: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error totalling hours worked"})
+			return
+		}
+		pools, err := activeTipPools(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching tip pools"})
+			return
+		}
+
+		// Everything is worked out in cents so the tip-outs add up exactly.
+		byUser := map[string]*TipOutRow{}
+		cents := map[string]*tipCents{}
+		row := func(userId string) *TipOutRow {
+			if byUser[userId] == nil {
+				byUser[userId] = &TipOutRow{User_Id: userId}
+				cents[userId] = &tipCents{}
+			}
+			return byUser[userId]
+		}
+
+		var unattributed, total int64
+		for serverId, amount := range earned {
+			total += amount
+			if serverId == "" {
+				unattributed = amount
+				continue
+			}
+			row(serverId)
+			cents[serverId].earned = amount
+		}
+		for userId, minutes := range worked {
+			row(userId).Worked_Hours = roundHours(minutes / 60)
+		}
+
+		roles, names, err := staffDetails(ctx, byUser)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching staff"})
+			return
+		}
+		for userId, r := range byUser {
+			r.Name = names[userId]
+			r.Role = roles[userId]
+		}
+
+		payouts := make([]TipPoolPayout, 0, len(pools))
+		for _, pool := range pools {
+			payout := TipPoolPayout{
+				Tip_Pool_Id: pool.Tip_Pool_Id,
+				Name:        derefString(pool.Name),
+				Percent:     *pool.Percent,
+				Split:       *pool.Split,
+				Members:     []string{},
+			}
+
+			var weights []float64
+			for userId, minutes := range worked {
+				if minutes > 0 && slices.Contains(pool.Roles, roles[userId]) {
+					payout.Members = append(payout.Members, userId)
+				}
+			}
+			sort.Strings(payout.Members)
+			for _, userId := range payout.Members {
+				if *pool.Split == "HOURS" {
+					weights = append(weights, worked[userId])
+				} else {
+					weights = append(weights, 1)
+				}
+			}
+			if len(payout.Members) == 0 {
+				payouts = append(payouts, payout)
+				continue
+			}
+
+			var amount int64
+			for serverId, earnedCents := range earned {
+				if serverId == "" {
+					continue
+				}
+				out := int64(math.Round(float64(earnedCents) * *pool.Percent / 100))
+				cents[serverId].out += out
+				amount += out
+			}
+			for i, share := range splitCents(amount, weights) {
+				cents[payout.Members[i]].in += share
+			}
+			payout.Amount = float64(amount) / 100
+			payouts = append(payouts, payout)
+		}
+
+		rows := make([]*TipOutRow, 0, len(byUser))
+		for userId, r := range byUser {
+			amounts := cents[userId]
+			r.Tips_Earned = float64(amounts.earned) / 100
+			r.Tip_Out = float64(amounts.out) / 100
+			r.Tip_In = float64(amounts.in) / 100
+			r.Net_Tips = float64(amounts.earned-amounts.out+amounts.in) / 100
+			rows = append(rows, r)
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+
+		c.JSON(http.StatusOK, gin.H{
+			"date":              from.Format("2006-01-02"),
+			"from":              from,
+			"to":                to,
+			"total_tips":        float64(total) / 100,
+			"unattributed_tips": float64(unattributed) / 100,
+			"pools":             payouts,
+			"data":              rows,
+		})
+	}
+}
+
+// tipsByServer totals the tips recorded in [from, to) per server, in cents.
+// Tips with no known server are totalled under "".
+func tipsByServer(ctx context.Context, from, to time.Time) (map[string]int64, error) {
+	inDay := bson.M{"$gte": from, "$lt": to}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tips.at": inDay, "payment_status": bson.M{"$ne": "REFUNDED"}}}},
+		{{Key: "$unwind", Value: "$tips"}},
+		{{Key: "$match", Value: bson.M{"tips.at": inDay}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    bson.M{"$ifNull": bson.A{"$tips.server_id", ""}},
+			"amount": bson.M{"$sum": "$tips.amount"},
+		}}},
+	}
+
+	cursor, err := invoiceModel.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var totals []struct {
+		Server_Id string  `bson:"_id"`
+		Amount    float64 `bson:"amount"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+
+	earned := map[string]int64{}
+	for _, t := range totals {
+		earned[t.Server_Id] = int64(math.Round(t.Amount * 100))
+	}
+	return earned, nil
+}
+
+// minutesWorked totals each user's worked minutes inside [from, to), from
+// the time entries that overlap it.
+func minutesWorked(ctx context.Context, from, to time.Time) (map[string]float64, error) {
+	cursor, err := timeEntryModel.Find(ctx, bson.M{
+		"clock_in": bson.M{"$lt": to},
+		"$or": bson.A{
+			bson.M{"status": "OPEN"},
+			bson.M{"clock_out": bson.M{"$gt": from}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var entries []models.TimeEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	worked := map[string]float64{}
+	for _, entry := range entries {
+		worked[entry.User_Id] += entry.WorkedMinutesBetween(from, to, now)
+	}
+	return worked, nil
+}
+
+// staffDetails looks up the current role and name of each user in rows.
+func staffDetails(ctx context.Context, rows map[string]*TipOutRow) (roles, names map[string]string, err error) {
+	userIds := make([]string, 0, len(rows))
+	for userId := range rows {
+		userIds = append(userIds, userId)
+	}
+
+	cursor, err := userModel.Find(ctx, bson.M{"user_id": bson.M{"$in": userIds}})
+	if err != nil {
+		return nil, nil, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, nil, err
+	}
+
+	roles = map[string]string{}
+	names = map[string]string{}
+	for _, user := range users {
+		roles[user.User_Id] = derefString(user.Role)
+		names[user.User_Id] = fmt.Sprintf("%s %s", derefString(user.First_Name), derefString(user.Last_Name))
+	}
+	return roles, names, nil
+}
+
+// splitCents divides amount by weights, handing the cents lost to rounding
+// to the largest remainders so the shares add up to amount.
+func splitCents(amount int64, weights []float64) []int64 {
+	var sum float64
+	for _, w := range weights {
+		sum += w
+	}
+	shares := make([]int64, len(weights))
+	if sum <= 0 {
+		return shares
+	}
+
+	remainders := make([]float64, len(weights))
+	left := amount
+	for i, w := range weights {
+		exact := float64(amount) * w / sum
+		shares[i] = int64(math.Floor(exact))
+		remainders[i] = exact - float64(shares[i])
+		left -= shares[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for i := 0; left > 0; i, left = i+1, left-1 {
+		shares[order[i%len(order)]]++
+	}
+	return shares
+}
+
+// businessDay returns the bounds of the business day on date, or of the
+// current one. Days roll over at BUSINESS_DAY_START_HOUR local time (4 by
+// default), so late service counts towards the night it started.
+func businessDay(value string) (time.Time, time.Time, error) {
+	startHour := helpers.EnvInt("BUSINESS_DAY_START_HOUR", 4)
+	if startHour < 0 || startHour > 23 {
+		startHour = 4
+	}
+
+	var day time.Time
+	if value == "" {
+		now := time.Now()
+		day = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		if now.Hour() < startHour {
+			day = day.AddDate(0, 0, -1)
+		}
+	} else {
+		var err error
+		day, err = time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("date must be a date like 2024-01-31")
+		}
+	}
+
+	from := time.Date(day.Year(), day.Month(), day.Day(), startHour, 0, 0, 0, time.Local)
+	to := time.Date(day.Year(), day.Month(), day.Day()+1, startHour, 0, 0, 0, time.Local)
+	return from, to, nil
+}
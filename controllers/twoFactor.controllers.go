@@ -0,0 +1,510 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	errTotpNotStarted  = errors.New("start two-factor enrolment first")
+	errInvalidTotpCode = errors.New("invalid two-factor code")
+)
+
+// recoveryCodeCount recovery codes are issued per enrolment; each works
+// once.
+const recoveryCodeCount = 10
+
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+type passwordConfirmRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type totpCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// secondFactorRequest takes either a current TOTP code or one of the
+// recovery codes.
+type secondFactorRequest struct {
+	Password      string `json:"password" validate:"required"`
+	Code          string `json:"code" validate:"required_without=Recovery_Code"`
+	Recovery_Code string `json:"recovery_code"`
+}
+
+type loginChallengeRequest struct {
+	Challenge     string `json:"challenge" validate:"required"`
+	Code          string `json:"code"`
+	Recovery_Code string `json:"recovery_code"`
+}
+
+func totpIssuer() string {
+	return helpers.EnvString("TOTP_ISSUER", "Restaurant Management")
+}
+
+// requiresTwoFactor reports whether the user's role is listed in
+// TOTP_REQUIRED_ROLES, a comma-separated list such as "ADMIN,MANAGER".
+// Users with those roles can't sign in or turn 2FA off until enrolled.
+func requiresTwoFactor(user models.User) bool {
+	if user.Role == nil {
+		return false
+	}
+	for _, role := range strings.Split(helpers.EnvString("TOTP_REQUIRED_ROLES", ""), ",") {
+		if strings.EqualFold(strings.TrimSpace(role), *user.Role) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnrollTwoFactor starts enrolment for the signed-in user and returns the
+// secret to add to an authenticator app. 2FA is only switched on once
+// ConfirmTwoFactor sees a valid code.
+func EnrollTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var request passwordConfirmRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		user, ok := signedInUser(ctx, c)
+		if !ok {
+			return
+		}
+		if valid, _ := VerifyPassword(request.Password, *user.Password); !valid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+			return
+		}
+		if user.Totp_Enabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already on"})
+			return
+		}
+
+		writeTotpEnrollment(ctx, c, user)
+	}
+}
+
+// ConfirmTwoFactor switches 2FA on with the first code from the app and
+// returns the recovery codes. They are not shown again.
+func ConfirmTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var request totpCodeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		user, ok := signedInUser(ctx, c)
+		if !ok {
+			return
+		}
+
+		codes, ok := confirmTotpEnrollment(ctx, c, user, request.Code)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "Two-factor authentication is on, store the recovery codes somewhere safe",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// DisableTwoFactor turns 2FA off after checking the password and a code.
+// Roles that require 2FA can't turn it off.
+func DisableTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var request secondFactorRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Send the password and a code or recovery code"})
+			return
+		}
+
+		user, ok := signedInUser(ctx, c)
+		if !ok {
+			return
+		}
+		if !user.Totp_Enabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not on"})
+			return
+		}
+		if requiresTwoFactor(user) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your role requires two-factor authentication"})
+			return
+		}
+		if valid, _ := VerifyPassword(request.Password, *user.Password); !valid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+			return
+		}
+		if err := verifySecondFactor(ctx, user, request.Code, request.Recovery_Code); err != nil {
+			writeSecondFactorError(c, err)
+			return
+		}
+
+		_, err := userModel.UpdateOne(ctx,
+			bson.M{"user_id": user.User_Id},
+			bson.M{
+				"$set":   bson.M{"totp_enabled": false, "updated_at": time.Now()},
+				"$unset": bson.M{"totp_secret": "", "totp_pending_secret": "", "totp_last_step": "", "recovery_codes": ""},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to turn off two-factor authentication"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication is off"})
+	}
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, for when they were
+// lost or mostly used.
+func RegenerateRecoveryCodes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var request totpCodeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		user, ok := signedInUser(ctx, c)
+		if !ok {
+			return
+		}
+		if !user.Totp_Enabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not on"})
+			return
+		}
+		if err := verifySecondFactor(ctx, user, request.Code, ""); err != nil {
+			writeSecondFactorError(c, err)
+			return
+		}
+
+		codes, hashes, err := newRecoveryCodes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+			return
+		}
+		_, err = userModel.UpdateOne(ctx,
+			bson.M{"user_id": user.User_Id},
+			bson.M{"$set": bson.M{"recovery_codes": hashes, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recovery codes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// VerifyLoginTwoFactor finishes a login that Login answered with a
+// challenge. Wrong codes count towards the same lockout as wrong
+// passwords.
+func VerifyLoginTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		request, user, ok := bindLoginChallenge(ctx, c, helpers.TwoFactorVerify)
+		if !ok {
+			return
+		}
+		email := NormalizeEmail(*user.Email)
+
+		wait, err := loginRetryAfter(ctx, email, c.ClientIP())
+		if err != nil {
+			log.Printf("Error checking login attempts for %s: %v", email, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error signing in"})
+			return
+		}
+		if wait > 0 {
+			recordLoginAttempt(ctx, c, email, user.User_Id, loginThrottled)
+			retryAfter := int(wait.Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later", "retry_after": retryAfter})
+			return
+		}
+
+		if err := verifySecondFactor(ctx, user, request.Code, request.Recovery_Code); err != nil {
+			if errors.Is(err, errInvalidTotpCode) {
+				recordLoginAttempt(ctx, c, email, user.User_Id, loginFailure)
+			}
+			writeSecondFactorError(c, err)
+			return
+		}
+
+		recordLoginAttempt(ctx, c, email, user.User_Id, loginSuccess)
+		writeLoginTokens(c, user, nil)
+	}
+}
+
+// EnrollLoginTwoFactor lets a user whose role requires 2FA enrol during
+// login, since they can't get an access token before they have.
+func EnrollLoginTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		_, user, ok := bindLoginChallenge(ctx, c, helpers.TwoFactorEnroll)
+		if !ok {
+			return
+		}
+		if user.Totp_Enabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already on, sign in again"})
+			return
+		}
+
+		writeTotpEnrollment(ctx, c, user)
+	}
+}
+
+// ConfirmLoginTwoFactor completes enrolment during login and signs the
+// user in.
+func ConfirmLoginTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		request, user, ok := bindLoginChallenge(ctx, c, helpers.TwoFactorEnroll)
+		if !ok {
+			return
+		}
+
+		codes, ok := confirmTotpEnrollment(ctx, c, user, request.Code)
+		if !ok {
+			return
+		}
+
+		recordLoginAttempt(ctx, c, NormalizeEmail(*user.Email), user.User_Id, loginSuccess)
+		writeLoginTokens(c, user, gin.H{"recovery_codes": codes})
+	}
+}
+
+// writeTwoFactorChallenge answers a correct password when the user still
+// has to send a code, or has to enrol first.
+func writeTwoFactorChallenge(c *gin.Context, user models.User) {
+	purpose := helpers.TwoFactorVerify
+	if !user.Totp_Enabled {
+		purpose = helpers.TwoFactorEnroll
+	}
+
+	challenge, err := helpers.GenerateTwoFactorChallenge(user.User_Id, purpose)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error signing in"})
+		return
+	}
+
+	response := gin.H{"challenge": challenge}
+	if purpose == helpers.TwoFactorVerify {
+		response["message"] = "Enter the code from your authenticator app"
+		response["two_factor_required"] = true
+	} else {
+		response["message"] = "Your role requires two-factor authentication, enrol to continue"
+		response["two_factor_setup_required"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func writeTotpEnrollment(ctx context.Context, c *gin.Context, user models.User) {
+	secret, err := helpers.NewTotpSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	_, err = userModel.UpdateOne(ctx,
+		bson.M{"user_id": user.User_Id},
+		bson.M{"$set": bson.M{"totp_pending_secret": secret, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrolment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Add the secret to your authenticator app, then confirm with a code",
+		"secret":      secret,
+		"otpauth_uri": helpers.TotpURI(totpIssuer(), *user.Email, secret),
+	})
+}
+
+func confirmTotpEnrollment(ctx context.Context, c *gin.Context, user models.User, code string) ([]string, bool) {
+	if user.Totp_Pending_Secret == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": errTotpNotStarted.Error()})
+		return nil, false
+	}
+
+	step, valid := helpers.VerifyTotp(*user.Totp_Pending_Secret, code, time.Now())
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidTotpCode.Error()})
+		return nil, false
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return nil, false
+	}
+
+	_, err = userModel.UpdateOne(ctx,
+		bson.M{"user_id": user.User_Id, "totp_pending_secret": *user.Totp_Pending_Secret},
+		bson.M{
+			"$set": bson.M{
+				"totp_enabled":   true,
+				"totp_secret":    *user.Totp_Pending_Secret,
+				"totp_last_step": step,
+				"recovery_codes": hashes,
+				"updated_at":     time.Now(),
+			},
+			"$unset": bson.M{"totp_pending_secret": ""},
+		},
+	)
+	if err != nil {
+		log.Printf("Error enabling two-factor authentication for user %s: %v", user.User_Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to turn on two-factor authentication"})
+		return nil, false
+	}
+	return codes, true
+}
+
+// verifySecondFactor accepts a TOTP code at most once, or spends one of the
+// user's recovery codes.
+func verifySecondFactor(ctx context.Context, user models.User, code, recoveryCode string) error {
+	if code != "" {
+		if user.Totp_Secret == nil {
+			return errInvalidTotpCode
+		}
+		step, valid := helpers.VerifyTotp(*user.Totp_Secret, code, time.Now())
+		if !valid {
+			return errInvalidTotpCode
+		}
+		result, err := userModel.UpdateOne(ctx,
+			bson.M{"user_id": user.User_Id, "totp_last_step": bson.M{"$lt": step}},
+			bson.M{"$set": bson.M{"totp_last_step": step}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errInvalidTotpCode
+		}
+		return nil
+	}
+
+	normalized := strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(recoveryCode))
+	if normalized == "" {
+		return errInvalidTotpCode
+	}
+	result, err := userModel.UpdateOne(ctx,
+		bson.M{"user_id": user.User_Id, "recovery_codes": hashSecretToken(normalized)},
+		bson.M{"$pull": bson.M{"recovery_codes": hashSecretToken(normalized)}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errInvalidTotpCode
+	}
+	return nil
+}
+
+func writeSecondFactorError(c *gin.Context, err error) {
+	if errors.Is(err, errInvalidTotpCode) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("Error checking two-factor code: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking two-factor code"})
+}
+
+func bindLoginChallenge(ctx context.Context, c *gin.Context, purpose string) (loginChallengeRequest, models.User, bool) {
+	var request loginChallengeRequest
+	var user models.User
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return request, user, false
+	}
+	if err := validate.Struct(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return request, user, false
+	}
+
+	claims, err := helpers.ValidateTwoFactorChallenge(request.Challenge, purpose)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Challenge is invalid or has expired, sign in again"})
+		return request, user, false
+	}
+
+	if err := userModel.FindOne(ctx, bson.M{"user_id": claims.Uid}).Decode(&user); err != nil || !user.IsActive() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Challenge is invalid or has expired, sign in again"})
+		return request, user, false
+	}
+	return request, user, true
+}
+
+// signedInUser loads the user behind the request's access token.
+func signedInUser(ctx context.Context, c *gin.Context) (models.User, bool) {
+	var user models.User
+	if err := userModel.FindOne(ctx, bson.M{"user_id": c.GetString("uid")}).Decode(&user); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return user, false
+	}
+	return user, true
+}
+
+// newRecoveryCodes returns codes formatted like "abcde-fghjk" and the
+// hashes to store for them.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		chars := make([]byte, len(b))
+		for i, v := range b {
+			chars[i] = recoveryCodeAlphabet[int(v)%len(recoveryCodeAlphabet)]
+		}
+		code := string(chars[:5]) + "-" + string(chars[5:])
+		codes = append(codes, code)
+		hashes = append(hashes, hashSecretToken(strings.ReplaceAll(code, "-", "")))
+	}
+	return codes, hashes, nil
+}
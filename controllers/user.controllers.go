@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/djwhocodes/restaurant_management/database"
@@ -44,14 +45,25 @@ func GetUsers() gin.HandlerFunc {
 			SetLimit(int64(limit)).
 			SetSort(bson.D{{Key: "created_at", Value: -1}})
 
-		cursor, err := userModel.Find(ctx, bson.M{}, opts)
+		filter := bson.M{}
+		if role := c.Query("role"); role != "" {
+			filter["role"] = strings.ToUpper(role)
+		}
+		switch c.Query("active") {
+		case "true":
+			filter["active"] = bson.M{"$ne": false}
+		case "false":
+			filter["active"] = false
+		}
+
+		cursor, err := userModel.Find(ctx, filter, opts)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching users"})
 			return
 		}
 		defer cursor.Close(ctx)
 
-		var users []bson.M
+		var users []models.User
 		if err := cursor.All(ctx, &users); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding user data"})
 			return
@@ -61,7 +73,7 @@ func GetUsers() gin.HandlerFunc {
 			"page":  page,
 			"limit": limit,
 			"count": len(users),
-			"data":  users,
+			"data":  models.PublicUsers(users),
 		})
 	}
 }
@@ -83,7 +95,7 @@ func GetUser() gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusOK, user)
+		c.JSON(http.StatusOK, user.Public())
 	}
 }
 
@@ -102,13 +114,15 @@ func SignUp() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		email := NormalizeEmail(*user.Email)
+		user.Email = &email
 
 		count, err := userModel.CountDocuments(ctx, bson.M{
 			"$or": []bson.M{
-				{"email": user.Email},
+				{"email": email},
 				{"phone": user.Phone},
 			},
-		})
+		}, options.Count().SetCollation(emailCollation))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking user existence"})
 			return
@@ -121,6 +135,11 @@ func SignUp() gin.HandlerFunc {
 		hashedPassword := HashPassword(*user.Password)
 		user.Password = &hashedPassword
 
+		role := models.DefaultUserRole
+		active := true
+		user.Role = &role
+		user.Active = &active
+
 		user.ID = primitive.NewObjectID()
 		user.User_Id = user.ID.Hex()
 		user.Created_At = time.Now()
@@ -133,52 +152,169 @@ func SignUp() gin.HandlerFunc {
 
 		_, err = userModel.InsertOne(ctx, user)
 		if err != nil {
+			// The unique indexes catch sign-ups racing past the check above.
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Email or phone already registered"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating user"})
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "User created successfully", "data": user})
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "User created successfully",
+			"data":    user.Public(),
+			"token":   token,
+			"refresh": refreshToken,
+		})
 	}
 }
 
+type loginRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// Login checks the email and password. Unknown emails and wrong passwords
+// get the same answer in about the same time, and repeated failures are
+// slowed down and then locked out per account and per client address.
 func Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		var user models.User
-		var foundUser models.User
-
-		if err := c.BindJSON(&user); err != nil {
+		var request loginRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email and password are required"})
+			return
+		}
+		email := NormalizeEmail(request.Email)
 
-		err := userModel.FindOne(ctx, bson.M{"email": user.Email}).Decode(&foundUser)
+		wait, err := loginRetryAfter(ctx, email, c.ClientIP())
 		if err != nil {
+			log.Printf("Error checking login attempts for %s: %v", email, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error signing in"})
+			return
+		}
+		if wait > 0 {
+			recordLoginAttempt(ctx, c, email, "", loginThrottled)
+			retryAfter := int(wait.Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later", "retry_after": retryAfter})
+			return
+		}
+
+		var foundUser models.User
+		err = userModel.FindOne(ctx, bson.M{"email": email}, options.FindOne().SetCollation(emailCollation)).Decode(&foundUser)
+		if err != nil || foundUser.Password == nil {
+			compareDummyPassword(request.Password)
+			recordLoginAttempt(ctx, c, email, "", loginFailure)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 			return
 		}
 
-		passwordValid, msg := VerifyPassword(*user.Password, *foundUser.Password)
-		if !passwordValid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+		if passwordValid, _ := VerifyPassword(request.Password, *foundUser.Password); !passwordValid {
+			recordLoginAttempt(ctx, c, email, foundUser.User_Id, loginFailure)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 			return
 		}
 
-		// Generate new tokens
-		token, refreshToken, _ := helpers.GenerateAllTokens(*foundUser.Email, *foundUser.First_Name, *foundUser.Last_Name, foundUser.User_Id)
+		// Only someone who knows the password learns the account is off.
+		if !foundUser.IsActive() {
+			recordLoginAttempt(ctx, c, email, foundUser.User_Id, loginFailure)
+			c.JSON(http.StatusForbidden, gin.H{"error": "This account has been deactivated"})
+			return
+		}
 
-		helpers.UpdateAllTokens(token, refreshToken, foundUser.User_Id)
+		// The attempt only counts as a success once the second factor
+		// is in, so wrong codes keep adding to the failures.
+		if foundUser.Totp_Enabled || requiresTwoFactor(foundUser) {
+			writeTwoFactorChallenge(c, foundUser)
+			return
+		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Login successful",
-			"token":   token,
-			"refresh": refreshToken,
-		})
+		recordLoginAttempt(ctx, c, email, foundUser.User_Id, loginSuccess)
+		writeLoginTokens(c, foundUser, nil)
+	}
+}
+
+// writeLoginTokens issues and stores a new token pair and answers the
+// login with them, plus any extra fields.
+func writeLoginTokens(c *gin.Context, user models.User, extra gin.H) {
+	token, refreshToken, _ := helpers.GenerateAllTokens(*user.Email, *user.First_Name, *user.Last_Name, user.User_Id)
+
+	helpers.UpdateAllTokens(token, refreshToken, user.User_Id)
+
+	response := gin.H{
+		"message": "Login successful",
+		"token":   token,
+		"refresh": refreshToken,
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeactivateUser stops a user from signing in and revokes their tokens.
+// Their records stay, so history keeps pointing at them.
+func DeactivateUser() gin.HandlerFunc {
+	return setUserActive(false)
+}
+
+func ReactivateUser() gin.HandlerFunc {
+	return setUserActive(true)
+}
+
+func setUserActive(active bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+		if !active && userId == c.GetString("uid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You can't deactivate yourself"})
+			return
+		}
+
+		result, err := userModel.UpdateOne(ctx,
+			bson.M{"user_id": userId},
+			bson.M{"$set": bson.M{"active": active, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating user"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if !active {
+			if _, err := helpers.RevokeTokens(ctx, bson.M{"user_id": userId}); err != nil {
+				log.Printf("Error revoking tokens of deactivated user %s: %v", userId, err)
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "User deactivated"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "User reactivated"})
 	}
 }
 
+// NormalizeEmail is how emails are stored and looked up, so the same
+// address typed in a different case is the same account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// emailCollation matches emails case-insensitively, for accounts stored
+// before their emails were normalised.
+var emailCollation = &options.Collation{Locale: "en", Strength: 2}
+
 func HashPassword(password string) string {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	if err != nil {
@@ -0,0 +1,464 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var webhookModel *mongo.Collection = database.OpenCollection(database.MongoClient, "webhook")
+var webhookDeliveryModel *mongo.Collection = database.OpenCollection(database.MongoClient, "webhook_delivery")
+
+// webhookEventTypes lists the events subscribers can ask for; "*" matches all.
+var webhookEventTypes = map[string]bool{
+	"*":                    true,
+	"order.created":        true,
+	"order.updated":        true,
+	"order_item.created":   true,
+	"order_item.updated":   true,
+	"invoice.created":      true,
+	"invoice.updated":      true,
+	"invoice.paid":         true,
+	"table.created":        true,
+	"table.updated":        true,
+	"table.bill_requested": true,
+	"table.waiter_called":  true,
+}
+
+var webhookClient = helpers.NewWebhookClient(10 * time.Second)
+
+func GetWebhooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		opts := options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}}).
+			SetProjection(bson.M{"secret": 0})
+
+		cursor, err := webhookModel.Find(ctx, bson.M{}, opts)
+		if err != nil {
+			log.Printf("Error fetching webhooks: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching webhooks"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var webhooks []bson.M
+		if err := cursor.All(ctx, &webhooks); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding webhooks"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": webhooks})
+	}
+}
+
+func CreateWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var webhook models.Webhook
+		if err := c.ShouldBindJSON(&webhook); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		if err := validate.Struct(webhook); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		for _, eventType := range webhook.Event_Types {
+			if !webhookEventTypes[eventType] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown event type: " + eventType})
+				return
+			}
+		}
+
+		if err := helpers.CheckWebhookURL(ctx, *webhook.Url); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if webhook.Active == nil {
+			active := true
+			webhook.Active = &active
+		}
+
+		now := time.Now().UTC()
+		webhook.ID = primitive.NewObjectID()
+		webhook.Webhook_Id = webhook.ID.Hex()
+		webhook.Created_At = now
+		webhook.Updated_At = now
+
+		if _, err := webhookModel.InsertOne(ctx, webhook); err != nil {
+			log.Printf("Error inserting webhook: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+			return
+		}
+
+		webhook.Secret = nil
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Webhook created successfully",
+			"data":    webhook,
+		})
+	}
+}
+
+func UpdateWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		webhookId := c.Param("webhook_id")
+
+		var webhook models.Webhook
+		if err := c.ShouldBindJSON(&webhook); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+			return
+		}
+
+		updateObj := bson.D{}
+		if webhook.Url != nil {
+			if err := validate.Var(*webhook.Url, "url"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "url is invalid"})
+				return
+			}
+			if err := helpers.CheckWebhookURL(ctx, *webhook.Url); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "url", Value: *webhook.Url})
+		}
+		if webhook.Secret != nil {
+			if err := validate.Var(*webhook.Secret, "min=16"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "secret must be at least 16 characters"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "secret", Value: *webhook.Secret})
+		}
+		if webhook.Event_Types != nil {
+			for _, eventType := range webhook.Event_Types {
+				if !webhookEventTypes[eventType] {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown event type: " + eventType})
+					return
+				}
+			}
+			updateObj = append(updateObj, bson.E{Key: "event_types", Value: webhook.Event_Types})
+		}
+		if webhook.Description != nil {
+			updateObj = append(updateObj, bson.E{Key: "description", Value: *webhook.Description})
+		}
+		if webhook.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: *webhook.Active})
+		}
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: time.Now().UTC()})
+
+		result, err := webhookModel.UpdateOne(ctx, bson.M{"webhook_id": webhookId}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			log.Printf("Error updating webhook (id=%s): %v", webhookId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook updated successfully"})
+	}
+}
+
+// GetWebhookDeliveries is the delivery log for one subscription.
+func GetWebhookDeliveries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit < 1 {
+			limit = 20
+		}
+
+		filter := bson.M{"webhook_id": c.Param("webhook_id")}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+
+		opts := options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}}).
+			SetSkip(int64((page - 1) * limit)).
+			SetLimit(int64(limit))
+
+		cursor, err := webhookDeliveryModel.Find(ctx, filter, opts)
+		if err != nil {
+			log.Printf("Error fetching webhook deliveries: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching deliveries"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var deliveries []bson.M
+		if err := cursor.All(ctx, &deliveries); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding deliveries"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"page":  page,
+			"limit": limit,
+			"data":  deliveries,
+		})
+	}
+}
+
+// GetDeadWebhookDeliveries lists deliveries that ran out of retries.
+func GetDeadWebhookDeliveries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}).SetLimit(100)
+
+		cursor, err := webhookDeliveryModel.Find(ctx, bson.M{"status": "DEAD"}, opts)
+		if err != nil {
+			log.Printf("Error fetching dead webhook deliveries: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching dead letters"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var deliveries []bson.M
+		if err := cursor.All(ctx, &deliveries); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding dead letters"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": deliveries})
+	}
+}
+
+// RetryWebhookDelivery puts a dead delivery back in the queue.
+func RetryWebhookDelivery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		now := time.Now().UTC()
+		result, err := webhookDeliveryModel.UpdateOne(ctx,
+			bson.M{"delivery_id": c.Param("delivery_id"), "status": "DEAD"},
+			bson.M{"$set": bson.M{"status": "PENDING", "attempts": 0, "next_attempt_at": now, "updated_at": now}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue delivery"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dead delivery not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Delivery requeued"})
+	}
+}
+
+// queueWebhookDeliveries is the event bus subscriber that fans an event out
+// to matching webhook subscriptions. Deliveries are keyed by webhook and
+// event id, so a redelivered event doesn't queue duplicates.
+func queueWebhookDeliveries(ctx context.Context, event helpers.DomainEvent) error {
+	cursor, err := webhookModel.Find(ctx, bson.M{
+		"active":      true,
+		"event_types": bson.M{"$in": []string{event.Type, "*"}},
+	})
+	if err != nil {
+		return err
+	}
+
+	var webhooks []models.Webhook
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, webhook := range webhooks {
+		id := primitive.NewObjectID()
+		_, err := webhookDeliveryModel.UpdateOne(ctx,
+			bson.M{"webhook_id": webhook.Webhook_Id, "event_id": event.Event_Id},
+			bson.M{"$setOnInsert": models.WebhookDelivery{
+				ID:              id,
+				Webhook_Id:      webhook.Webhook_Id,
+				Event_Id:        event.Event_Id,
+				Event_Type:      event.Type,
+				Payload:         string(payload),
+				Status:          "PENDING",
+				Log:             []models.DeliveryAttempt{},
+				Next_Attempt_At: now,
+				Created_At:      now,
+				Updated_At:      now,
+				Delivery_Id:     id.Hex(),
+			}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartWebhookDispatcher sends queued deliveries until ctx is cancelled.
+func StartWebhookDispatcher(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for dispatchNextWebhookDelivery(ctx) {
+				}
+			}
+		}
+	}()
+}
+
+// dispatchNextWebhookDelivery sends one due delivery and reports whether
+// there was one. Claiming pushes next_attempt_at forward, so another server
+// instance won't pick the same delivery up while it is in flight.
+func dispatchNextWebhookDelivery(parent context.Context) bool {
+	ctx, cancel := context.WithTimeout(parent, 30*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	var delivery models.WebhookDelivery
+	err := webhookDeliveryModel.FindOneAndUpdate(ctx,
+		bson.M{"status": "PENDING", "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": now.Add(time.Minute)}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}),
+	).Decode(&delivery)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("Error claiming webhook delivery: %v", err)
+		}
+		return false
+	}
+
+	var webhook models.Webhook
+	if err := webhookModel.FindOne(ctx, bson.M{"webhook_id": delivery.Webhook_Id}).Decode(&webhook); err != nil {
+		recordWebhookAttempt(ctx, &delivery, models.DeliveryAttempt{At: now, Error: "webhook no longer exists"}, true)
+		return true
+	}
+
+	recordWebhookAttempt(ctx, &delivery, sendWebhook(ctx, &webhook, &delivery), false)
+	return true
+}
+
+// sendWebhook posts the payload. Receivers verify X-Webhook-Signature, an
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the subscription secret.
+func sendWebhook(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) models.DeliveryAttempt {
+	started := time.Now()
+	attempt := models.DeliveryAttempt{At: started.UTC()}
+
+	timestamp := strconv.FormatInt(started.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(*webhook.Secret))
+	mac.Write([]byte(timestamp + "." + delivery.Payload))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *webhook.Url, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", delivery.Delivery_Id)
+	req.Header.Set("X-Webhook-Event", delivery.Event_Type)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := webhookClient.Do(req)
+	attempt.Duration_Ms = time.Since(started).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.Response_Code = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		attempt.Error = fmt.Sprintf("receiver answered %d", resp.StatusCode)
+	}
+	return attempt
+}
+
+// recordWebhookAttempt logs the attempt. Failures are retried with
+// exponential backoff and jitter until WEBHOOK_MAX_ATTEMPTS, after which the
+// delivery moves to the dead letters; giveUp skips straight there.
+func recordWebhookAttempt(ctx context.Context, delivery *models.WebhookDelivery, attempt models.DeliveryAttempt, giveUp bool) {
+	set := bson.M{"last_error": attempt.Error, "updated_at": time.Now().UTC()}
+	switch {
+	case attempt.Error == "":
+		set["status"] = "DELIVERED"
+	case giveUp || delivery.Attempts+1 >= webhookMaxAttempts():
+		set["status"] = "DEAD"
+	default:
+		set["next_attempt_at"] = time.Now().UTC().Add(webhookBackoff(delivery.Attempts + 1))
+	}
+
+	_, err := webhookDeliveryModel.UpdateOne(ctx,
+		bson.M{"delivery_id": delivery.Delivery_Id},
+		bson.M{
+			"$set":  set,
+			"$inc":  bson.M{"attempts": 1},
+			"$push": bson.M{"log": attempt},
+		},
+	)
+	if err != nil {
+		log.Printf("Error recording webhook attempt %s: %v", delivery.Delivery_Id, err)
+	}
+}
+
+// webhookBackoff waits 10s after the first failure and doubles from there,
+// capped at an hour.
+func webhookBackoff(attempts int) time.Duration {
+	delay := 10 * time.Second << min(attempts-1, 10)
+	delay = min(delay, time.Hour)
+	return delay + time.Duration(rand.Int63n(int64(delay/5)+1))
+}
+
+func webhookMaxAttempts() int {
+	return helpers.EnvInt("WEBHOOK_MAX_ATTEMPTS", 8)
+}
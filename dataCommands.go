@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// importBatchSize bounds the size of each bulk write during import.
+const importBatchSize = 500
+
+// secretCollections hold nothing but credentials and are left out of an
+// export unless --include-secrets is given.
+var secretCollections = map[string]bool{
+	"signing_key":    true,
+	"password_reset": true,
+}
+
+// secretFields are stripped from the documents of these collections unless
+// --include-secrets is given. Users restored from such an export have to
+// reset their password and enrol in 2FA again.
+var secretFields = map[string][]string{
+	"user": {
+		"password", "token", "refresh_token", "pin_hash",
+		"totp_enabled", "totp_secret", "totp_pending_secret", "totp_last_step", "recovery_codes",
+	},
+	"terminal": {"key_hash"},
+}
+
+// runExport writes every collection to <dir>/<collection>.json as an array
+// of canonical extended JSON, which keeps ObjectIds, dates and decimals
+// intact for import. Credentials are left out by default, and the files
+// are only readable by the current user.
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	dir := flags.String("dir", "", "directory to write the export to (required)")
+	includeSecrets := flags.Bool("include-secrets", false, "also export password hashes, 2FA secrets and signing keys")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "--dir is required")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	if err := os.MkdirAll(*dir, 0o700); err != nil {
+		fmt.Fprintln(os.Stderr, "creating export directory:", err)
+		return 1
+	}
+
+	db := database.OpenDatabase(database.MongoClient)
+	names, err := db.ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "listing collections:", err)
+		return 1
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if strings.HasPrefix(name, "system.") || (secretCollections[name] && !*includeSecrets) {
+			continue
+		}
+
+		var redact []string
+		if !*includeSecrets {
+			redact = secretFields[name]
+		}
+		count, err := exportCollection(ctx, db.Collection(name), filepath.Join(*dir, name+".json"), redact)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "exporting %s: %v\n", name, err)
+			return 1
+		}
+		fmt.Printf("%-20s %d document(s)\n", name, count)
+	}
+	return 0
+}
+
+func exportCollection(ctx context.Context, collection *mongo.Collection, path string, redact []string) (int, error) {
+	filter := bson.M{}
+	if collection.Name() == "schema_migrations" {
+		// The migration lock belongs to the running deployment.
+		filter["_id"] = bson.M{"$ne": "lock"}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if len(redact) > 0 {
+		projection := bson.M{}
+		for _, field := range redact {
+			projection[field] = 0
+		}
+		opts.SetProjection(projection)
+	}
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	// Tighten files left over from an earlier export too.
+	if err := file.Chmod(0o600); err != nil {
+		return 0, err
+	}
+
+	out := bufio.NewWriter(file)
+	out.WriteString("[")
+	count := 0
+	for cursor.Next(ctx) {
+		doc, err := bson.MarshalExtJSON(cursor.Current, true, false)
+		if err != nil {
+			return count, err
+		}
+		if count > 0 {
+			out.WriteString(",")
+		}
+		out.WriteString("\n")
+		out.Write(doc)
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	out.WriteString("\n]\n")
+
+	if err := out.Flush(); err != nil {
+		return count, err
+	}
+	return count, file.Close()
+}
+
+// runImport loads the files written by export. Documents are upserted by
+// _id, so an import can be repeated; --drop empties each collection first
+// but keeps its indexes and validators. Validation is bypassed because an
+// export may predate the current validators.
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dir := flags.String("dir", "", "directory written by export (required)")
+	drop := flags.Bool("drop", false, "delete existing documents before importing")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "--dir is required")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	paths, err := filepath.Glob(filepath.Join(*dir, "*.json"))
+	if err != nil || len(paths) == 0 {
+		fmt.Fprintf(os.Stderr, "no .json files found in %s\n", *dir)
+		return 1
+	}
+	sort.Strings(paths)
+
+	db := database.OpenDatabase(database.MongoClient)
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		if strings.HasPrefix(name, "system.") {
+			continue
+		}
+
+		count, err := importCollection(ctx, db.Collection(name), path, *drop)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "importing %s: %v\n", name, err)
+			return 1
+		}
+		fmt.Printf("%-20s %d document(s)\n", name, count)
+	}
+	return 0
+}
+
+func importCollection(ctx context.Context, collection *mongo.Collection, path string, drop bool) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return 0, fmt.Errorf("expected a JSON array: %w", err)
+	}
+
+	docs := make([]bson.D, 0, len(raws))
+	for i, raw := range raws {
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(raw, true, &doc); err != nil {
+			return 0, fmt.Errorf("document %d: %w", i+1, err)
+		}
+		id, ok := documentId(doc)
+		if !ok {
+			return 0, fmt.Errorf("document %d has no _id", i+1)
+		}
+		if collection.Name() == "schema_migrations" && id == "lock" {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+
+	if drop {
+		if _, err := collection.DeleteMany(ctx, bson.M{}); err != nil {
+			return 0, err
+		}
+	}
+
+	opts := options.BulkWrite().SetOrdered(false).SetBypassDocumentValidation(true)
+	for start := 0; start < len(docs); start += importBatchSize {
+		end := min(start+importBatchSize, len(docs))
+
+		writes := make([]mongo.WriteModel, 0, end-start)
+		for _, doc := range docs[start:end] {
+			id, _ := documentId(doc)
+			writes = append(writes, mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"_id": id}).
+				SetReplacement(doc).
+				SetUpsert(true))
+		}
+		if _, err := collection.BulkWrite(ctx, writes, opts); err != nil {
+			return start, err
+		}
+	}
+	return len(docs), nil
+}
+
+func documentId(doc bson.D) (interface{}, bool) {
+	for _, field := range doc {
+		if field.Key == "_id" {
+			return field.Value, true
+		}
+	}
+	return nil, false
+}
@@ -2,7 +2,6 @@ package database
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"time"
 
@@ -10,27 +9,35 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// DBinstance creates the client without talking to the server: the driver
+// connects in the background on first use, so packages can open their
+// collections at init. Ping checks the server is actually reachable.
 func DBinstance() *mongo.Client {
 	MongoURI := "mongodb://localhost:27017"
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(MongoURI))
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(MongoURI))
 	if err != nil {
 		log.Fatal("MongoDB connection error:", err)
 	}
-
-	if err := client.Ping(ctx, nil); err != nil {
-		log.Fatal("MongoDB ping failed:", err)
-	}
-
-	fmt.Println("✅ Connected to MongoDB successfully!")
 	return client
 }
 
 var MongoClient *mongo.Client = DBinstance()
 
+// Ping waits up to ten seconds for the server to answer.
+func Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return MongoClient.Ping(ctx, nil)
+}
+
+const DatabaseName = "restaurant_management"
+
+func OpenDatabase(client *mongo.Client) *mongo.Database {
+	return client.Database(DatabaseName)
+}
+
 func OpenCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	return client.Database("restaurant_management").Collection(collectionName)
+	return OpenDatabase(client).Collection(collectionName)
 }
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// illegalOperation is returned by standalone servers, which don't support
+// multi-document transactions.
+const illegalOperation = 20
+
+// WithTransaction runs fn inside a multi-document transaction, retrying on
+// transient errors. Against a standalone server (typical for local
+// development) fn runs without a transaction and transactional is false, so
+// callers that need all-or-nothing behaviour can compensate themselves.
+func WithTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) (transactional bool, err error) {
+	session, err := MongoClient.StartSession()
+	if err != nil {
+		return false, err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(illegalOperation) {
+		log.Println("MongoDB transactions unavailable, running without one")
+		return false, fn(mongo.NewSessionContext(ctx, session))
+	}
+
+	return true, err
+}
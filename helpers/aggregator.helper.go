@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AggregatorAdapter turns a delivery platform's order webhook into an
+// ExternalOrder. Each platform gets its own adapter; the generic adapter
+// covers platforms that can be described with a JSON field mapping.
+type AggregatorAdapter interface {
+	Name() string
+	// VerifySignature checks that the payload really came from the platform.
+	VerifySignature(payload []byte, header http.Header) error
+	ParseOrder(payload []byte) (ExternalOrder, error)
+}
+
+// ExternalOrder is a platform order in our terms, before its items are
+// matched to our foods.
+type ExternalOrder struct {
+	External_Order_Id string
+	Order_Type        string
+	Customer_Name     string
+	Customer_Phone    string
+	Customer_Email    string
+	Address_Line1     string
+	Address_Line2     string
+	City              string
+	Postal_Code       string
+	Address_Notes     string
+	Promised_Time     *time.Time
+	Items             []ExternalOrderItem
+	Total             float64
+}
+
+type ExternalOrderItem struct {
+	External_Item_Id string  `json:"external_item_id"`
+	Name             string  `json:"name"`
+	Quantity         int     `json:"quantity"`
+	Unit_Price       float64 `json:"unit_price"`
+	Note             string  `json:"note,omitempty"`
+}
+
+var (
+	ErrAggregatorSignature = errors.New("aggregator signature is invalid")
+	ErrAggregatorPayload   = errors.New("aggregator payload could not be parsed")
+)
+
+var (
+	aggregatorAdaptersMu sync.RWMutex
+	aggregatorAdapters   = map[string]AggregatorAdapter{}
+)
+
+func RegisterAggregatorAdapter(adapter AggregatorAdapter) {
+	aggregatorAdaptersMu.Lock()
+	defer aggregatorAdaptersMu.Unlock()
+	aggregatorAdapters[adapter.Name()] = adapter
+}
+
+func GetAggregatorAdapter(name string) (AggregatorAdapter, error) {
+	aggregatorAdaptersMu.RLock()
+	defer aggregatorAdaptersMu.RUnlock()
+
+	adapter, ok := aggregatorAdapters[name]
+	if !ok {
+		return nil, fmt.Errorf("aggregator %q is not registered", name)
+	}
+	return adapter, nil
+}
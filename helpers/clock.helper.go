@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var timeEntryModel *mongo.Collection = database.OpenCollection(database.MongoClient, "time_entry")
+
+// OpenTimeEntry returns the user's current clock-in, or nil when they are
+// clocked out.
+func OpenTimeEntry(ctx context.Context, userId string) (*models.TimeEntry, error) {
+	var entry models.TimeEntry
+	err := timeEntryModel.FindOne(ctx, bson.M{"user_id": userId, "status": "OPEN"}).Decode(&entry)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// OnBreak reports whether the entry has a break that hasn't ended.
+func OnBreak(entry *models.TimeEntry) bool {
+	for _, b := range entry.Breaks {
+		if b.End == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package helpers
+
+import (
+	"bytes"
+	"strings"
+)
+
+// A PrintDocument is a printer-neutral list of lines that can be rendered
+// either as raw ESC/POS bytes for thermal printers or as plain text for
+// on-screen previews.
+type PrintDocument struct {
+	Width  int
+	blocks []printBlock
+}
+
+type printBlock struct {
+	kind  string
+	text  string
+	right string
+	align byte
+	bold  bool
+	large bool
+}
+
+const (
+	AlignLeft   byte = 0
+	AlignCenter byte = 1
+	AlignRight  byte = 2
+)
+
+func NewPrintDocument(width int) *PrintDocument {
+	if width < 16 {
+		width = 42
+	}
+	return &PrintDocument{Width: width}
+}
+
+func (d *PrintDocument) Text(text string, align byte, bold bool) {
+	d.blocks = append(d.blocks, printBlock{kind: "text", text: text, align: align, bold: bold})
+}
+
+func (d *PrintDocument) Title(text string) {
+	d.blocks = append(d.blocks, printBlock{kind: "text", text: text, align: AlignCenter, bold: true, large: true})
+}
+
+// Row prints left and right aligned text on the same line, e.g. an item and
+// its price.
+func (d *PrintDocument) Row(left, right string, bold bool) {
+	d.blocks = append(d.blocks, printBlock{kind: "row", text: left, right: right, bold: bold})
+}
+
+func (d *PrintDocument) Separator() {
+	d.blocks = append(d.blocks, printBlock{kind: "separator"})
+}
+
+func (d *PrintDocument) Feed() {
+	d.blocks = append(d.blocks, printBlock{kind: "feed"})
+}
+
+func (d *PrintDocument) QRCode(data string) {
+	d.blocks = append(d.blocks, printBlock{kind: "qr", text: data, align: AlignCenter})
+}
+
+// PlainText renders the document for previews. QR codes are shown as their
+// encoded content.
+func (d *PrintDocument) PlainText() string {
+	var out strings.Builder
+	for _, block := range d.blocks {
+		switch block.kind {
+		case "text":
+			width := d.Width
+			if block.large {
+				width = d.Width / 2
+			}
+			for _, line := range wrapText(block.text, width) {
+				out.WriteString(alignText(line, d.Width, block.align))
+				out.WriteByte('\n')
+			}
+		case "row":
+			out.WriteString(rowText(block.text, block.right, d.Width))
+			out.WriteByte('\n')
+		case "separator":
+			out.WriteString(strings.Repeat("-", d.Width))
+			out.WriteByte('\n')
+		case "feed":
+			out.WriteByte('\n')
+		case "qr":
+			out.WriteString(alignText("[QR] "+block.text, d.Width, AlignCenter))
+			out.WriteByte('\n')
+		}
+	}
+	return out.String()
+}
+
+// ESCPOS renders the document as printer commands, finishing with a feed and
+// partial cut.
+func (d *PrintDocument) ESCPOS() []byte {
+	var out bytes.Buffer
+	out.Write([]byte{0x1B, 0x40}) // ESC @: initialise
+
+	for _, block := range d.blocks {
+		switch block.kind {
+		case "text":
+			out.Write([]byte{0x1B, 0x61, block.align})
+			setBold(&out, block.bold)
+			width := d.Width
+			if block.large {
+				out.Write([]byte{0x1D, 0x21, 0x11}) // GS !: double width and height
+				width = d.Width / 2
+			}
+			for _, line := range wrapText(block.text, width) {
+				out.WriteString(printable(line))
+				out.WriteByte('\n')
+			}
+			if block.large {
+				out.Write([]byte{0x1D, 0x21, 0x00})
+			}
+			setBold(&out, false)
+		case "row":
+			out.Write([]byte{0x1B, 0x61, AlignLeft})
+			setBold(&out, block.bold)
+			out.WriteString(printable(rowText(block.text, block.right, d.Width)))
+			out.WriteByte('\n')
+			setBold(&out, false)
+		case "separator":
+			out.Write([]byte{0x1B, 0x61, AlignLeft})
+			out.WriteString(strings.Repeat("-", d.Width))
+			out.WriteByte('\n')
+		case "feed":
+			out.WriteByte('\n')
+		case "qr":
+			out.Write([]byte{0x1B, 0x61, AlignCenter})
+			writeQRCode(&out, block.text)
+		}
+	}
+
+	out.Write([]byte{0x1B, 0x64, 0x04})       // ESC d: feed 4 lines
+	out.Write([]byte{0x1D, 0x56, 0x42, 0x00}) // GS V: partial cut
+	return out.Bytes()
+}
+
+func setBold(out *bytes.Buffer, on bool) {
+	flag := byte(0)
+	if on {
+		flag = 1
+	}
+	out.Write([]byte{0x1B, 0x45, flag})
+}
+
+// writeQRCode uses the printer's built-in QR renderer (GS ( k, model 2).
+func writeQRCode(out *bytes.Buffer, data string) {
+	payload := []byte(data)
+	storeLen := len(payload) + 3
+
+	out.Write([]byte{0x1D, 0x28, 0x6B, 0x04, 0x00, 0x31, 0x41, 0x32, 0x00}) // model 2
+	out.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x43, 0x06})       // module size
+	out.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x45, 0x31})       // error correction M
+	out.Write([]byte{0x1D, 0x28, 0x6B, byte(storeLen % 256), byte(storeLen / 256), 0x31, 0x50, 0x30})
+	out.Write(payload)
+	out.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x51, 0x30}) // print
+	out.WriteByte('\n')
+}
+
+// printable replaces characters outside ASCII, which most printers would
+// otherwise render using whatever code page they booted with.
+func printable(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7E {
+			return '?'
+		}
+		return r
+	}, text)
+}
+
+func rowText(left, right string, width int) string {
+	space := width - len([]rune(right)) - 1
+	if space < 1 {
+		space = 1
+	}
+	leftRunes := []rune(left)
+	if len(leftRunes) > space {
+		leftRunes = leftRunes[:space]
+	}
+	pad := max(width-len(leftRunes)-len([]rune(right)), 1)
+	return string(leftRunes) + strings.Repeat(" ", pad) + right
+}
+
+func alignText(text string, width int, align byte) string {
+	pad := width - len([]rune(text))
+	if pad <= 0 {
+		return text
+	}
+	switch align {
+	case AlignCenter:
+		return strings.Repeat(" ", pad/2) + text
+	case AlignRight:
+		return strings.Repeat(" ", pad) + text
+	}
+	return text
+}
+
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len([]rune(word)) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, string([]rune(word)[:width]))
+				word = string([]rune(word)[width:])
+			}
+			if line == "" {
+				line = word
+			} else if len([]rune(line))+1+len([]rune(word)) <= width {
+				line += " " + word
+			} else {
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,204 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var outboxModel *mongo.Collection = database.OpenCollection(database.MongoClient, "outbox")
+
+// DomainEvent is what subscribers receive. Delivery is at-least-once, so
+// subscribers should use Event_Id to ignore repeats.
+type DomainEvent struct {
+	Event_Id     string          `json:"id"`
+	Type         string          `json:"type"`
+	Aggregate_Id string          `json:"aggregate_id"`
+	Payload      json.RawMessage `json:"data"`
+	Occurred_At  time.Time       `json:"created_at"`
+}
+
+type EventHandler func(ctx context.Context, event DomainEvent) error
+
+type eventSubscriber struct {
+	name    string
+	pattern string
+	handler EventHandler
+}
+
+var (
+	subscribersMu sync.RWMutex
+	subscribers   []eventSubscriber
+)
+
+// Subscribe registers an in-process handler. The pattern is an exact event
+// type, a prefix such as "invoice.*", or "*" for everything. The name must be
+// unique and stable: it records which subscribers already handled an event.
+func Subscribe(name, pattern string, handler EventHandler) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subscribers = append(subscribers, eventSubscriber{name: name, pattern: pattern, handler: handler})
+}
+
+func matchingSubscribers(eventType string) []eventSubscriber {
+	subscribersMu.RLock()
+	defer subscribersMu.RUnlock()
+
+	var matched []eventSubscriber
+	for _, subscriber := range subscribers {
+		if subscriber.pattern == "*" || subscriber.pattern == eventType ||
+			(strings.HasSuffix(subscriber.pattern, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(subscriber.pattern, "*"))) {
+			matched = append(matched, subscriber)
+		}
+	}
+	return matched
+}
+
+// RecordEvent writes an event to the outbox. Pass the session context of the
+// surrounding transaction so the event is committed with the change itself.
+func RecordEvent(ctx context.Context, eventType, aggregateId string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encoding %s event: %w", eventType, err)
+	}
+
+	now := time.Now().UTC()
+	event := models.OutboxEvent{
+		ID:              primitive.NewObjectID(),
+		Type:            eventType,
+		Aggregate_Id:    aggregateId,
+		Payload:         string(payload),
+		Status:          "PENDING",
+		Delivered_To:    []string{},
+		Next_Attempt_At: now,
+		Occurred_At:     now,
+	}
+	event.Event_Id = event.ID.Hex()
+
+	_, err = outboxModel.InsertOne(ctx, event)
+	if err == nil {
+		wakeOutboxDispatcher()
+	}
+	return err
+}
+
+var outboxWake = make(chan struct{}, 1)
+
+func wakeOutboxDispatcher() {
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartOutboxDispatcher delivers pending events until ctx is cancelled. It
+// wakes on new events and otherwise polls, which also picks up events left
+// behind by a crash or written by another instance.
+func StartOutboxDispatcher(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-outboxWake:
+			}
+			for dispatchNextOutboxEvent(ctx) {
+			}
+		}
+	}()
+}
+
+// dispatchNextOutboxEvent handles one due event and reports whether there
+// was one. The claim leases the event for a minute; subscribers that
+// succeed are remembered so a retry only reaches the ones that failed.
+func dispatchNextOutboxEvent(parent context.Context) bool {
+	ctx, cancel := context.WithTimeout(parent, 30*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	var event models.OutboxEvent
+	err := outboxModel.FindOneAndUpdate(ctx,
+		bson.M{"status": "PENDING", "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": now.Add(time.Minute)}, "$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "occurred_at", Value: 1}}).SetReturnDocument(options.After),
+	).Decode(&event)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) && !errors.Is(err, context.Canceled) {
+			log.Printf("Error claiming outbox event: %v", err)
+		}
+		return false
+	}
+
+	domainEvent := DomainEvent{
+		Event_Id:     event.Event_Id,
+		Type:         event.Type,
+		Aggregate_Id: event.Aggregate_Id,
+		Payload:      json.RawMessage(event.Payload),
+		Occurred_At:  event.Occurred_At,
+	}
+
+	delivered := map[string]bool{}
+	for _, name := range event.Delivered_To {
+		delivered[name] = true
+	}
+
+	var failures []string
+	for _, subscriber := range matchingSubscribers(event.Type) {
+		if delivered[subscriber.name] {
+			continue
+		}
+		if err := safeHandle(ctx, subscriber, domainEvent); err != nil {
+			failures = append(failures, subscriber.name+": "+err.Error())
+			continue
+		}
+		outboxModel.UpdateOne(ctx, bson.M{"_id": event.ID}, bson.M{"$addToSet": bson.M{"delivered_to": subscriber.name}})
+	}
+
+	set := bson.M{}
+	if len(failures) == 0 {
+		set["status"] = "DISPATCHED"
+		set["dispatched_at"] = time.Now().UTC()
+		set["last_error"] = ""
+	} else {
+		set["last_error"] = strings.Join(failures, "; ")
+		set["next_attempt_at"] = time.Now().UTC().Add(outboxBackoff(event.Attempts))
+		if event.Attempts >= EnvInt("OUTBOX_MAX_ATTEMPTS", 20) {
+			set["status"] = "FAILED"
+		}
+	}
+
+	if _, err := outboxModel.UpdateOne(ctx, bson.M{"_id": event.ID}, bson.M{"$set": set}); err != nil {
+		log.Printf("Error updating outbox event %s: %v", event.Event_Id, err)
+	}
+	return true
+}
+
+// safeHandle keeps a panicking subscriber from taking the dispatcher down.
+func safeHandle(ctx context.Context, subscriber eventSubscriber, event DomainEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return subscriber.handler(ctx, event)
+}
+
+func outboxBackoff(attempts int) time.Duration {
+	return min(time.Second<<min(attempts, 12), 10*time.Minute)
+}
@@ -0,0 +1,246 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GenericAggregatorAdapter reads platform orders through a JSON field
+// mapping, so a new platform or a local fixture only needs a mapping file.
+// AGGREGATOR_GENERIC_MAPPING points at the file; without it the default
+// mapping below is used (see fixtures/aggregator). Payloads must carry a
+// hex HMAC-SHA256 of the body, keyed with AGGREGATOR_GENERIC_SECRET:
+//
+//	openssl dgst -sha256 -hmac "$AGGREGATOR_GENERIC_SECRET" order.json
+type GenericAggregatorAdapter struct{}
+
+// GenericAggregatorMapping holds dot-separated paths into the platform's
+// order JSON. Item paths are relative to each entry of Items.
+type GenericAggregatorMapping struct {
+	Signature_Header       string            `json:"signature_header"`
+	Order_Id               string            `json:"order_id"`
+	Order_Type             string            `json:"order_type"`
+	Order_Type_Values      map[string]string `json:"order_type_values"`
+	Customer_Name          string            `json:"customer_name"`
+	Customer_Phone         string            `json:"customer_phone"`
+	Customer_Email         string            `json:"customer_email"`
+	Address_Line1          string            `json:"address_line1"`
+	Address_Line2          string            `json:"address_line2"`
+	City                   string            `json:"city"`
+	Postal_Code            string            `json:"postal_code"`
+	Address_Notes          string            `json:"address_notes"`
+	Promised_Time          string            `json:"promised_time"`
+	Total                  string            `json:"total"`
+	Items                  string            `json:"items"`
+	Item_Id                string            `json:"item_id"`
+	Item_Name              string            `json:"item_name"`
+	Item_Quantity          string            `json:"item_quantity"`
+	Item_Unit_Price        string            `json:"item_unit_price"`
+	Item_Note              string            `json:"item_note"`
+	Amounts_In_Minor_Units bool              `json:"amounts_in_minor_units"`
+}
+
+var defaultGenericAggregatorMapping = GenericAggregatorMapping{
+	Signature_Header:  "X-Signature",
+	Order_Id:          "id",
+	Order_Type:        "fulfilment",
+	Order_Type_Values: map[string]string{"delivery": "DELIVERY", "pickup": "TAKEAWAY"},
+	Customer_Name:     "customer.name",
+	Customer_Phone:    "customer.phone",
+	Customer_Email:    "customer.email",
+	Address_Line1:     "delivery.address.street",
+	Address_Line2:     "delivery.address.unit",
+	City:              "delivery.address.city",
+	Postal_Code:       "delivery.address.postcode",
+	Address_Notes:     "delivery.instructions",
+	Promised_Time:     "promised_at",
+	Total:             "total",
+	Items:             "items",
+	Item_Id:           "sku",
+	Item_Name:         "name",
+	Item_Quantity:     "quantity",
+	Item_Unit_Price:   "price",
+	Item_Note:         "note",
+}
+
+func init() {
+	RegisterAggregatorAdapter(GenericAggregatorAdapter{})
+}
+
+func (GenericAggregatorAdapter) Name() string {
+	return "generic"
+}
+
+func (GenericAggregatorAdapter) VerifySignature(payload []byte, header http.Header) error {
+	mapping, err := GenericAggregatorMappingFromEnv()
+	if err != nil {
+		return err
+	}
+
+	secret := os.Getenv("AGGREGATOR_GENERIC_SECRET")
+	if secret == "" {
+		return ErrAggregatorSignature
+	}
+
+	signature := strings.TrimPrefix(header.Get(mapping.Signature_Header), "sha256=")
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrAggregatorSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	if !hmac.Equal(given, mac.Sum(nil)) {
+		return ErrAggregatorSignature
+	}
+	return nil
+}
+
+func (GenericAggregatorAdapter) ParseOrder(payload []byte) (ExternalOrder, error) {
+	mapping, err := GenericAggregatorMappingFromEnv()
+	if err != nil {
+		return ExternalOrder{}, err
+	}
+	return mapping.Parse(payload)
+}
+
+// GenericAggregatorMappingFromEnv loads the mapping file named by
+// AGGREGATOR_GENERIC_MAPPING. It is read on every call so the mapping can
+// be tuned against fixtures without a restart.
+func GenericAggregatorMappingFromEnv() (GenericAggregatorMapping, error) {
+	path := os.Getenv("AGGREGATOR_GENERIC_MAPPING")
+	if path == "" {
+		return defaultGenericAggregatorMapping, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return GenericAggregatorMapping{}, fmt.Errorf("reading aggregator mapping: %w", err)
+	}
+
+	var mapping GenericAggregatorMapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return GenericAggregatorMapping{}, fmt.Errorf("parsing aggregator mapping: %w", err)
+	}
+	if mapping.Signature_Header == "" {
+		mapping.Signature_Header = defaultGenericAggregatorMapping.Signature_Header
+	}
+	return mapping, nil
+}
+
+func (m GenericAggregatorMapping) Parse(payload []byte) (ExternalOrder, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return ExternalOrder{}, fmt.Errorf("%w: %v", ErrAggregatorPayload, err)
+	}
+
+	order := ExternalOrder{
+		External_Order_Id: jsonString(doc, m.Order_Id),
+		Customer_Name:     jsonString(doc, m.Customer_Name),
+		Customer_Phone:    jsonString(doc, m.Customer_Phone),
+		Customer_Email:    jsonString(doc, m.Customer_Email),
+		Address_Line1:     jsonString(doc, m.Address_Line1),
+		Address_Line2:     jsonString(doc, m.Address_Line2),
+		City:              jsonString(doc, m.City),
+		Postal_Code:       jsonString(doc, m.Postal_Code),
+		Address_Notes:     jsonString(doc, m.Address_Notes),
+		Total:             m.amount(jsonNumber(doc, m.Total)),
+	}
+	if order.External_Order_Id == "" {
+		return ExternalOrder{}, fmt.Errorf("%w: missing %s", ErrAggregatorPayload, m.Order_Id)
+	}
+
+	order.Order_Type = "DELIVERY"
+	if value := jsonString(doc, m.Order_Type); value != "" {
+		if mapped, ok := m.Order_Type_Values[value]; ok {
+			order.Order_Type = mapped
+		}
+	}
+
+	if value := jsonString(doc, m.Promised_Time); value != "" {
+		promised, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return ExternalOrder{}, fmt.Errorf("%w: %s is not an RFC 3339 time", ErrAggregatorPayload, m.Promised_Time)
+		}
+		order.Promised_Time = &promised
+	}
+
+	items, _ := jsonLookup(doc, m.Items).([]interface{})
+	if len(items) == 0 {
+		return ExternalOrder{}, fmt.Errorf("%w: order has no items", ErrAggregatorPayload)
+	}
+	for _, raw := range items {
+		entry, ok := raw.(map[string]interface{})
+		if !ok {
+			return ExternalOrder{}, fmt.Errorf("%w: items must be objects", ErrAggregatorPayload)
+		}
+
+		item := ExternalOrderItem{
+			External_Item_Id: jsonString(entry, m.Item_Id),
+			Name:             jsonString(entry, m.Item_Name),
+			Quantity:         int(jsonNumber(entry, m.Item_Quantity)),
+			Unit_Price:       m.amount(jsonNumber(entry, m.Item_Unit_Price)),
+			Note:             jsonString(entry, m.Item_Note),
+		}
+		if item.External_Item_Id == "" || item.Quantity <= 0 {
+			return ExternalOrder{}, fmt.Errorf("%w: every item needs %s and a positive %s", ErrAggregatorPayload, m.Item_Id, m.Item_Quantity)
+		}
+		order.Items = append(order.Items, item)
+	}
+
+	return order, nil
+}
+
+func (m GenericAggregatorMapping) amount(value float64) float64 {
+	if m.Amounts_In_Minor_Units {
+		value /= 100
+	}
+	return math.Round(value*100) / 100
+}
+
+// jsonLookup follows a dot-separated path through decoded JSON objects.
+func jsonLookup(doc map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+
+	var current interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = object[key]
+	}
+	return current
+}
+
+func jsonString(doc map[string]interface{}, path string) string {
+	switch value := jsonLookup(doc, path).(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return ""
+}
+
+func jsonNumber(doc map[string]interface{}, path string) float64 {
+	switch value := jsonLookup(doc, path).(type) {
+	case float64:
+		return value
+	case string:
+		number, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return number
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+package helpers
+
+import (
+	"errors"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// GuestTableClaims identify the table behind a QR code. The codes are
+// printed and stay on the table, so they don't expire; bumping the table's
+// QR version revokes every code issued before. For the same reason they
+// are signed with their own HS256 secret, GUEST_TOKEN_SECRET, rather than
+// the rotating keyring. It must differ from SECRET_KEY, so a leaked table
+// secret can't sign anything else; codes printed while the two were shared
+// have to be issued again.
+type GuestTableClaims struct {
+	Table_Id   string `json:"table_id"`
+	Qr_Version int    `json:"qr_version"`
+	jwt.RegisteredClaims
+}
+
+const guestTokenSubject = "guest-table"
+
+func guestTokenSecret() []byte {
+	secret := os.Getenv("GUEST_TOKEN_SECRET")
+	if secret == os.Getenv("SECRET_KEY") {
+		return nil
+	}
+	return []byte(secret)
+}
+
+var errNoGuestSecret = errors.New("set GUEST_TOKEN_SECRET, different from SECRET_KEY, to sign table codes")
+
+func GenerateGuestToken(tableId string, qrVersion int) (string, error) {
+	if len(guestTokenSecret()) == 0 {
+		return "", errNoGuestSecret
+	}
+	claims := &GuestTableClaims{
+		Table_Id:         tableId,
+		Qr_Version:       qrVersion,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: guestTokenSubject},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(guestTokenSecret())
+}
+
+func ValidateGuestToken(signedToken string) (*GuestTableClaims, error) {
+	if len(guestTokenSecret()) == 0 {
+		return nil, errNoGuestSecret
+	}
+	claims := &GuestTableClaims{}
+	_, err := jwt.ParseWithClaims(signedToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return guestTokenSecret(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject != guestTokenSubject || claims.Table_Id == "" {
+		return nil, errors.New("not a table token")
+	}
+	return claims, nil
+}
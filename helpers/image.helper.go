@@ -0,0 +1,54 @@
+package helpers
+
+import (
+	"image"
+	"image/color"
+)
+
+// Thumbnail scales img down so its longer side is at most maxSide, keeping
+// the aspect ratio. Each target pixel averages the source pixels it covers,
+// which keeps downscaled photos smooth without an imaging dependency.
+// Images already small enough are returned unchanged.
+func Thumbnail(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSide && height <= maxSide {
+		return img
+	}
+
+	newWidth, newHeight := maxSide, maxSide
+	if width > height {
+		newHeight = max(1, height*maxSide/width)
+	} else {
+		newWidth = max(1, width*maxSide/height)
+	}
+
+	thumb := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		y0 := bounds.Min.Y + y*height/newHeight
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*height/newHeight)
+
+		for x := 0; x < newWidth; x++ {
+			x0 := bounds.Min.X + x*width/newWidth
+			x1 := max(x0+1, bounds.Min.X+(x+1)*width/newWidth)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			thumb.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return thumb
+}
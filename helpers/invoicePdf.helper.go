@@ -0,0 +1,159 @@
+package helpers
+
+import (
+	"fmt"
+
+	"github.com/djwhocodes/restaurant_management/models"
+)
+
+// RestaurantDetails is printed in the header of formal invoices.
+type RestaurantDetails struct {
+	Name    string
+	Address []string
+	Phone   string
+	Email   string
+	Tax_Id  string
+}
+
+// RestaurantDetailsFromEnv reads RESTAURANT_NAME, RESTAURANT_ADDRESS (lines
+// separated by "|"), RESTAURANT_PHONE, RESTAURANT_EMAIL and RESTAURANT_TAX_ID.
+func RestaurantDetailsFromEnv() RestaurantDetails {
+	return RestaurantDetails{
+		Name:    EnvString("RESTAURANT_NAME", "Restaurant"),
+		Address: splitLines(EnvString("RESTAURANT_ADDRESS", "")),
+		Phone:   EnvString("RESTAURANT_PHONE", ""),
+		Email:   EnvString("RESTAURANT_EMAIL", ""),
+		Tax_Id:  EnvString("RESTAURANT_TAX_ID", ""),
+	}
+}
+
+// InvoicePDF lays out an A4 invoice. The customer may be nil.
+func InvoicePDF(invoice *models.Invoice, customer *models.Customer, restaurant RestaurantDetails) []byte {
+	const (
+		left   = 50.0
+		right  = PDFPageWidth - 50
+		bottom = 80.0
+	)
+
+	doc := NewPDFDocument()
+	y := PDFPageHeight - 60
+
+	doc.Text(left, y, 18, true, restaurant.Name)
+	doc.TextRight(right, y, 18, true, "INVOICE")
+	y -= 20
+
+	details := append([]string{}, restaurant.Address...)
+	if restaurant.Phone != "" {
+		details = append(details, "Tel: "+restaurant.Phone)
+	}
+	if restaurant.Email != "" {
+		details = append(details, restaurant.Email)
+	}
+	if restaurant.Tax_Id != "" {
+		details = append(details, "Tax ID: "+restaurant.Tax_Id)
+	}
+
+	meta := [][2]string{
+		{"Invoice No.", InvoiceDisplayNumber(invoice)},
+		{"Date", invoice.Created_At.Local().Format("2006-01-02")},
+		{"Order", invoice.Order_Id},
+	}
+	if !invoice.Payment_Due_Date.IsZero() {
+		meta = append(meta, [2]string{"Due", invoice.Payment_Due_Date.Local().Format("2006-01-02")})
+	}
+
+	for i := 0; i < max(len(details), len(meta)); i++ {
+		if i < len(details) {
+			doc.Text(left, y, 10, false, details[i])
+		}
+		if i < len(meta) {
+			doc.TextRight(right-110, y, 10, true, meta[i][0])
+			doc.TextRight(right, y, 10, false, meta[i][1])
+		}
+		y -= 14
+	}
+
+	if customer != nil {
+		y -= 10
+		doc.Text(left, y, 10, true, "Bill to")
+		y -= 14
+		name := *customer.First_Name
+		if customer.Last_Name != nil {
+			name += " " + *customer.Last_Name
+		}
+		doc.Text(left, y, 10, false, name)
+		y -= 14
+		if customer.Email != nil {
+			doc.Text(left, y, 10, false, *customer.Email)
+			y -= 14
+		}
+	}
+
+	tableHeader := func() {
+		y -= 16
+		doc.Text(left, y, 10, true, "Description")
+		doc.TextRight(right-170, y, 10, true, "Qty")
+		doc.TextRight(right-90, y, 10, true, "Unit price")
+		doc.TextRight(right, y, 10, true, "Amount")
+		y -= 6
+		doc.Line(left, y, right, y, 0.8)
+		y -= 14
+	}
+
+	newPageIfNeeded := func() {
+		if y < bottom {
+			doc.AddPage()
+			y = PDFPageHeight - 60
+			tableHeader()
+		}
+	}
+
+	tableHeader()
+	for _, item := range invoice.Line_Items {
+		newPageIfNeeded()
+		doc.Text(left, y, 10, false, item.Name)
+		doc.TextRight(right-170, y, 10, false, fmt.Sprint(item.Quantity))
+		doc.TextRight(right-90, y, 10, false, Money(item.Unit_Price))
+		doc.TextRight(right, y, 10, false, Money(item.Line_Total))
+		y -= 14
+	}
+
+	y += 4
+	doc.Line(left, y, right, y, 0.5)
+	y -= 16
+
+	total := func(label, amount string, bold bool) {
+		newPageIfNeeded()
+		doc.TextRight(right-90, y, 10, bold, label)
+		doc.TextRight(right, y, 10, bold, amount)
+		y -= 14
+	}
+
+	total("Subtotal", Money(invoice.Sub_Total), false)
+	for _, promotion := range invoice.Applied_Promotions {
+		total(promotion.Name, "-"+Money(promotion.Amount), false)
+	}
+	if invoice.Delivery_Fee > 0 {
+		total("Delivery", Money(invoice.Delivery_Fee), false)
+	}
+	taxable := invoice.Sub_Total - invoice.Discount_Total + invoice.Delivery_Fee
+	total(fmt.Sprintf("%s %g%% on %s", invoice.Tax_Name, invoice.Tax_Rate, Money(taxable)), Money(invoice.Tax_Amount), false)
+	total("Total", Money(invoice.Total_Amount), true)
+	if invoice.Points_Value > 0 {
+		total(fmt.Sprintf("Loyalty points (%d)", invoice.Points_Redeemed), "-"+Money(invoice.Points_Value), false)
+		total("Amount due", Money(invoice.Amount_Due), true)
+	}
+
+	y -= 10
+	newPageIfNeeded()
+	status := "PENDING"
+	if invoice.Payment_Status != nil && *invoice.Payment_Status != "" {
+		status = *invoice.Payment_Status
+	}
+	if invoice.Payment_Method != nil && *invoice.Payment_Method != "" {
+		status += " by " + *invoice.Payment_Method
+	}
+	doc.Text(left, y, 10, true, "Payment status: "+status)
+
+	return doc.Bytes()
+}
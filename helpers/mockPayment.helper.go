@@ -0,0 +1,151 @@
+package helpers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockPaymentProvider simulates a card gateway in memory for local testing.
+// The card token picks the outcome:
+//
+//	tok_decline       the authorisation is declined
+//	tok_insufficient  declined with insufficient_funds
+//	tok_timeout       the gateway never answers and the call times out
+//	tok_slow          approved after a two second delay
+//
+// Any other token is approved. Webhooks are signed with HMAC-SHA256 using
+// MOCK_PAYMENT_WEBHOOK_SECRET. The mock is only registered when
+// PAYMENT_MOCK_ENABLED is true, so it can't take payments in production.
+type MockPaymentProvider struct {
+	mu       sync.Mutex
+	payments map[string]*mockPayment
+}
+
+type mockPayment struct {
+	authorized float64
+	captured   float64
+	refunded   float64
+	status     string
+}
+
+// RegisterMockPaymentProvider registers the mock gateway when it is enabled.
+// It refuses to without a webhook secret, since anyone could sign webhooks
+// with a default one.
+func RegisterMockPaymentProvider() error {
+	if EnvString("PAYMENT_MOCK_ENABLED", "false") != "true" {
+		return nil
+	}
+	if EnvString("MOCK_PAYMENT_WEBHOOK_SECRET", "") == "" {
+		return errors.New("PAYMENT_MOCK_ENABLED is set but MOCK_PAYMENT_WEBHOOK_SECRET is empty")
+	}
+	RegisterPaymentProvider(&MockPaymentProvider{payments: map[string]*mockPayment{}})
+	return nil
+}
+
+func (m *MockPaymentProvider) Name() string {
+	return "mock"
+}
+
+func (m *MockPaymentProvider) Authorize(ctx context.Context, req PaymentRequest) (PaymentResult, error) {
+	switch req.Card_Token {
+	case "tok_timeout":
+		<-ctx.Done()
+		return PaymentResult{Status: "FAILED", Message: "gateway timeout"}, ErrPaymentTimeout
+	case "tok_slow":
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			return PaymentResult{Status: "FAILED", Message: "gateway timeout"}, ErrPaymentTimeout
+		}
+	case "tok_decline":
+		return PaymentResult{Status: "DECLINED", Decline_Code: "card_declined", Message: "The card was declined"}, ErrPaymentDeclined
+	case "tok_insufficient":
+		return PaymentResult{Status: "DECLINED", Decline_Code: "insufficient_funds", Message: "Insufficient funds"}, ErrPaymentDeclined
+	}
+
+	reference := "mock_" + primitive.NewObjectID().Hex()
+
+	m.mu.Lock()
+	m.payments[reference] = &mockPayment{authorized: req.Amount, status: "AUTHORIZED"}
+	m.mu.Unlock()
+
+	return PaymentResult{Reference: reference, Status: "AUTHORIZED", Message: "Approved"}, nil
+}
+
+func (m *MockPaymentProvider) Capture(ctx context.Context, reference string, amount float64) (PaymentResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	payment, ok := m.payments[reference]
+	if !ok || payment.status != "AUTHORIZED" || amount > payment.authorized {
+		return PaymentResult{Reference: reference, Status: "FAILED"}, ErrPaymentState
+	}
+
+	payment.captured = amount
+	payment.status = "CAPTURED"
+	return PaymentResult{Reference: reference, Status: "CAPTURED", Message: "Captured"}, nil
+}
+
+func (m *MockPaymentProvider) Refund(ctx context.Context, reference string, amount float64) (PaymentResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	payment, ok := m.payments[reference]
+	if !ok || payment.captured == 0 || payment.refunded+amount > payment.captured {
+		return PaymentResult{Reference: reference, Status: "FAILED"}, ErrPaymentState
+	}
+
+	payment.refunded += amount
+	if payment.refunded >= payment.captured {
+		payment.status = "REFUNDED"
+	}
+	return PaymentResult{Reference: reference, Status: payment.status, Message: "Refunded"}, nil
+}
+
+func (m *MockPaymentProvider) Void(ctx context.Context, reference string) (PaymentResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	payment, ok := m.payments[reference]
+	if !ok || payment.status != "AUTHORIZED" {
+		return PaymentResult{Reference: reference, Status: "FAILED"}, ErrPaymentState
+	}
+
+	payment.status = "VOIDED"
+	return PaymentResult{Reference: reference, Status: "VOIDED", Message: "Voided"}, nil
+}
+
+func (m *MockPaymentProvider) VerifyWebhook(payload []byte, signature string) (PaymentWebhookEvent, error) {
+	var event PaymentWebhookEvent
+
+	expected := MockWebhookSignature(payload)
+	if expected == "" || !hmac.Equal([]byte(expected), []byte(signature)) {
+		return event, ErrWebhookInvalid
+	}
+
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return event, fmt.Errorf("decoding webhook: %w", err)
+	}
+	return event, nil
+}
+
+// MockWebhookSignature signs a payload the way the mock gateway would, so
+// webhooks can be simulated with curl. It returns "" without a secret.
+func MockWebhookSignature(payload []byte) string {
+	secret := EnvString("MOCK_PAYMENT_WEBHOOK_SECRET", "")
+	if secret == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
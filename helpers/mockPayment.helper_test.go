@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+)
+
+const mockWebhookPayload = `{"reference":"mock_1","status":"CAPTURED","amount":12.5}`
+
+// mockWebhookSignature is the HMAC-SHA256 of mockWebhookPayload keyed with
+// "whsec_test".
+const mockWebhookSignature = "c40d4ee532e75d6c9461d846b4420b2b4dbcbbac225d4575e93826f124745fef"
+
+func TestMockWebhookSignature(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		want   string
+	}{
+		{name: "signs with the secret", secret: "whsec_test", want: mockWebhookSignature},
+		{name: "empty without a secret", secret: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MOCK_PAYMENT_WEBHOOK_SECRET", tt.secret)
+			if got := MockWebhookSignature([]byte(mockWebhookPayload)); got != tt.want {
+				t.Errorf("MockWebhookSignature() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockVerifyWebhook(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		payload   string
+		signature string
+		wantErr   error
+	}{
+		{name: "valid signature", secret: "whsec_test", payload: mockWebhookPayload, signature: mockWebhookSignature},
+		{name: "wrong signature", secret: "whsec_test", payload: mockWebhookPayload, signature: "deadbeef", wantErr: ErrWebhookInvalid},
+		{name: "tampered payload", secret: "whsec_test", payload: `{"reference":"mock_1","status":"CAPTURED","amount":1250}`, signature: mockWebhookSignature, wantErr: ErrWebhookInvalid},
+		{name: "other secret", secret: "whsec_other", payload: mockWebhookPayload, signature: mockWebhookSignature, wantErr: ErrWebhookInvalid},
+		{name: "no secret accepts nothing", secret: "", payload: mockWebhookPayload, signature: "", wantErr: ErrWebhookInvalid},
+	}
+
+	provider := &MockPaymentProvider{payments: map[string]*mockPayment{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MOCK_PAYMENT_WEBHOOK_SECRET", tt.secret)
+
+			event, err := provider.VerifyWebhook([]byte(tt.payload), tt.signature)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyWebhook() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (event.Reference != "mock_1" || event.Status != "CAPTURED" || event.Amount != 12.5) {
+				t.Errorf("VerifyWebhook() event = %+v", event)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Notifier delivers messages to users, such as password reset links. The
+// log and file implementations are meant for local use; real channels
+// like email or SMS register themselves the same way and are picked with
+// NOTIFIER_DRIVER.
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, notification Notification) error
+}
+
+type Notification struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	Sent_At time.Time `json:"sent_at"`
+}
+
+var (
+	notifiersMu sync.RWMutex
+	notifiers   = map[string]Notifier{}
+)
+
+func RegisterNotifier(notifier Notifier) {
+	notifiersMu.Lock()
+	defer notifiersMu.Unlock()
+	notifiers[notifier.Name()] = notifier
+}
+
+// GetNotifier returns the notifier named by NOTIFIER_DRIVER, which
+// defaults to writing to the server log.
+func GetNotifier() (Notifier, error) {
+	name := EnvString("NOTIFIER_DRIVER", "log")
+
+	notifiersMu.RLock()
+	defer notifiersMu.RUnlock()
+
+	notifier, ok := notifiers[name]
+	if !ok {
+		return nil, fmt.Errorf("notifier %q is not registered", name)
+	}
+	return notifier, nil
+}
+
+// LogNotifier prints notifications to the server log.
+type LogNotifier struct{}
+
+func (LogNotifier) Name() string { return "log" }
+
+func (LogNotifier) Send(ctx context.Context, notification Notification) error {
+	log.Printf("Notification to %s: %s\n%s", notification.To, notification.Subject, notification.Body)
+	return nil
+}
+
+// FileNotifier appends notifications as JSON lines to NOTIFIER_FILE
+// (default "notifications.log").
+type FileNotifier struct {
+	mu sync.Mutex
+}
+
+func (*FileNotifier) Name() string { return "file" }
+
+func (n *FileNotifier) Send(ctx context.Context, notification Notification) error {
+	if notification.Sent_At.IsZero() {
+		notification.Sent_At = time.Now().UTC()
+	}
+	line, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(EnvString("NOTIFIER_FILE", "notifications.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func init() {
+	RegisterNotifier(LogNotifier{})
+	RegisterNotifier(&FileNotifier{})
+}
@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PaymentProvider is implemented by each card gateway. Amounts are in the
+// invoice currency's major unit, matching the rest of the invoice fields.
+type PaymentProvider interface {
+	Name() string
+	Authorize(ctx context.Context, req PaymentRequest) (PaymentResult, error)
+	Capture(ctx context.Context, reference string, amount float64) (PaymentResult, error)
+	Refund(ctx context.Context, reference string, amount float64) (PaymentResult, error)
+	Void(ctx context.Context, reference string) (PaymentResult, error)
+	// VerifyWebhook checks the signature of a gateway callback and decodes it.
+	VerifyWebhook(payload []byte, signature string) (PaymentWebhookEvent, error)
+}
+
+type PaymentRequest struct {
+	Amount          float64
+	Currency        string
+	Card_Token      string
+	Idempotency_Key string
+	Description     string
+}
+
+type PaymentResult struct {
+	Reference    string
+	Status       string
+	Decline_Code string
+	Message      string
+}
+
+type PaymentWebhookEvent struct {
+	Reference string  `json:"reference"`
+	Status    string  `json:"status"`
+	Amount    float64 `json:"amount"`
+	Message   string  `json:"message"`
+}
+
+var (
+	ErrPaymentDeclined = errors.New("payment declined")
+	ErrPaymentTimeout  = errors.New("payment provider timed out")
+	ErrPaymentState    = errors.New("payment is not in a state that allows this operation")
+	ErrWebhookInvalid  = errors.New("webhook signature is invalid")
+)
+
+var (
+	paymentProvidersMu sync.RWMutex
+	paymentProviders   = map[string]PaymentProvider{}
+)
+
+// RegisterPaymentProvider makes a gateway available by name. Real gateways
+// register themselves at startup the same way the mock provider does.
+func RegisterPaymentProvider(provider PaymentProvider) {
+	paymentProvidersMu.Lock()
+	defer paymentProvidersMu.Unlock()
+	paymentProviders[provider.Name()] = provider
+}
+
+// GetPaymentProvider looks up a gateway by name. An empty name selects
+// PAYMENT_PROVIDER, which defaults to the mock provider.
+func GetPaymentProvider(name string) (PaymentProvider, error) {
+	if name == "" {
+		name = EnvString("PAYMENT_PROVIDER", "mock")
+	}
+
+	paymentProvidersMu.RLock()
+	defer paymentProvidersMu.RUnlock()
+
+	provider, ok := paymentProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("payment provider %q is not registered", name)
+	}
+	return provider, nil
+}
+
+// PaymentCallTimeout bounds each call to a gateway (PAYMENT_TIMEOUT_SECONDS).
+func PaymentCallTimeout() time.Duration {
+	return time.Duration(EnvInt("PAYMENT_TIMEOUT_SECONDS", 8)) * time.Second
+}
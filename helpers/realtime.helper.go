@@ -0,0 +1,183 @@
+package helpers
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RealtimeMessage is what connected tablets receive. An event carries every
+// topic it was published to and a single seq, so a client subscribed to
+// several of them sees it once.
+type RealtimeMessage struct {
+	Type   string       `json:"type"`
+	Seq    uint64       `json:"seq,omitempty"`
+	Topics []string     `json:"topics,omitempty"`
+	Event  *DomainEvent `json:"event,omitempty"`
+	At     time.Time    `json:"at"`
+}
+
+// RealtimeClient is one connection's subscription state. Messages are
+// queued on Send; a client that falls too far behind is disconnected and
+// can resume from its last sequence number.
+type RealtimeClient struct {
+	Send   chan RealtimeMessage
+	Closed chan struct{}
+	topics map[string]bool
+}
+
+// RealtimeHub fans messages out to subscribed clients and keeps the most
+// recent ones so a reconnecting client can catch up. Sequence numbers are
+// per process; after a restart clients are asked to resync.
+type RealtimeHub struct {
+	mu      sync.Mutex
+	seq     uint64
+	clients map[*RealtimeClient]bool
+	buffer  []RealtimeMessage
+	size    int
+}
+
+var Realtime = NewRealtimeHub(1000)
+
+func NewRealtimeHub(size int) *RealtimeHub {
+	return &RealtimeHub{clients: map[*RealtimeClient]bool{}, size: size}
+}
+
+// Register adds a client. When since is non-zero the buffered messages after
+// it are returned for replay; resync is true if some have already been
+// dropped from the buffer, so the client should reload its state instead.
+func (h *RealtimeHub) Register(topics []string, since uint64) (client *RealtimeClient, replay []RealtimeMessage, resync bool) {
+	client = &RealtimeClient{
+		Send:   make(chan RealtimeMessage, 64),
+		Closed: make(chan struct{}),
+		topics: map[string]bool{},
+	}
+	for _, topic := range topics {
+		client.topics[topic] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if since > 0 {
+		if since > h.seq || (len(h.buffer) > 0 && h.buffer[0].Seq > since+1) || (len(h.buffer) == 0 && since < h.seq) {
+			resync = true
+		} else {
+			for _, message := range h.buffer {
+				if message.Seq > since && client.subscribedTo(message.Topics) {
+					replay = append(replay, message)
+				}
+			}
+		}
+	}
+
+	h.clients[client] = true
+	return client, replay, resync
+}
+
+func (h *RealtimeHub) Unregister(client *RealtimeClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients[client] {
+		delete(h.clients, client)
+		close(client.Closed)
+	}
+}
+
+func (h *RealtimeHub) Subscribe(client *RealtimeClient, topics []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, topic := range topics {
+		client.topics[topic] = true
+	}
+}
+
+func (h *RealtimeHub) Unsubscribe(client *RealtimeClient, topics []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, topic := range topics {
+		delete(client.topics, topic)
+	}
+}
+
+func (h *RealtimeHub) Topics(client *RealtimeClient) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	topics := make([]string, 0, len(client.topics))
+	for topic := range client.topics {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// Seq is the last sequence number handed out.
+func (h *RealtimeHub) Seq() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.seq
+}
+
+// Publish sends an event once to every client subscribed to any of the
+// topics.
+func (h *RealtimeHub) Publish(topics []string, event DomainEvent) {
+	if len(topics) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	message := RealtimeMessage{Type: "event", Seq: h.seq, Topics: topics, Event: &event, At: time.Now().UTC()}
+
+	h.buffer = append(h.buffer, message)
+	if len(h.buffer) > h.size {
+		h.buffer = h.buffer[len(h.buffer)-h.size:]
+	}
+
+	for client := range h.clients {
+		if !client.subscribedTo(topics) {
+			continue
+		}
+		select {
+		case client.Send <- message:
+		default:
+			delete(h.clients, client)
+			close(client.Closed)
+		}
+	}
+}
+
+func (c *RealtimeClient) subscribedTo(topics []string) bool {
+	for _, topic := range topics {
+		if c.topics[topic] {
+			return true
+		}
+	}
+	return false
+}
+
+// RealtimeOriginAllowed checks a WebSocket request's Origin against
+// REALTIME_ALLOWED_ORIGINS, a comma-separated list such as
+// "https://pos.example.com". Without the setting only the API's own host is
+// allowed. Requests without an Origin don't come from a browser and are
+// left to the token check.
+func RealtimeOriginAllowed(origin, host string) bool {
+	if origin == "" {
+		return true
+	}
+
+	if allowed := EnvString("REALTIME_ALLOWED_ORIGINS", ""); allowed != "" {
+		for _, candidate := range strings.Split(allowed, ",") {
+			if strings.EqualFold(strings.TrimRight(strings.TrimSpace(candidate), "/"), origin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	parsed, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(parsed.Host, host)
+}
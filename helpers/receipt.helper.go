@@ -0,0 +1,171 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/models"
+)
+
+// ReceiptConfig holds the restaurant-specific parts of printed output.
+type ReceiptConfig struct {
+	Header []string
+	Footer []string
+	Width  int
+	QRURL  string
+}
+
+// ReceiptConfigFromEnv reads RECEIPT_HEADER and RECEIPT_FOOTER (lines
+// separated by "|"), RECEIPT_WIDTH in characters and RECEIPT_QR_URL, a URL
+// with {invoice_id} where the invoice id goes.
+func ReceiptConfigFromEnv() ReceiptConfig {
+	return ReceiptConfig{
+		Header: splitLines(EnvString("RECEIPT_HEADER", "Restaurant")),
+		Footer: splitLines(EnvString("RECEIPT_FOOTER", "Thank you for dining with us!")),
+		Width:  EnvInt("RECEIPT_WIDTH", 42),
+		QRURL:  EnvString("RECEIPT_QR_URL", ""),
+	}
+}
+
+// receiptQRURL fills the invoice id into the RECEIPT_QR_URL template. The
+// first %s stands in for {invoice_id} in templates written before it.
+func receiptQRURL(template, invoiceId string) string {
+	if strings.Contains(template, "{invoice_id}") {
+		return strings.ReplaceAll(template, "{invoice_id}", invoiceId)
+	}
+	return strings.Replace(template, "%s", invoiceId, 1)
+}
+
+// KitchenTicketHeader describes the order a kitchen ticket is for. Takeaway
+// and delivery tickets carry the time promised to the customer.
+type KitchenTicketHeader struct {
+	OrderId    string
+	OrderType  string
+	Table      string
+	Customer   string
+	PlacedAt   time.Time
+	PromisedAt *time.Time
+}
+
+// KitchenTicketItem is one line on a kitchen ticket.
+type KitchenTicketItem struct {
+	Name     string
+	Quantity string
+}
+
+func ReceiptDocument(invoice *models.Invoice, cfg ReceiptConfig) *PrintDocument {
+	doc := NewPrintDocument(cfg.Width)
+
+	for i, line := range cfg.Header {
+		if i == 0 {
+			doc.Title(line)
+		} else {
+			doc.Text(line, AlignCenter, false)
+		}
+	}
+	doc.Separator()
+
+	doc.Row("Invoice", InvoiceDisplayNumber(invoice), false)
+	doc.Row("Date", invoice.Created_At.Local().Format("2006-01-02 15:04"), false)
+	doc.Separator()
+
+	for _, item := range invoice.Line_Items {
+		doc.Row(fmt.Sprintf("%d x %s", item.Quantity, item.Name), Money(item.Line_Total), false)
+	}
+	doc.Separator()
+
+	doc.Row("Subtotal", Money(invoice.Sub_Total), false)
+	for _, promotion := range invoice.Applied_Promotions {
+		doc.Row(promotion.Name, "-"+Money(promotion.Amount), false)
+	}
+	if invoice.Delivery_Fee > 0 {
+		doc.Row("Delivery", Money(invoice.Delivery_Fee), false)
+	}
+	if invoice.Tax_Amount > 0 || invoice.Tax_Rate > 0 {
+		doc.Row(fmt.Sprintf("%s %g%%", invoice.Tax_Name, invoice.Tax_Rate), Money(invoice.Tax_Amount), false)
+	}
+	doc.Row("TOTAL", Money(invoice.Total_Amount), true)
+	if invoice.Points_Value > 0 {
+		doc.Row(fmt.Sprintf("Points (%d)", invoice.Points_Redeemed), "-"+Money(invoice.Points_Value), false)
+		doc.Row("Amount due", Money(invoice.Amount_Due), true)
+	}
+	if invoice.Tip_Amount > 0 {
+		doc.Row("Tip", Money(invoice.Tip_Amount), false)
+	}
+
+	if invoice.Payment_Status != nil {
+		status := *invoice.Payment_Status
+		if invoice.Payment_Method != nil && *invoice.Payment_Method != "" {
+			status += " (" + *invoice.Payment_Method + ")"
+		}
+		doc.Row("Payment", status, false)
+	}
+	doc.Separator()
+
+	if cfg.QRURL != "" {
+		doc.QRCode(receiptQRURL(cfg.QRURL, invoice.Invoice_Id))
+	}
+	for _, line := range cfg.Footer {
+		doc.Text(line, AlignCenter, false)
+	}
+
+	return doc
+}
+
+func KitchenTicketDocument(header KitchenTicketHeader, items []KitchenTicketItem, cfg ReceiptConfig) *PrintDocument {
+	doc := NewPrintDocument(cfg.Width)
+
+	switch {
+	case header.OrderType == "TAKEAWAY" || header.OrderType == "DELIVERY":
+		doc.Title(header.OrderType)
+	case header.Table != "":
+		doc.Title("TABLE " + header.Table)
+	default:
+		doc.Title("KITCHEN")
+	}
+	doc.Row("Order", header.OrderId, false)
+	if header.Customer != "" {
+		doc.Row("Customer", header.Customer, false)
+	}
+	doc.Row("Time", header.PlacedAt.Local().Format("15:04"), false)
+	if header.PromisedAt != nil {
+		label := "Pickup"
+		if header.OrderType == "DELIVERY" {
+			label = "Deliver by"
+		}
+		doc.Row(label, header.PromisedAt.Local().Format("15:04"), true)
+	}
+	doc.Separator()
+
+	for _, item := range items {
+		doc.Text(fmt.Sprintf("%s x %s", item.Quantity, item.Name), AlignLeft, true)
+	}
+	doc.Separator()
+	doc.Text(time.Now().Local().Format("Printed 2006-01-02 15:04:05"), AlignCenter, false)
+
+	return doc
+}
+
+// InvoiceDisplayNumber is the legal invoice number, falling back to the
+// internal id for invoices created before numbering existed.
+func InvoiceDisplayNumber(invoice *models.Invoice) string {
+	if invoice.Invoice_Number != "" {
+		return invoice.Invoice_Number
+	}
+	return invoice.Invoice_Id
+}
+
+func Money(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+func splitLines(value string) []string {
+	var lines []string
+	for _, line := range strings.Split(value, "|") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
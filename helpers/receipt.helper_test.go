@@ -0,0 +1,22 @@
+package helpers
+
+import "testing"
+
+func TestReceiptQRURL(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"https://example.com/r/{invoice_id}", "https://example.com/r/inv1"},
+		{"https://example.com/r?id={invoice_id}&again={invoice_id}", "https://example.com/r?id=inv1&again=inv1"},
+		{"https://example.com/r/%s", "https://example.com/r/inv1"},
+		{"https://example.com/feedback", "https://example.com/feedback"},
+		{"https://example.com/a%20b/{invoice_id}", "https://example.com/a%20b/inv1"},
+	}
+
+	for _, tt := range tests {
+		if got := receiptQRURL(tt.template, "inv1"); got != tt.want {
+			t.Errorf("receiptQRURL(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,491 @@
+package helpers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Tokens are signed with the newest active key of a keyring kept in the
+// signing_key collection and carry its kid. JWT_ALGORITHM picks RS256 or
+// EdDSA (the default) for new keys; HS256 signs with SECRET_KEY alone and
+// publishes no keys.
+//
+// Every JWT_ROTATION_DAYS (default 30) a new key is generated. It is
+// published keyPrepublish before it starts signing, and the key it
+// replaces keeps verifying until every token it signed has expired.
+//
+// After switching from HS256, tokens signed with SECRET_KEY are only
+// accepted until JWT_LEGACY_HS256_UNTIL (an RFC 3339 time), so the old
+// shared secret stops being a valid signer once they have expired.
+var signingKeyModel *mongo.Collection = database.OpenCollection(database.MongoClient, "signing_key")
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmHS256 = "HS256"
+)
+
+const (
+	keyPrepublish     = time.Hour
+	keyReloadInterval = time.Minute
+	rsaKeyBits        = 2048
+)
+
+// tokenMaxLifetime is the longest a token lives, the refresh token's
+// lifetime. A replaced key is kept this long after it stops signing.
+const tokenMaxLifetime = 168 * time.Hour
+
+var ErrNoSigningKey = errors.New("no token signing key is configured")
+
+type signingKey struct {
+	kid        string
+	algorithm  string
+	private    crypto.Signer
+	public     crypto.PublicKey
+	activeFrom time.Time
+	// retiredAt is when a newer key took over, zero while still current.
+	retiredAt time.Time
+}
+
+type keyring struct {
+	mu   sync.RWMutex
+	keys []*signingKey
+}
+
+var signingKeys keyring
+
+func jwtAlgorithm() string {
+	return EnvString("JWT_ALGORITHM", AlgorithmEdDSA)
+}
+
+func jwtRotationInterval() time.Duration {
+	return time.Duration(EnvInt("JWT_ROTATION_DAYS", 30)) * 24 * time.Hour
+}
+
+// legacySecret is SECRET_KEY, used to sign in HS256 mode and to accept
+// HS256 tokens issued before asymmetric keys were turned on.
+func legacySecret() []byte {
+	return []byte(os.Getenv("SECRET_KEY"))
+}
+
+// acceptsLegacyTokens reports whether tokens without a kid, signed with
+// SECRET_KEY, are still valid: always in HS256 mode, otherwise only before
+// JWT_LEGACY_HS256_UNTIL.
+func acceptsLegacyTokens(now time.Time) bool {
+	if len(legacySecret()) == 0 {
+		return false
+	}
+	if jwtAlgorithm() == AlgorithmHS256 {
+		return true
+	}
+	until, err := time.Parse(time.RFC3339, os.Getenv("JWT_LEGACY_HS256_UNTIL"))
+	return err == nil && now.Before(until)
+}
+
+// InitSigningKeys loads the keyring and fails when tokens couldn't be
+// signed. With JWT_AUTO_ROTATE (default true) the first key is generated
+// when there is none.
+func InitSigningKeys(ctx context.Context) error {
+	switch jwtAlgorithm() {
+	case AlgorithmHS256:
+		if len(legacySecret()) == 0 {
+			return fmt.Errorf("%w: JWT_ALGORITHM is HS256 but SECRET_KEY is empty", ErrNoSigningKey)
+		}
+		if len(legacySecret()) < 32 {
+			log.Println("SECRET_KEY is shorter than 32 bytes, use a longer random value")
+		}
+		return nil
+	case AlgorithmRS256, AlgorithmEdDSA:
+	default:
+		return fmt.Errorf("unsupported JWT_ALGORITHM %q, use RS256, EdDSA or HS256", jwtAlgorithm())
+	}
+
+	if err := loadSigningKeys(ctx); err != nil {
+		return err
+	}
+	if os.Getenv("JWT_AUTO_ROTATE") != "false" {
+		if err := rotateIfDue(ctx); err != nil {
+			return err
+		}
+	}
+	if currentSigningKey() == nil {
+		return fmt.Errorf("%w: run `keys rotate` or enable JWT_AUTO_ROTATE", ErrNoSigningKey)
+	}
+	return nil
+}
+
+// StartKeyRotation reloads the keyring periodically, picking up keys added
+// by other servers, and adds the next key when rotation is due.
+func StartKeyRotation(ctx context.Context) {
+	if jwtAlgorithm() == AlgorithmHS256 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(keyReloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reloadCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+				if err := loadSigningKeys(reloadCtx); err != nil {
+					log.Printf("Error reloading signing keys: %v", err)
+				}
+				if os.Getenv("JWT_AUTO_ROTATE") != "false" {
+					if err := rotateIfDue(reloadCtx); err != nil {
+						log.Printf("Error rotating signing keys: %v", err)
+					}
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// RotateSigningKey adds a key using JWT_ALGORITHM. It starts signing after
+// the prepublish delay, or straight away when immediate is set, which
+// suits the first key or replacing a leaked one.
+func RotateSigningKey(ctx context.Context, immediate bool) (*models.SigningKey, error) {
+	algorithm := jwtAlgorithm()
+	if algorithm == AlgorithmHS256 {
+		return nil, errors.New("HS256 uses SECRET_KEY and has no keys to rotate")
+	}
+
+	var newest models.SigningKey
+	err := signingKeyModel.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "generation", Value: -1}})).Decode(&newest)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	private, err := generatePrivateKey(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+
+	now := time.Now().UTC()
+	key := models.SigningKey{
+		ID:          primitive.NewObjectID(),
+		Generation:  newest.Generation + 1,
+		Algorithm:   algorithm,
+		Private_Key: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		Public_Key:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		Active_From: now.Add(keyPrepublish),
+		Created_At:  now,
+	}
+	key.Kid = fmt.Sprintf("k%d-%s", key.Generation, hex.EncodeToString(suffix))
+	if immediate || newest.Generation == 0 {
+		key.Active_From = now
+	}
+
+	// The unique generation index makes a concurrent rotation on another
+	// server fail here instead of adding two keys.
+	if _, err := signingKeyModel.InsertOne(ctx, key); err != nil {
+		return nil, err
+	}
+	if err := loadSigningKeys(ctx); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// PruneSigningKeys deletes keys that no token can still be signed with.
+func PruneSigningKeys(ctx context.Context) (int, error) {
+	if err := loadSigningKeys(ctx); err != nil {
+		return 0, err
+	}
+
+	signingKeys.mu.RLock()
+	var expired []string
+	for _, key := range signingKeys.keys {
+		if !key.verifies(time.Now()) {
+			expired = append(expired, key.kid)
+		}
+	}
+	signingKeys.mu.RUnlock()
+
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	result, err := signingKeyModel.DeleteMany(ctx, bson.M{"kid": bson.M{"$in": expired}})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.DeletedCount), loadSigningKeys(ctx)
+}
+
+// ListSigningKeys returns the stored keys, oldest first.
+func ListSigningKeys(ctx context.Context) ([]models.SigningKey, error) {
+	cursor, err := signingKeyModel.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "generation", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var keys []models.SigningKey
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func rotateIfDue(ctx context.Context) error {
+	signingKeys.mu.RLock()
+	var newest *signingKey
+	if len(signingKeys.keys) > 0 {
+		newest = signingKeys.keys[len(signingKeys.keys)-1]
+	}
+	signingKeys.mu.RUnlock()
+
+	due := newest == nil ||
+		newest.algorithm != jwtAlgorithm() ||
+		time.Now().After(newest.activeFrom.Add(jwtRotationInterval()-keyPrepublish))
+	if !due {
+		return nil
+	}
+
+	key, err := RotateSigningKey(ctx, false)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return loadSigningKeys(ctx)
+		}
+		return err
+	}
+	log.Printf("Added signing key %s (%s), active from %s", key.Kid, key.Algorithm, key.Active_From.Format(time.RFC3339))
+	return nil
+}
+
+func loadSigningKeys(ctx context.Context) error {
+	stored, err := ListSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]*signingKey, 0, len(stored))
+	for _, record := range stored {
+		key, err := parseSigningKey(record)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", record.Kid, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	// A key retires when the next one starts signing.
+	sort.Slice(keys, func(i, j int) bool { return keys[i].activeFrom.Before(keys[j].activeFrom) })
+	for i := 0; i+1 < len(keys); i++ {
+		keys[i].retiredAt = keys[i+1].activeFrom
+	}
+
+	signingKeys.mu.Lock()
+	signingKeys.keys = keys
+	signingKeys.mu.Unlock()
+	return nil
+}
+
+func parseSigningKey(record models.SigningKey) (*signingKey, error) {
+	block, _ := pem.Decode([]byte(record.Private_Key))
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	switch signer.(type) {
+	case *rsa.PrivateKey:
+		if record.Algorithm != AlgorithmRS256 {
+			return nil, fmt.Errorf("RSA key stored as %s", record.Algorithm)
+		}
+	case ed25519.PrivateKey:
+		if record.Algorithm != AlgorithmEdDSA {
+			return nil, fmt.Errorf("Ed25519 key stored as %s", record.Algorithm)
+		}
+	default:
+		return nil, errors.New("unsupported private key type")
+	}
+
+	return &signingKey{
+		kid:        record.Kid,
+		algorithm:  record.Algorithm,
+		private:    signer,
+		public:     signer.Public(),
+		activeFrom: record.Active_From,
+	}, nil
+}
+
+func generatePrivateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		return rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		return private, err
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", algorithm)
+	}
+}
+
+// verifies reports whether tokens signed with the key are still accepted.
+func (k *signingKey) verifies(now time.Time) bool {
+	return k.retiredAt.IsZero() || now.Before(k.retiredAt.Add(tokenMaxLifetime))
+}
+
+func (k *signingKey) method() jwt.SigningMethod {
+	if k.algorithm == AlgorithmRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// currentSigningKey is the newest key that has started signing.
+func currentSigningKey() *signingKey {
+	signingKeys.mu.RLock()
+	defer signingKeys.mu.RUnlock()
+
+	now := time.Now()
+	for i := len(signingKeys.keys) - 1; i >= 0; i-- {
+		if !signingKeys.keys[i].activeFrom.After(now) {
+			return signingKeys.keys[i]
+		}
+	}
+	return nil
+}
+
+func findSigningKey(kid string) *signingKey {
+	signingKeys.mu.RLock()
+	defer signingKeys.mu.RUnlock()
+
+	for _, key := range signingKeys.keys {
+		if key.kid == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// signToken signs claims with the current key, or SECRET_KEY in HS256
+// mode.
+func signToken(claims jwt.Claims) (string, error) {
+	if jwtAlgorithm() == AlgorithmHS256 {
+		if len(legacySecret()) == 0 {
+			return "", ErrNoSigningKey
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(legacySecret())
+	}
+
+	key := currentSigningKey()
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// parseToken verifies a token signed by signToken. Tokens without a kid
+// are HS256 tokens signed with SECRET_KEY, accepted while it is set so a
+// switch to asymmetric keys doesn't sign everyone out.
+func parseToken(signedToken string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append(opts, jwt.WithValidMethods([]string{AlgorithmRS256, AlgorithmEdDSA, AlgorithmHS256}))
+	return jwt.ParseWithClaims(signedToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			if token.Method.Alg() != AlgorithmHS256 || !acceptsLegacyTokens(time.Now()) {
+				return nil, errors.New("token has no key id")
+			}
+			return legacySecret(), nil
+		}
+
+		key := findSigningKey(kid)
+		if key == nil || !key.verifies(time.Now()) {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.algorithm {
+			return nil, errors.New("token algorithm does not match its key")
+		}
+		return key.public, nil
+	}, opts...)
+}
+
+// JSONWebKey is the public half of a signing key in RFC 7517 form.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns every key a verifier may meet: the current one, keys that
+// are about to take over and retired keys whose tokens may still be live.
+func JWKS() []JSONWebKey {
+	signingKeys.mu.RLock()
+	defer signingKeys.mu.RUnlock()
+
+	now := time.Now()
+	keys := []JSONWebKey{}
+	for _, key := range signingKeys.keys {
+		if !key.verifies(now) {
+			continue
+		}
+
+		jwk := JSONWebKey{Kid: key.kid, Use: "sig", Alg: key.algorithm}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	return keys
+}
@@ -0,0 +1,165 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStorage keeps uploaded files under slash-separated keys. The local
+// filesystem implementation is registered by default; other backends
+// register themselves the same way and are picked with STORAGE_DRIVER.
+type FileStorage interface {
+	Name() string
+	Save(ctx context.Context, key string, r io.Reader, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, FileInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type FileInfo struct {
+	Content_Type string
+	Size         int64
+	Modified     time.Time
+}
+
+var (
+	ErrFileNotFound = errors.New("file not found")
+	ErrInvalidKey   = errors.New("invalid file key")
+)
+
+var (
+	fileStoragesMu sync.RWMutex
+	fileStorages   = map[string]FileStorage{}
+)
+
+func RegisterFileStorage(storage FileStorage) {
+	fileStoragesMu.Lock()
+	defer fileStoragesMu.Unlock()
+	fileStorages[storage.Name()] = storage
+}
+
+// GetFileStorage returns the backend named by STORAGE_DRIVER, which
+// defaults to the local filesystem.
+func GetFileStorage() (FileStorage, error) {
+	name := EnvString("STORAGE_DRIVER", "local")
+
+	fileStoragesMu.RLock()
+	defer fileStoragesMu.RUnlock()
+
+	storage, ok := fileStorages[name]
+	if !ok {
+		return nil, fmt.Errorf("file storage %q is not registered", name)
+	}
+	return storage, nil
+}
+
+// CleanStorageKey rejects keys that are empty, absolute or climb out of
+// the storage root.
+func CleanStorageKey(key string) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if key == "" || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || strings.HasPrefix(cleaned, "../") || cleaned == ".." {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
+
+// LocalFileStorage stores files below STORAGE_LOCAL_DIR (default
+// "uploads"), read on each call so it follows the loaded .env.
+type LocalFileStorage struct{}
+
+func init() {
+	RegisterFileStorage(LocalFileStorage{})
+}
+
+func (LocalFileStorage) Name() string {
+	return "local"
+}
+
+func (LocalFileStorage) path(key string) (string, error) {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(EnvString("STORAGE_LOCAL_DIR", "uploads"), filepath.FromSlash(key)), nil
+}
+
+// Save writes to a temporary file first so readers never see a partial
+// upload.
+func (s LocalFileStorage) Save(ctx context.Context, key string, r io.Reader, contentType string) error {
+	target, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func (s LocalFileStorage) Open(ctx context.Context, key string) (io.ReadCloser, FileInfo, error) {
+	target, err := s.path(key)
+	if err != nil {
+		return nil, FileInfo{}, err
+	}
+
+	file, err := os.Open(target)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, FileInfo{}, ErrFileNotFound
+		}
+		return nil, FileInfo{}, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		file.Close()
+		return nil, FileInfo{}, ErrFileNotFound
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(target))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return file, FileInfo{Content_Type: contentType, Size: stat.Size(), Modified: stat.ModTime()}, nil
+}
+
+func (s LocalFileStorage) Delete(ctx context.Context, key string) error {
+	target, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
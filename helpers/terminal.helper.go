@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var terminalModel *mongo.Collection = database.OpenCollection(database.MongoClient, "terminal")
+
+var ErrUnknownTerminal = errors.New("terminal is not registered or has been deactivated")
+
+// terminalTouchInterval limits how often activity is written back, so a
+// busy terminal doesn't update its document on every request.
+const terminalTouchInterval = 30 * time.Second
+
+func HashTerminalKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// TerminalIdleTimeout is how long a terminal stays unlocked without
+// requests, TERMINAL_IDLE_MINUTES (default 5).
+func TerminalIdleTimeout() time.Duration {
+	return time.Duration(EnvInt("TERMINAL_IDLE_MINUTES", 5)) * time.Minute
+}
+
+// VerifyTerminal returns the active terminal whose key matches.
+func VerifyTerminal(ctx context.Context, terminalId, key string) (*models.Terminal, error) {
+	if terminalId == "" || key == "" {
+		return nil, ErrUnknownTerminal
+	}
+
+	var terminal models.Terminal
+	err := terminalModel.FindOne(ctx, bson.M{"terminal_id": terminalId, "active": true}).Decode(&terminal)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrUnknownTerminal
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(terminal.Key_Hash), []byte(HashTerminalKey(key))) != 1 {
+		return nil, ErrUnknownTerminal
+	}
+	return &terminal, nil
+}
+
+// checkTerminalSession rejects a PIN-login token once another user has
+// signed in on the terminal, it was locked, or it sat idle too long.
+func checkTerminalSession(ctx context.Context, claims *SignedDetails) string {
+	var terminal models.Terminal
+	err := terminalModel.FindOne(ctx, bson.M{
+		"terminal_id":        claims.TerminalId,
+		"active":             true,
+		"session.session_id": claims.TerminalSession,
+	}).Decode(&terminal)
+	if err != nil {
+		return "terminal session has ended"
+	}
+
+	now := time.Now().UTC()
+	idle := now.Sub(terminal.Session.Last_Activity_At)
+	if idle > TerminalIdleTimeout() {
+		terminalModel.UpdateOne(ctx,
+			bson.M{"terminal_id": claims.TerminalId, "session.session_id": claims.TerminalSession},
+			bson.M{"$set": bson.M{"session": nil, "updated_at": now}},
+		)
+		return "terminal is locked"
+	}
+
+	if idle > terminalTouchInterval {
+		terminalModel.UpdateOne(ctx,
+			bson.M{"terminal_id": claims.TerminalId, "session.session_id": claims.TerminalSession},
+			bson.M{"$set": bson.M{"session.last_activity_at": now, "last_seen_at": now}},
+		)
+	}
+	return ""
+}
@@ -3,7 +3,6 @@ package helpers
 import (
 	"context"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/djwhocodes/restaurant_management/database"
@@ -18,11 +17,17 @@ type SignedDetails struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	Uid       string `json:"uid"`
+	// Tokens from a PIN login are only valid on the terminal that issued
+	// them, and only while its session is the current one.
+	TerminalId      string `json:"terminal_id,omitempty"`
+	TerminalSession string `json:"terminal_session,omitempty"`
+	// Role is not part of the signed token; ValidateToken fills it in from
+	// the user's current record.
+	Role string `json:"-"`
 	jwt.RegisteredClaims
 }
 
 var userModel *mongo.Collection = database.OpenCollection(database.MongoClient, "user")
-var SECRET_KEY = os.Getenv("SECRET_KEY")
 
 func GenerateAllTokens(email, firstName, lastName, uid string) (accessToken, refreshToken string, err error) {
 	accessClaims := &SignedDetails{
@@ -38,20 +43,17 @@ func GenerateAllTokens(email, firstName, lastName, uid string) (accessToken, ref
 
 	refreshClaims := &SignedDetails{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(168 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenMaxLifetime)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	accessTokenObj := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	refreshTokenObj := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-
-	accessToken, err = accessTokenObj.SignedString([]byte(SECRET_KEY))
+	accessToken, err = signToken(accessClaims)
 	if err != nil {
 		return "", "", err
 	}
 
-	refreshToken, err = refreshTokenObj.SignedString([]byte(SECRET_KEY))
+	refreshToken, err = signToken(refreshClaims)
 	if err != nil {
 		return "", "", err
 	}
@@ -59,6 +61,30 @@ func GenerateAllTokens(email, firstName, lastName, uid string) (accessToken, ref
 	return accessToken, refreshToken, nil
 }
 
+// GenerateTerminalToken issues a short-lived access token for a PIN login.
+// There is no refresh token; staff unlock the terminal with their PIN again.
+func GenerateTerminalToken(email, firstName, lastName, uid, terminalId, sessionId string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(time.Duration(EnvInt("PIN_TOKEN_TTL_MINUTES", 60)) * time.Minute)
+	claims := &SignedDetails{
+		Email:           email,
+		FirstName:       firstName,
+		LastName:        lastName,
+		Uid:             uid,
+		TerminalId:      terminalId,
+		TerminalSession: sessionId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err := signToken(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
 func UpdateAllTokens(accessToken, refreshToken, userId string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -89,21 +115,17 @@ func UpdateAllTokens(accessToken, refreshToken, userId string) {
 }
 
 func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
-	token, err := jwt.ParseWithClaims(
-		signedToken,
-		&SignedDetails{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(SECRET_KEY), nil
-		},
-	)
+	token, err := parseToken(signedToken, &SignedDetails{})
 
 	if err != nil {
 		msg = err.Error()
 		return
 	}
 
+	// Access tokens carry no subject; 2FA challenge tokens, signed with
+	// the same keys, do.
 	claims, ok := token.Claims.(*SignedDetails)
-	if !ok {
+	if !ok || claims.Subject != "" || claims.ExpiresAt == nil {
 		msg = "token is invalid"
 		return
 	}
@@ -113,5 +135,53 @@ func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user struct {
+		Tokens_Valid_After time.Time `bson:"tokens_valid_after"`
+		Role               *string   `bson:"role"`
+		Active             *bool     `bson:"active"`
+	}
+	projection := bson.M{"tokens_valid_after": 1, "role": 1, "active": 1}
+	err = userModel.FindOne(ctx, bson.M{"user_id": claims.Uid}, options.FindOne().SetProjection(projection)).Decode(&user)
+	if err != nil {
+		msg = "token is invalid"
+		return
+	}
+	if user.Active != nil && !*user.Active {
+		msg = "account is deactivated"
+		return
+	}
+	if user.Role != nil {
+		claims.Role = *user.Role
+	}
+	if claims.IssuedAt == nil || claims.IssuedAt.Time.Before(user.Tokens_Valid_After) {
+		msg = "token has been revoked"
+		return
+	}
+
+	if claims.TerminalId != "" {
+		if msg = checkTerminalSession(ctx, claims); msg != "" {
+			return
+		}
+	}
+
 	return claims, msg
 }
+
+// RevokeTokens invalidates every token issued so far to the users matching
+// filter. Token timestamps have second precision, so the cut-off is
+// truncated to the second to keep tokens issued right after it valid.
+func RevokeTokens(ctx context.Context, filter bson.M) (int64, error) {
+	result, err := userModel.UpdateMany(ctx, filter, bson.M{"$set": bson.M{
+		"tokens_valid_after": time.Now().UTC().Truncate(time.Second),
+		"token":              "",
+		"refresh_token":      "",
+		"updated_at":         time.Now(),
+	}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,75 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key from RFC 6238 appendix B, base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCode(t *testing.T) {
+	// The RFC lists eight digit codes; these are their last six digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := TotpCode(rfc6238Secret, TotpStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TotpCode(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("TotpCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTotp(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TotpStep(now)
+
+	codeAt := func(step int64) string {
+		code, err := TotpCode(rfc6238Secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOk   bool
+	}{
+		{name: "current step", secret: rfc6238Secret, code: codeAt(step), wantStep: step, wantOk: true},
+		{name: "previous step", secret: rfc6238Secret, code: codeAt(step - 1), wantStep: step - 1, wantOk: true},
+		{name: "next step", secret: rfc6238Secret, code: codeAt(step + 1), wantStep: step + 1, wantOk: true},
+		{name: "too old", secret: rfc6238Secret, code: codeAt(step - 2)},
+		{name: "too far ahead", secret: rfc6238Secret, code: codeAt(step + 2)},
+		{name: "spaces are ignored", secret: rfc6238Secret, code: " 050 471 ", wantStep: step, wantOk: true},
+		{name: "lower case secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: "050471", wantStep: step, wantOk: true},
+		{name: "wrong length", secret: rfc6238Secret, code: "50471"},
+		{name: "wrong code", secret: rfc6238Secret, code: "000000"},
+		{name: "invalid secret", secret: "not base32!", code: "050471"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := VerifyTotp(tt.secret, tt.code, now)
+			if ok != tt.wantOk || gotStep != tt.wantStep {
+				t.Errorf("VerifyTotp() = (%d, %v), want (%d, %v)", gotStep, ok, tt.wantStep, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package helpers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TwoFactorClaims identify a user who has passed the password check and
+// still owes a TOTP code, or has to enrol before signing in when Purpose
+// is TwoFactorEnroll.
+type TwoFactorClaims struct {
+	Uid     string `json:"uid"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+const (
+	TwoFactorVerify = "verify"
+	TwoFactorEnroll = "enroll"
+)
+
+const twoFactorSubject = "2fa-challenge"
+
+// twoFactorChallengeTTL is how long a user has to type their code after
+// the password.
+const twoFactorChallengeTTL = 5 * time.Minute
+
+func GenerateTwoFactorChallenge(uid, purpose string) (string, error) {
+	claims := &TwoFactorClaims{
+		Uid:     uid,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   twoFactorSubject,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(twoFactorChallengeTTL)),
+		},
+	}
+	return signToken(claims)
+}
+
+func ValidateTwoFactorChallenge(signedToken, purpose string) (*TwoFactorClaims, error) {
+	claims := &TwoFactorClaims{}
+	_, err := parseToken(signedToken, claims, jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject != twoFactorSubject || claims.Purpose != purpose || claims.Uid == "" {
+		return nil, errors.New("not a two-factor challenge")
+	}
+	return claims, nil
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookTarget is returned for webhook URLs that would reach the
+// server's own network rather than a subscriber on the internet.
+var ErrWebhookTarget = errors.New("webhook url must be http(s) and resolve to a public address")
+
+// publicWebhookIP reports whether webhooks may be sent to ip: loopback,
+// private, link-local, multicast and unspecified addresses are refused.
+func publicWebhookIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// CheckWebhookURL rejects webhook URLs that aren't http(s) or whose host
+// resolves to an address publicWebhookIP refuses.
+func CheckWebhookURL(ctx context.Context, raw string) error {
+	target, err := url.Parse(raw)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return ErrWebhookTarget
+	}
+
+	host := target.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicWebhookIP(ip) {
+			return ErrWebhookTarget
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookTarget, err)
+	}
+	for _, addr := range addrs {
+		if !publicWebhookIP(addr.IP) {
+			return ErrWebhookTarget
+		}
+	}
+	return nil
+}
+
+// NewWebhookClient returns a client that checks every address it connects
+// to, so a host that resolved to a public address when the webhook was saved
+// can't later be pointed at an internal one, and redirects can't get there
+// either.
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicWebhookIP(ip) {
+				return ErrWebhookTarget
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,42 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCheckWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://93.184.216.34/hooks", false},
+		{"http://[2606:4700::1111]:8080/hooks", false},
+		{"ftp://93.184.216.34/hooks", true},
+		{"https:///hooks", true},
+		{"http://127.0.0.1:8080/hooks", true},
+		{"http://[::1]/hooks", true},
+		{"http://10.0.0.5/hooks", true},
+		{"http://172.16.3.4/hooks", true},
+		{"http://192.168.1.10/hooks", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"http://[fe80::1]/hooks", true},
+		{"http://[fd00::1]/hooks", true},
+		{"http://0.0.0.0/hooks", true},
+		{"http://[::ffff:127.0.0.1]/hooks", true},
+		{"http://localhost/hooks", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := CheckWebhookURL(context.Background(), tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckWebhookURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrWebhookTarget) {
+				t.Errorf("CheckWebhookURL() error = %v, want ErrWebhookTarget", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/helpers"
+)
+
+const keysUsage = `usage: restaurant_management keys [list|rotate|prune]
+
+  list              show the signing keys and when they sign
+  rotate [--now]    add a key; --now makes it sign immediately instead of
+                    after it has been published for an hour
+  prune             delete keys no live token can be signed with`
+
+func runKeys(args []string) int {
+	command := "list"
+	if len(args) > 0 {
+		command = args[0]
+		args = args[1:]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	switch command {
+	case "list":
+		keys, err := helpers.ListSigningKeys(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "listing keys:", err)
+			return 1
+		}
+		if len(keys) == 0 {
+			fmt.Println("no signing keys")
+		}
+		for _, key := range keys {
+			fmt.Printf("%-16s %-6s active from %s\n", key.Kid, key.Algorithm, key.Active_From.Local().Format("2006-01-02 15:04:05"))
+		}
+		return 0
+
+	case "rotate":
+		flags := flag.NewFlagSet("keys rotate", flag.ContinueOnError)
+		now := flags.Bool("now", false, "sign with the new key immediately")
+		if err := flags.Parse(args); err != nil {
+			return 2
+		}
+
+		key, err := helpers.RotateSigningKey(ctx, *now)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rotating keys:", err)
+			return 1
+		}
+		fmt.Printf("added %s (%s), active from %s\n", key.Kid, key.Algorithm, key.Active_From.Local().Format("2006-01-02 15:04:05"))
+		return 0
+
+	case "prune":
+		deleted, err := helpers.PruneSigningKeys(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "pruning keys:", err)
+			return 1
+		}
+		fmt.Printf("deleted %d key(s)\n", deleted)
+		return 0
+
+	default:
+		fmt.Fprintln(os.Stderr, keysUsage)
+		return 2
+	}
+}
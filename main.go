@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/helpers"
 	"github.com/djwhocodes/restaurant_management/routes"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -18,6 +21,23 @@ func main() {
 		log.Println("No .env file found or couldn't load it")
 	}
 
+	os.Exit(runCommand(os.Args[1:]))
+}
+
+// serve runs the HTTP API. It is the default command.
+func serve() int {
+	migrateOnStart()
+
+	if err := helpers.RegisterMockPaymentProvider(); err != nil {
+		log.Printf("Refusing to start: %v", err)
+		return 1
+	}
+
+	if err := helpers.InitSigningKeys(context.Background()); err != nil {
+		log.Printf("Refusing to start: %v", err)
+		return 1
+	}
+
 	port := os.Getenv("PORT")
 
 	if port == "" {
@@ -37,6 +57,28 @@ func main() {
 	routes.OrderItemRoutes(router)
 	routes.TableRoutes(router)
 	routes.UserRoutes(router)
+	routes.PromotionRoutes(router)
+	routes.CouponRoutes(router)
+	routes.CustomerRoutes(router)
+	routes.PrintRoutes(router)
+	routes.PaymentRoutes(router)
+	routes.WebhookRoutes(router)
+	routes.EventRoutes(router)
+	routes.RealtimeRoutes(router)
+	routes.GuestRoutes(router)
+	routes.DeliveryZoneRoutes(router)
+	routes.AggregatorRoutes(router)
+	routes.CatalogRoutes(router)
+	routes.TerminalRoutes(router)
+	routes.KeyRoutes(router)
+	routes.ShiftRoutes(router)
+	routes.TipRoutes(router)
+	routes.DrawerSessionRoutes(router)
+
+	controllers.RegisterEventSubscribers()
+	helpers.StartKeyRotation(context.Background())
+	helpers.StartOutboxDispatcher(context.Background())
+	controllers.StartWebhookDispatcher(context.Background())
 
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -45,5 +87,9 @@ func main() {
 	})
 
 	fmt.Println("Server running on port:", port)
-	router.Run(":" + port)
+	if err := router.Run(":" + port); err != nil {
+		log.Printf("Server stopped: %v", err)
+		return 1
+	}
+	return 0
 }
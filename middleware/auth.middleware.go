@@ -24,10 +24,22 @@ func Authentication() gin.HandlerFunc {
 			return
 		}
 
+		// PIN-login tokens only work from the terminal they were issued to.
+		if claims.TerminalId != "" {
+			terminal, err := requestTerminal(c)
+			if err != nil || terminal.Terminal_Id != claims.TerminalId {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "token is bound to another terminal"})
+				c.Abort()
+				return
+			}
+			c.Set("terminal_id", terminal.Terminal_Id)
+		}
+
 		c.Set("email", claims.Email)
 		c.Set("first_name", claims.FirstName)
 		c.Set("last_name", claims.LastName)
 		c.Set("uid", claims.Uid)
+		c.Set("role", claims.Role)
 
 		c.Next()
 	}
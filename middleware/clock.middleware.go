@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/gin-gonic/gin"
+)
+
+// ClockedIn only lets staff who are clocked in and not on a break through.
+// It must run after Authentication.
+func ClockedIn() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		entry, err := helpers.OpenTimeEntry(ctx, c.GetString("uid"))
+		if err != nil {
+			log.Printf("Error checking clock-in of user %s: %v", c.GetString("uid"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking clock-in"})
+			c.Abort()
+			return
+		}
+		if entry == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Clock in before taking orders"})
+			c.Abort()
+			return
+		}
+		if helpers.OnBreak(entry) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "End your break before taking orders"})
+			c.Abort()
+			return
+		}
+
+		c.Set("time_entry_id", entry.Time_Entry_Id)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/gin-gonic/gin"
+)
+
+// GuestTable authenticates the signed table token from a QR code and scopes
+// the request to that table.
+func GuestTable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := helpers.ValidateGuestToken(c.Param("guest_token"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid table code"})
+			c.Abort()
+			return
+		}
+
+		c.Set("guest_table_id", claims.Table_Id)
+		c.Set("guest_qr_version", claims.Qr_Version)
+
+		c.Next()
+	}
+}
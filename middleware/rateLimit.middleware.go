@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimit allows limit requests per window for each key, answering 429
+// beyond that. Counters are kept in memory, so each instance limits on its
+// own.
+func RateLimit(limit int, window time.Duration, key func(c *gin.Context) string) gin.HandlerFunc {
+	var mu sync.Mutex
+	windows := map[string]*rateWindow{}
+	lastSweep := time.Now()
+
+	return func(c *gin.Context) {
+		now := time.Now()
+		k := key(c)
+
+		mu.Lock()
+		if now.Sub(lastSweep) > window {
+			for existing, w := range windows {
+				if now.Sub(w.start) > window {
+					delete(windows, existing)
+				}
+			}
+			lastSweep = now
+		}
+
+		w, ok := windows[k]
+		if !ok || now.Sub(w.start) > window {
+			w = &rateWindow{start: now}
+			windows[k] = w
+		}
+		w.count++
+		count, reset := w.count, w.start.Add(window)
+		mu.Unlock()
+
+		if count > limit {
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(reset).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please slow down"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
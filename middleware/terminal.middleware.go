@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Terminals identify themselves with the id and key issued when they were
+// registered.
+const (
+	terminalIdHeader  = "X-Terminal-Id"
+	terminalKeyHeader = "X-Terminal-Key"
+)
+
+// Terminal authenticates a registered POS terminal and sets terminal_id.
+func Terminal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		terminal, err := requestTerminal(c)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if !errors.Is(err, helpers.ErrUnknownTerminal) {
+				status = http.StatusInternalServerError
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Set("terminal_id", terminal.Terminal_Id)
+
+		c.Next()
+	}
+}
+
+func requestTerminal(c *gin.Context) (*models.Terminal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return helpers.VerifyTerminal(ctx, c.GetHeader(terminalIdHeader), c.GetHeader(terminalKeyHeader))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/migrations"
+)
+
+const migrateUsage = `usage: restaurant_management migrate [up|status]
+
+  up      apply pending migrations (default)
+  status  list migrations and when they were applied`
+
+// runMigrate implements the migrate subcommand and returns the exit code.
+func runMigrate(args []string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	db := database.OpenDatabase(database.MongoClient)
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		ran, err := migrations.Up(ctx, db)
+		for _, version := range ran {
+			fmt.Printf("applied %d\n", version)
+		}
+		if err != nil {
+			if errors.Is(err, migrations.ErrLocked) {
+				fmt.Fprintln(os.Stderr, "another migration run holds the lock, try again later")
+				return 1
+			}
+			fmt.Fprintln(os.Stderr, "migration failed:", err)
+			return 1
+		}
+		if len(ran) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return 0
+
+	case "status":
+		statuses, err := migrations.Status(ctx, db)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "reading migration status:", err)
+			return 1
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied_At != nil {
+				applied = status.Applied_At.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-45s %s\n", status.Version, status.Name, applied)
+		}
+		return 0
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+}
+
+// migrateOnStart applies pending migrations when AUTO_MIGRATE=true and
+// otherwise only warns about them, leaving schema changes to the operator.
+func migrateOnStart() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	db := database.OpenDatabase(database.MongoClient)
+
+	if os.Getenv("AUTO_MIGRATE") == "true" {
+		if _, err := migrations.Up(ctx, db); err != nil && !errors.Is(err, migrations.ErrLocked) {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	pending, err := migrations.Pending(ctx, db)
+	if err != nil {
+		log.Printf("Could not check migrations: %v", err)
+		return
+	}
+	if pending > 0 {
+		log.Printf("%d database migration(s) pending, run `migrate up`", pending)
+	}
+}
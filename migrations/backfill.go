@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// backfillDefaults gives documents written before these fields existed the
+// values new documents get, so filters and validators see one shape.
+func backfillDefaults(ctx context.Context, db *mongo.Database) error {
+	fills := []struct {
+		collection string
+		filter     bson.M
+		set        bson.M
+	}{
+		{"order", bson.M{"order_type": bson.M{"$in": bson.A{nil, ""}}}, bson.M{"order_type": "DINE_IN"}},
+		{"order_item", bson.M{"status": bson.M{"$in": bson.A{nil, ""}}}, bson.M{"status": "PENDING"}},
+		{"table", bson.M{"qr_version": nil}, bson.M{"qr_version": 0}},
+		{"invoice", bson.M{"location_id": bson.M{"$in": bson.A{nil, ""}}}, bson.M{"location_id": helpers.EnvString("LOCATION_ID", "MAIN")}},
+	}
+
+	for _, fill := range fills {
+		result, err := db.Collection(fill.collection).UpdateMany(ctx, fill.filter, bson.M{"$set": fill.set})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount > 0 {
+			log.Printf("Backfilled %d %s documents", result.ModifiedCount, fill.collection)
+		}
+	}
+	return nil
+}
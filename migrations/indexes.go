@@ -0,0 +1,268 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type indexSpec struct {
+	collection string
+	keys       bson.D
+	unique     bool
+	// partial limits a unique index to documents where the field is set,
+	// for fields older documents don't have.
+	partial bson.M
+	// expireAfter makes a TTL index that removes documents this many
+	// seconds after the indexed date.
+	expireAfter *int32
+}
+
+func asc(fields ...string) bson.D {
+	keys := bson.D{}
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: field, Value: 1})
+	}
+	return keys
+}
+
+var indexes = []indexSpec{
+	{collection: "user", keys: asc("user_id"), unique: true},
+	{collection: "user", keys: asc("email"), unique: true},
+	{collection: "user", keys: asc("phone"), unique: true},
+
+	{collection: "menu", keys: asc("menu_id"), unique: true},
+	{collection: "menu", keys: asc("name")},
+	{collection: "menu", keys: asc("start_date", "end_date")},
+
+	{collection: "food", keys: asc("food_id"), unique: true},
+	{collection: "food", keys: asc("menu_id")},
+	{collection: "food", keys: asc("name")},
+
+	{collection: "table", keys: asc("table_id"), unique: true},
+
+	{collection: "order", keys: asc("order_id"), unique: true},
+	{collection: "order", keys: bson.D{{Key: "table_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	{collection: "order", keys: asc("customer_id")},
+	{collection: "order", keys: asc("order_type")},
+
+	{collection: "order_item", keys: asc("order_item_id"), unique: true},
+	{collection: "order_item", keys: asc("order_id")},
+	{collection: "order_item", keys: asc("status", "created_at")},
+
+	{collection: "invoice", keys: asc("invoice_id"), unique: true},
+	{collection: "invoice", keys: asc("order_id")},
+	{collection: "invoice", keys: asc("customer_id")},
+	{collection: "invoice", keys: asc("invoice_number"), unique: true, partial: bson.M{"invoice_number": bson.M{"$gt": ""}}},
+	{collection: "invoice", keys: asc("location_id", "fiscal_year")},
+	{collection: "invoice_document", keys: asc("invoice_id"), unique: true},
+
+	{collection: "customer", keys: asc("customer_id"), unique: true},
+	{collection: "customer", keys: asc("phone")},
+	{collection: "customer", keys: asc("email")},
+
+	{collection: "promotion", keys: asc("promotion_id"), unique: true},
+	{collection: "coupon", keys: asc("coupon_id"), unique: true},
+	{collection: "coupon", keys: asc("code"), unique: true},
+
+	{collection: "payment", keys: asc("payment_id"), unique: true},
+	{collection: "payment", keys: asc("invoice_id")},
+	{collection: "payment", keys: asc("provider", "provider_reference"), unique: true, partial: bson.M{"provider_reference": bson.M{"$gt": ""}}},
+
+	{collection: "print_job", keys: asc("print_job_id"), unique: true},
+	{collection: "print_job", keys: asc("printer", "status", "created_at")},
+
+	{collection: "outbox", keys: asc("event_id"), unique: true},
+	{collection: "outbox", keys: asc("status", "next_attempt_at")},
+
+	{collection: "webhook", keys: asc("webhook_id"), unique: true},
+	{collection: "webhook_delivery", keys: asc("delivery_id"), unique: true},
+	{collection: "webhook_delivery", keys: asc("webhook_id", "event_id"), unique: true},
+	{collection: "webhook_delivery", keys: asc("status", "next_attempt_at")},
+
+	{collection: "delivery_zone", keys: asc("delivery_zone_id"), unique: true},
+	{collection: "delivery_zone", keys: asc("postal_codes")},
+
+	{collection: "aggregator_order", keys: asc("platform", "external_order_id"), unique: true},
+	{collection: "aggregator_order", keys: asc("status", "received_at")},
+	{collection: "item_mapping", keys: asc("platform", "external_item_id"), unique: true},
+}
+
+// passwordResetIndexes let reset tokens be found by hash and drop them once
+// they expire.
+var passwordResetIndexes = []indexSpec{
+	{collection: "password_reset", keys: asc("token_hash"), unique: true},
+	{collection: "password_reset", keys: asc("user_id")},
+	{collection: "password_reset", keys: asc("expires_at"), expireAfter: seconds(0)},
+}
+
+var terminalIndexes = []indexSpec{
+	{collection: "terminal", keys: asc("terminal_id"), unique: true},
+}
+
+var loginAttemptIndexes = []indexSpec{
+	{collection: "login_attempt", keys: bson.D{{Key: "email", Value: 1}, {Key: "created_at", Value: -1}}},
+	{collection: "login_attempt", keys: bson.D{{Key: "ip", Value: 1}, {Key: "created_at", Value: -1}}},
+}
+
+var signingKeyIndexes = []indexSpec{
+	{collection: "signing_key", keys: asc("kid"), unique: true},
+	{collection: "signing_key", keys: asc("generation"), unique: true},
+}
+
+var staffTimeIndexes = []indexSpec{
+	{collection: "shift", keys: asc("shift_id"), unique: true},
+	{collection: "shift", keys: asc("user_id", "start_time")},
+	{collection: "shift", keys: asc("start_time")},
+	{collection: "time_entry", keys: asc("time_entry_id"), unique: true},
+	{collection: "time_entry", keys: asc("user_id", "clock_in")},
+	{collection: "time_entry", keys: asc("clock_in")},
+	// At most one open time entry per user.
+	{collection: "time_entry", keys: asc("user_id"), unique: true, partial: bson.M{"status": "OPEN"}},
+	{collection: "order", keys: asc("server_id")},
+	{collection: "invoice", keys: asc("server_id", "created_at")},
+}
+
+var tipIndexes = []indexSpec{
+	{collection: "tip_pool", keys: asc("tip_pool_id"), unique: true},
+	{collection: "invoice", keys: asc("tips.at")},
+	{collection: "time_entry", keys: asc("clock_out")},
+}
+
+var drawerSessionIndexes = []indexSpec{
+	{collection: "drawer_session", keys: asc("drawer_session_id"), unique: true},
+	// One open drawer per cashier, and per terminal when opened on one.
+	{collection: "drawer_session", keys: asc("cashier_id"), unique: true, partial: bson.M{"status": "OPEN"}},
+	{collection: "drawer_session", keys: asc("terminal_id"), unique: true, partial: bson.M{"status": "OPEN", "terminal_id": bson.M{"$type": "string"}}},
+	{collection: "drawer_session", keys: asc("opened_at")},
+	{collection: "drawer_session", keys: asc("status", "closed_at")},
+	{collection: "invoice", keys: asc("drawer_session_id")},
+}
+
+var invoiceVoidIndexes = []indexSpec{
+	{collection: "invoice_void", keys: asc("location_id", "fiscal_year", "seq"), unique: true},
+}
+
+func seconds(n int32) *int32 {
+	return &n
+}
+
+func createIndexes(ctx context.Context, db *mongo.Database) error {
+	return applyIndexes(ctx, db, indexes)
+}
+
+func createPasswordResetIndexes(ctx context.Context, db *mongo.Database) error {
+	return applyIndexes(ctx, db, passwordResetIndexes)
+}
+
+func createTerminalIndexes(ctx context.Context, db *mongo.Database) error {
+	return applyIndexes(ctx, db, terminalIndexes)
+}
+
+func createLoginAttemptIndexes(ctx context.Context, db *mongo.Database) error {
+	return applyIndexes(ctx, db, loginAttemptIndexes)
+}
+
+func createSigningKeyIndexes(ctx context.Context, db *mongo.Database) error {
+	return applyIndexes(ctx, db, signingKeyIndexes)
+}
+
+func createStaffTimeIndexes(ctx context.Context, db *mongo.Database) error {
+	return applyIndexes(ctx, db, staffTimeIndexes)
+}
+
+func createTipIndexes(ctx context.Context, db *mongo.Database) error {
+	return applyIndexes(ctx, db, tipIndexes)
+}
+
+func createDrawerSessionIndexes(ctx context.Context, db *mongo.Database) error {
+	return applyIndexes(ctx, db, drawerSessionIndexes)
+}
+
+func createInvoiceVoidIndexes(ctx context.Context, db *mongo.Database) error {
+	return applyIndexes(ctx, db, invoiceVoidIndexes)
+}
+
+func applyIndexes(ctx context.Context, db *mongo.Database, specs []indexSpec) error {
+	for _, spec := range specs {
+		if spec.unique {
+			if err := checkDuplicates(ctx, db.Collection(spec.collection), spec); err != nil {
+				return err
+			}
+		}
+
+		opts := options.Index().SetName(indexName(spec))
+		if spec.unique {
+			opts.SetUnique(true)
+		}
+		if spec.partial != nil {
+			opts.SetPartialFilterExpression(spec.partial)
+		}
+		if spec.expireAfter != nil {
+			opts.SetExpireAfterSeconds(*spec.expireAfter)
+		}
+
+		_, err := db.Collection(spec.collection).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: spec.keys, Options: opts})
+		if err != nil {
+			return fmt.Errorf("creating index %s on %s: %w", indexName(spec), spec.collection, err)
+		}
+	}
+	return nil
+}
+
+func indexName(spec indexSpec) string {
+	parts := make([]string, 0, len(spec.keys))
+	for _, key := range spec.keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", key.Key, key.Value))
+	}
+	name := strings.Join(parts, "_")
+	if spec.unique {
+		name += "_unique"
+	}
+	return name
+}
+
+// checkDuplicates fails with the offending values when existing data would
+// break a unique index, so they can be cleaned up by hand first.
+func checkDuplicates(ctx context.Context, collection *mongo.Collection, spec indexSpec) error {
+	group := bson.M{}
+	match := bson.M{}
+	for _, key := range spec.keys {
+		group[key.Key] = "$" + key.Key
+	}
+	for field, condition := range spec.partial {
+		match[field] = condition
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": group, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$limit", Value: 5}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var duplicates []bson.M
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(duplicates))
+	for _, duplicate := range duplicates {
+		values = append(values, fmt.Sprintf("%v (%v documents)", duplicate["_id"], duplicate["count"]))
+	}
+	return fmt.Errorf("%s has duplicate %s values, resolve them before migrating: %s",
+		collection.Name(), indexName(spec), strings.Join(values, "; "))
+}
@@ -0,0 +1,180 @@
+// Package migrations versions the database schema: indexes, collection
+// validators and data fixes are applied in order and recorded in the
+// schema_migrations collection, so each runs exactly once per database.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+}
+
+type MigrationStatus struct {
+	Version    int        `json:"version"`
+	Name       string     `json:"name"`
+	Applied_At *time.Time `json:"applied_at"`
+}
+
+// migrations must stay in version order. Never edit one that has shipped;
+// add a new version instead.
+var migrations = []Migration{
+	{Version: 1, Name: "create indexes", Up: createIndexes},
+	{Version: 2, Name: "backfill order types and item statuses", Up: backfillDefaults},
+	{Version: 3, Name: "add collection validators", Up: addValidators},
+	{Version: 4, Name: "add password reset indexes", Up: createPasswordResetIndexes},
+	{Version: 5, Name: "add terminal indexes", Up: createTerminalIndexes},
+	{Version: 6, Name: "add login attempt indexes", Up: createLoginAttemptIndexes},
+	{Version: 7, Name: "add signing key indexes", Up: createSigningKeyIndexes},
+	{Version: 8, Name: "add shift and time entry indexes", Up: createStaffTimeIndexes},
+	{Version: 9, Name: "add tip indexes", Up: createTipIndexes},
+	{Version: 10, Name: "add cash drawer session indexes", Up: createDrawerSessionIndexes},
+	{Version: 11, Name: "add invoice void indexes", Up: createInvoiceVoidIndexes},
+}
+
+const migrationsCollection = "schema_migrations"
+
+// lockId marks a running migration. A lock older than lockTimeout is
+// assumed to belong to a crashed run and is taken over.
+const (
+	lockId      = "lock"
+	lockTimeout = 15 * time.Minute
+)
+
+var ErrLocked = errors.New("another migration run is in progress")
+
+// Up applies every migration that hasn't been applied yet and returns the
+// versions it ran.
+func Up(ctx context.Context, db *mongo.Database) ([]int, error) {
+	if err := acquireLock(ctx, db); err != nil {
+		return nil, err
+	}
+	defer releaseLock(db)
+
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []int
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		log.Printf("Applying migration %d: %s", migration.Version, migration.Name)
+		started := time.Now()
+		if err := migration.Up(ctx, db); err != nil {
+			return ran, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+
+		_, err := db.Collection(migrationsCollection).InsertOne(ctx, bson.M{
+			"_id":         migration.Version,
+			"name":        migration.Name,
+			"applied_at":  time.Now().UTC(),
+			"duration_ms": time.Since(started).Milliseconds(),
+		})
+		if err != nil {
+			return ran, fmt.Errorf("recording migration %d: %w", migration.Version, err)
+		}
+		ran = append(ran, migration.Version)
+	}
+
+	return ran, nil
+}
+
+// Status lists every known migration and when it was applied, if at all.
+func Status(ctx context.Context, db *mongo.Database) ([]MigrationStatus, error) {
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if at, ok := applied[migration.Version]; ok {
+			status.Applied_At = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending reports how many migrations have not been applied yet.
+func Pending(ctx context.Context, db *mongo.Database) (int, error) {
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	return len(migrations) - len(applied), nil
+}
+
+func appliedVersions(ctx context.Context, db *mongo.Database) (map[int]time.Time, error) {
+	cursor, err := db.Collection(migrationsCollection).Find(ctx, bson.M{"_id": bson.M{"$type": "number"}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []struct {
+		Version    int       `bson:"_id"`
+		Applied_At time.Time `bson:"applied_at"`
+	}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time, len(records))
+	for _, record := range records {
+		applied[record.Version] = record.Applied_At
+	}
+	return applied, nil
+}
+
+func acquireLock(ctx context.Context, db *mongo.Database) error {
+	host, _ := os.Hostname()
+	now := time.Now().UTC()
+	collection := db.Collection(migrationsCollection)
+
+	_, err := collection.InsertOne(ctx, bson.M{"_id": lockId, "locked_at": now, "host": host})
+	if err == nil {
+		return nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": lockId, "locked_at": bson.M{"$lt": now.Add(-lockTimeout)}},
+		bson.M{"$set": bson.M{"locked_at": now, "host": host}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrLocked
+	}
+	log.Println("Took over a stale migration lock")
+	return nil
+}
+
+func releaseLock(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := db.Collection(migrationsCollection).DeleteOne(ctx, bson.M{"_id": lockId}); err != nil {
+		log.Printf("Error releasing migration lock: %v", err)
+	}
+}
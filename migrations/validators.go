@@ -0,0 +1,125 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// namespaceNotFound is returned by collMod for collections that don't exist
+// yet.
+const namespaceNotFound = 26
+
+var (
+	str       = bson.M{"bsonType": "string"}
+	nonEmpty  = bson.M{"bsonType": "string", "minLength": 1}
+	number    = bson.M{"bsonType": bson.A{"double", "int", "long", "decimal"}}
+	optString = bson.M{"bsonType": bson.A{"string", "null"}}
+	date      = bson.M{"bsonType": "date"}
+)
+
+func enum(values ...string) bson.M {
+	allowed := bson.A{}
+	for _, value := range values {
+		allowed = append(allowed, value)
+	}
+	return bson.M{"bsonType": "string", "enum": allowed}
+}
+
+func schema(required []string, properties bson.M) bson.M {
+	return bson.M{"$jsonSchema": bson.M{
+		"bsonType":   "object",
+		"required":   required,
+		"properties": properties,
+	}}
+}
+
+// validators mirror the invariants the handlers already enforce. They use
+// the moderate level, so documents that predate a rule can still be
+// updated; only new documents and already-valid ones are checked.
+var validators = map[string]bson.M{
+	"user": schema([]string{"user_id", "email", "password"}, bson.M{
+		"user_id":  nonEmpty,
+		"email":    nonEmpty,
+		"password": nonEmpty,
+		"phone":    optString,
+	}),
+	"menu": schema([]string{"menu_id", "name"}, bson.M{
+		"menu_id":  nonEmpty,
+		"name":     bson.M{"bsonType": "string", "minLength": 2, "maxLength": 30},
+		"category": optString,
+	}),
+	"food": schema([]string{"food_id", "name", "price", "menu_id"}, bson.M{
+		"food_id":    nonEmpty,
+		"name":       bson.M{"bsonType": "string", "minLength": 2, "maxLength": 100},
+		"price":      bson.M{"bsonType": number["bsonType"], "minimum": 0},
+		"menu_id":    nonEmpty,
+		"food_image": optString,
+	}),
+	"table": schema([]string{"table_id", "table_number"}, bson.M{
+		"table_id":     nonEmpty,
+		"table_number": number,
+	}),
+	"order": schema([]string{"order_id", "order_type", "created_at"}, bson.M{
+		"order_id":   nonEmpty,
+		"order_type": enum("DINE_IN", "TAKEAWAY", "DELIVERY"),
+		"table_id":   optString,
+		"created_at": date,
+	}),
+	"order_item": schema([]string{"order_item_id", "order_id", "food_id", "quantity", "unit_price", "status"}, bson.M{
+		"order_item_id": nonEmpty,
+		"order_id":      nonEmpty,
+		"food_id":       nonEmpty,
+		"quantity":      str,
+		"unit_price":    number,
+		"status":        enum("AWAITING_APPROVAL", "PENDING", "PREPARING", "READY", "SERVED", "REJECTED"),
+	}),
+	"invoice": schema([]string{"invoice_id", "order_id", "total_amount"}, bson.M{
+		"invoice_id":     nonEmpty,
+		"order_id":       nonEmpty,
+		"invoice_number": str,
+		"total_amount":   number,
+	}),
+	"customer": schema([]string{"customer_id", "phone"}, bson.M{
+		"customer_id":    nonEmpty,
+		"phone":          nonEmpty,
+		"loyalty_points": bson.M{"bsonType": number["bsonType"], "minimum": 0},
+	}),
+	"coupon": schema([]string{"coupon_id", "code"}, bson.M{
+		"coupon_id":   nonEmpty,
+		"code":        nonEmpty,
+		"usage_count": bson.M{"bsonType": number["bsonType"], "minimum": 0},
+	}),
+	"payment": schema([]string{"payment_id", "invoice_id", "status"}, bson.M{
+		"payment_id": nonEmpty,
+		"invoice_id": nonEmpty,
+		"amount":     number,
+	}),
+}
+
+func addValidators(ctx context.Context, db *mongo.Database) error {
+	for collection, validator := range validators {
+		err := db.RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: collection},
+			{Key: "validator", Value: validator},
+			{Key: "validationLevel", Value: "moderate"},
+			{Key: "validationAction", Value: "error"},
+		}).Err()
+
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && serverErr.HasErrorCode(namespaceNotFound) {
+			err = db.CreateCollection(ctx, collection, options.CreateCollection().
+				SetValidator(validator).
+				SetValidationLevel("moderate").
+				SetValidationAction("error"))
+		}
+		if err != nil {
+			return fmt.Errorf("setting validator on %s: %w", collection, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AggregatorOrder records each order received from a delivery platform, so
+// a repeated webhook never creates a second order.
+type AggregatorOrder struct {
+	ID                  primitive.ObjectID `bson:"_id"`
+	Platform            string             `json:"platform"`
+	External_Order_Id   string             `json:"external_order_id"`
+	Order_Id            *string            `json:"order_id"`
+	Status              string             `json:"status" validate:"eq=RECEIVED|eq=IMPORTED|eq=PARTIAL|eq=FAILED"`
+	Unmapped_Items      []UnmappedItem     `json:"unmapped_items"`
+	Error               string             `json:"error,omitempty"`
+	Payload             string             `json:"payload"`
+	Received_At         time.Time          `json:"received_at"`
+	Updated_At          time.Time          `json:"updated_at"`
+	Aggregator_Order_Id string             `json:"aggregator_order_id"`
+}
+
+type UnmappedItem struct {
+	External_Item_Id string `json:"external_item_id"`
+	Name             string `json:"name"`
+	Quantity         int    `json:"quantity"`
+}
+
+// ItemMapping links a platform's item id to one of our foods.
+type ItemMapping struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Platform         string             `json:"platform"`
+	External_Item_Id string             `json:"external_item_id" validate:"required"`
+	Food_Id          *string            `json:"food_id" validate:"required"`
+	Name             string             `json:"name"`
+	Created_At       time.Time          `json:"created_at"`
+	Updated_At       time.Time          `json:"updated_at"`
+	Item_Mapping_Id  string             `json:"item_mapping_id"`
+}
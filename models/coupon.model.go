@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Coupon struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Code         *string            `json:"code" validate:"required,min=3,max=40"`
+	Promotion_Id *string            `json:"promotion_id" validate:"required"`
+	Usage_Limit  *int               `json:"usage_limit" validate:"omitempty,gte=1"`
+	Usage_Count  int                `json:"usage_count"`
+	Expires_At   time.Time          `json:"expires_at"`
+	Created_At   time.Time          `json:"created_at"`
+	Updated_At   time.Time          `json:"updated_at"`
+	Coupon_Id    string             `json:"coupon_id"`
+}
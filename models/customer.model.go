@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Customer struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	First_Name     *string            `json:"first_name" validate:"required,min=2,max=100"`
+	Last_Name      *string            `json:"last_name" validate:"omitempty,max=100"`
+	Phone          *string            `json:"phone" validate:"required"`
+	Email          *string            `json:"email" validate:"omitempty,email"`
+	Preferences    []string           `json:"preferences"`
+	Allergies      []string           `json:"allergies"`
+	Notes          *string            `json:"notes" validate:"omitempty,max=1000"`
+	Loyalty_Points int                `json:"loyalty_points"`
+	Visit_Count    int                `json:"visit_count"`
+	Last_Visit     time.Time          `json:"last_visit"`
+	Created_At     time.Time          `json:"created_at"`
+	Updated_At     time.Time          `json:"updated_at"`
+	Customer_Id    string             `json:"customer_id"`
+}
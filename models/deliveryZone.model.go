@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type DeliveryZone struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Name             *string            `json:"name" validate:"required,min=2,max=100"`
+	Postal_Codes     []string           `json:"postal_codes" validate:"required,min=1"`
+	Delivery_Fee     *float64           `json:"delivery_fee" validate:"required,min=0"`
+	Minimum_Order    *float64           `json:"minimum_order" validate:"required,min=0"`
+	Active           *bool              `json:"active"`
+	Created_At       time.Time          `json:"created_at"`
+	Updated_At       time.Time          `json:"updated_at"`
+	Delivery_Zone_Id string             `json:"delivery_zone_id"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DrawerSession is one cashier's cash drawer from the opening float to the
+// closing count. The running totals are kept alongside the movements so
+// the expected cash can be read without replaying them.
+type DrawerSession struct {
+	ID                primitive.ObjectID  `bson:"_id"`
+	Cashier_Id        string              `json:"cashier_id"`
+	Terminal_Id       *string             `json:"terminal_id"`
+	Status            string              `json:"status" validate:"eq=OPEN|eq=CLOSED"`
+	Opening_Float     float64             `json:"opening_float"`
+	Cash_Sales        float64             `json:"cash_sales"`
+	Cash_Tips         float64             `json:"cash_tips"`
+	Change_Given      float64             `json:"change_given"`
+	Pay_Ins           float64             `json:"pay_ins"`
+	Pay_Outs          float64             `json:"pay_outs"`
+	Movements         []DrawerMovement    `json:"movements"`
+	Counted           []DenominationCount `json:"counted"`
+	Counted_Total     *float64            `json:"counted_total"`
+	Expected_Total    *float64            `json:"expected_total"`
+	Over_Short        *float64            `json:"over_short"`
+	Notes             *string             `json:"notes"`
+	Opened_At         time.Time           `json:"opened_at"`
+	Closed_At         *time.Time          `json:"closed_at"`
+	Closed_By         *string             `json:"closed_by"`
+	Updated_At        time.Time           `json:"updated_at"`
+	Drawer_Session_Id string              `json:"drawer_session_id"`
+}
+
+// DrawerMovement is cash going into or out of the drawer. SALE movements
+// record what was tendered and the change handed back.
+type DrawerMovement struct {
+	Type       string    `json:"type" validate:"eq=SALE|eq=PAY_IN|eq=PAY_OUT"`
+	Amount     float64   `json:"amount"`
+	Tip        float64   `json:"tip,omitempty"`
+	Tendered   float64   `json:"tendered,omitempty"`
+	Change     float64   `json:"change,omitempty"`
+	Invoice_Id *string   `json:"invoice_id,omitempty"`
+	Reason     *string   `json:"reason,omitempty"`
+	User_Id    string    `json:"user_id"`
+	At         time.Time `json:"at"`
+}
+
+type DenominationCount struct {
+	Value float64 `json:"value" validate:"gt=0"`
+	Count int     `json:"count" validate:"gte=0"`
+}
+
+// Expected is the cash that should be in the drawer: the float plus cash
+// taken and paid in, less what was paid out.
+func (s DrawerSession) Expected() float64 {
+	return s.Opening_Float + s.Cash_Sales + s.Cash_Tips + s.Pay_Ins - s.Pay_Outs
+}
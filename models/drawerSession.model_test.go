@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestDrawerSessionExpected(t *testing.T) {
+	tests := []struct {
+		name    string
+		session DrawerSession
+		want    float64
+	}{
+		{name: "float only", session: DrawerSession{Opening_Float: 150}, want: 150},
+		{name: "sales and tips", session: DrawerSession{Opening_Float: 100, Cash_Sales: 42.5, Cash_Tips: 7.5}, want: 150},
+		{name: "pay-ins and pay-outs", session: DrawerSession{Opening_Float: 100, Pay_Ins: 20, Pay_Outs: 35}, want: 85},
+		{name: "change given is not taken off sales again", session: DrawerSession{Opening_Float: 100, Cash_Sales: 30, Change_Given: 20}, want: 130},
+		{name: "pay-outs can exceed the float", session: DrawerSession{Opening_Float: 10, Pay_Outs: 25}, want: -15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.session.Expected(); got != tt.want {
+				t.Errorf("Expected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
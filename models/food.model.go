@@ -7,12 +7,13 @@ import (
 )
 
 type Food struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Name       *string            `json:"name" validate:"required,min=2,max=100"`
-	Price      *float64           `json:"price" validate:"required"`
-	Food_Image *string            `json:"food_image" validate:"required"`
-	Created_At time.Time          `json:"created_at"`
-	Updated_At time.Time          `json:"updated_at"`
-	Food_Id    string             `json:"food_id"`
-	Menu_Id    *string            `json:"menu_id" validate:"required"`
+	ID                  primitive.ObjectID `bson:"_id"`
+	Name                *string            `json:"name" validate:"required,min=2,max=100"`
+	Price               *float64           `json:"price" validate:"required"`
+	Food_Image          *string            `json:"food_image"`
+	Food_Image_Variants map[string]string  `json:"food_image_variants"`
+	Created_At          time.Time          `json:"created_at"`
+	Updated_At          time.Time          `json:"updated_at"`
+	Food_Id             string             `json:"food_id"`
+	Menu_Id             *string            `json:"menu_id" validate:"required"`
 }
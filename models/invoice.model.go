@@ -7,12 +7,66 @@ import (
 )
 
 type Invoice struct {
-	ID               primitive.ObjectID `bson:"_id"`
-	Invoice_Id       string             `json:"invoice_id"`
-	Order_Id         string             `json:"order_id"`
-	Payment_Method   *string            `json:"payment_method" validate:"eq=CARD|eq=CASH|eq="`
-	Payment_Status   *string            `json:"payment_status" validate:"required,eq=PENDING|eq=PAID|eq="`
-	Payment_Due_Date time.Time          `json:"payment_due_date"`
-	Created_At       time.Time          `json:"created_at"`
-	Updated_At       time.Time          `json:"updated_at"`
+	ID                 primitive.ObjectID `bson:"_id"`
+	Invoice_Id         string             `json:"invoice_id"`
+	Invoice_Number     string             `json:"invoice_number"`
+	Location_Id        string             `json:"location_id"`
+	Fiscal_Year        int                `json:"fiscal_year"`
+	Order_Id           string             `json:"order_id"`
+	Order_Type         string             `json:"order_type"`
+	Customer_Id        *string            `json:"customer_id"`
+	Server_Id          *string            `json:"server_id"`
+	Created_By         *string            `json:"created_by"`
+	Payment_Method     *string            `json:"payment_method" validate:"eq=CARD|eq=CASH|eq="`
+	Payment_Status     *string            `json:"payment_status" validate:"required,eq=PENDING|eq=PAID|eq=REFUNDED|eq="`
+	Drawer_Session_Id  *string            `json:"drawer_session_id"`
+	Payment_Due_Date   time.Time          `json:"payment_due_date"`
+	Coupon_Code        *string            `json:"coupon_code"`
+	Line_Items         []InvoiceLineItem  `json:"line_items"`
+	Applied_Promotions []AppliedPromotion `json:"applied_promotions"`
+	Sub_Total          float64            `json:"sub_total"`
+	Discount_Total     float64            `json:"discount_total"`
+	Delivery_Fee       float64            `json:"delivery_fee"`
+	Tax_Name           string             `json:"tax_name"`
+	Tax_Rate           float64            `json:"tax_rate"`
+	Tax_Amount         float64            `json:"tax_amount"`
+	Total_Amount       float64            `json:"total_amount"`
+	Points_Redeemed    int                `json:"points_redeemed"`
+	Points_Value       float64            `json:"points_value"`
+	Amount_Due         float64            `json:"amount_due"`
+	Card_Reserved      float64            `json:"card_reserved"`
+	Tip_Amount         float64            `json:"tip_amount"`
+	Tips               []InvoiceTip       `json:"tips"`
+	Points_Earned      int                `json:"points_earned"`
+	Points_Awarded     bool               `json:"points_awarded"`
+	Created_At         time.Time          `json:"created_at"`
+	Updated_At         time.Time          `json:"updated_at"`
+}
+
+type InvoiceLineItem struct {
+	Food_Id    string  `json:"food_id"`
+	Menu_Id    string  `json:"menu_id"`
+	Name       string  `json:"name"`
+	Quantity   int     `json:"quantity"`
+	Unit_Price float64 `json:"unit_price"`
+	Line_Total float64 `json:"line_total"`
+}
+
+type AppliedPromotion struct {
+	Promotion_Id string  `json:"promotion_id"`
+	Name         string  `json:"name"`
+	Coupon_Code  string  `json:"coupon_code,omitempty"`
+	Food_Id      string  `json:"food_id,omitempty"`
+	Amount       float64 `json:"amount"`
+}
+
+// InvoiceTip is one tip left on an invoice. Card tips are recorded when
+// their payment is captured; Server_Id is the user who owned the order.
+type InvoiceTip struct {
+	Amount      float64   `json:"amount"`
+	Method      string    `json:"method"`
+	Payment_Id  *string   `json:"payment_id,omitempty"`
+	Server_Id   *string   `json:"server_id"`
+	Recorded_By *string   `json:"recorded_by"`
+	At          time.Time `json:"at"`
 }
@@ -0,0 +1,10 @@
+package models
+
+// InvoiceCounter holds the last invoice number handed out for one location
+// and fiscal year. ID is "<location>:<fiscal year>".
+type InvoiceCounter struct {
+	ID          string `bson:"_id"`
+	Location_Id string `json:"location_id"`
+	Fiscal_Year int    `json:"fiscal_year"`
+	Seq         int64  `json:"seq"`
+}
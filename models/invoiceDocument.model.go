@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InvoiceDocument is an issued copy of an invoice, kept so the exact same
+// file can be sent again later.
+type InvoiceDocument struct {
+	ID                  primitive.ObjectID `bson:"_id"`
+	Invoice_Id          string             `json:"invoice_id"`
+	Content_Type        string             `json:"content_type"`
+	Data                []byte             `json:"-"`
+	Checksum            string             `json:"checksum"`
+	Created_At          time.Time          `json:"created_at"`
+	Invoice_Document_Id string             `json:"invoice_document_id"`
+}
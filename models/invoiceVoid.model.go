@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// InvoiceVoid accounts for an invoice number that was taken but never
+// used, so the numbering can be audited without gaps. Numbers are only
+// voided when MongoDB runs without transactions.
+type InvoiceVoid struct {
+	Invoice_Number string    `json:"invoice_number"`
+	Location_Id    string    `json:"location_id"`
+	Fiscal_Year    int       `json:"fiscal_year"`
+	Seq            int64     `json:"seq"`
+	Order_Id       string    `json:"order_id"`
+	Reason         string    `json:"reason"`
+	Created_At     time.Time `json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt is the audit record of one password login. Failures are
+// counted from these records by email and by client address, so unknown
+// emails are throttled exactly like real accounts.
+type LoginAttempt struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Email      string             `json:"email"`
+	User_Id    string             `json:"user_id,omitempty"`
+	Ip         string             `json:"ip"`
+	User_Agent string             `json:"user_agent"`
+	// Outcome is SUCCESS, FAILURE or THROTTLED, or UNLOCKED when an admin
+	// cleared the account's failures.
+	Outcome    string    `json:"outcome"`
+	Actor_Id   string    `json:"actor_id,omitempty"`
+	Created_At time.Time `json:"created_at"`
+}
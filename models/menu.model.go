@@ -8,7 +8,7 @@ import (
 
 type Menu struct {
 	ID         primitive.ObjectID `bson:"_id"`
-	Name       *string            `json:"name" validate:"required,min=2,max-30"`
+	Name       *string            `json:"name" validate:"required,min=2,max=30"`
 	Category   *string            `json:"category"`
 	Start_Date time.Time          `json:"start_date"`
 	End_Date   time.Time          `json:"end_date"`
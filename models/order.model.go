@@ -7,10 +7,36 @@ import (
 )
 
 type Order struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Order_Date time.Time          `json:"order_date"`
-	Created_At time.Time          `json:"created_at"`
-	Updated_At time.Time          `json:"updated_at"`
-	Order_Id   string             `json:"order_id"`
-	Table_Id   *string            `json:"table_id"`
+	ID                primitive.ObjectID `bson:"_id"`
+	Order_Date        time.Time          `json:"order_date"`
+	Created_At        time.Time          `json:"created_at"`
+	Updated_At        time.Time          `json:"updated_at"`
+	Order_Id          string             `json:"order_id"`
+	Order_Type        string             `json:"order_type" validate:"omitempty,eq=DINE_IN|eq=TAKEAWAY|eq=DELIVERY"`
+	Source            string             `json:"source"`
+	External_Order_Id *string            `json:"external_order_id"`
+	Table_Id          *string            `json:"table_id"`
+	Customer_Id       *string            `json:"customer_id"`
+	Contact           *OrderContact      `json:"contact"`
+	Delivery_Address  *DeliveryAddress   `json:"delivery_address"`
+	Delivery_Zone_Id  *string            `json:"delivery_zone_id"`
+	Delivery_Fee      float64            `json:"delivery_fee"`
+	Minimum_Order     float64            `json:"minimum_order"`
+	Promised_Time     *time.Time         `json:"promised_time"`
+	// Server_Id is the clocked-in user who took the order.
+	Server_Id *string `json:"server_id"`
+}
+
+type OrderContact struct {
+	Name  string `json:"name" validate:"required,min=2,max=100"`
+	Phone string `json:"phone" validate:"required,min=5,max=20"`
+	Email string `json:"email" validate:"omitempty,email"`
+}
+
+type DeliveryAddress struct {
+	Line1       string `json:"line1" validate:"required,max=200"`
+	Line2       string `json:"line2" validate:"max=200"`
+	City        string `json:"city" validate:"required,max=100"`
+	Postal_Code string `json:"postal_code" validate:"required,max=20"`
+	Notes       string `json:"notes" validate:"max=200"`
 }
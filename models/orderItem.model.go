@@ -15,4 +15,7 @@ type OrderItem struct {
 	Food_Id       *string            `json:"food_id" validate:"required"`
 	Order_Item_Id string             `json:"order_item_id"`
 	Order_Id      string             `json:"order_id" validate:"required"`
+	Status        *string            `json:"status" validate:"omitempty,eq=AWAITING_APPROVAL|eq=PENDING|eq=PREPARING|eq=READY|eq=SERVED|eq=REJECTED"`
+	Source        string             `json:"source"`
+	Note          *string            `json:"note" validate:"omitempty,max=200"`
 }
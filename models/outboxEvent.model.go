@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OutboxEvent is a domain event written in the same operation as the change
+// that caused it and delivered to subscribers afterwards.
+type OutboxEvent struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Event_Id        string             `json:"event_id"`
+	Type            string             `json:"type"`
+	Aggregate_Id    string             `json:"aggregate_id"`
+	Payload         string             `json:"payload"`
+	Status          string             `json:"status"`
+	Delivered_To    []string           `json:"delivered_to"`
+	Attempts        int                `json:"attempts"`
+	Next_Attempt_At time.Time          `json:"next_attempt_at"`
+	Last_Error      string             `json:"last_error"`
+	Occurred_At     time.Time          `json:"occurred_at"`
+	Dispatched_At   time.Time          `json:"dispatched_at"`
+}
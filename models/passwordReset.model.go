@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PasswordReset is a single-use reset token. Only the SHA-256 hash of the
+// token is stored; the token itself is only ever sent to the user.
+type PasswordReset struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Token_Hash string             `json:"-"`
+	User_Id    string             `json:"user_id"`
+	Expires_At time.Time          `json:"expires_at"`
+	Used_At    *time.Time         `json:"used_at"`
+	Created_At time.Time          `json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Payment struct {
+	ID                 primitive.ObjectID `bson:"_id"`
+	Invoice_Id         string             `json:"invoice_id"`
+	Provider           string             `json:"provider"`
+	Provider_Reference string             `json:"provider_reference"`
+	Amount             float64            `json:"amount"`
+	Tip_Amount         float64            `json:"tip_amount"`
+	Authorized_Amount  float64            `json:"authorized_amount"`
+	Currency           string             `json:"currency"`
+	Status             string             `json:"status"`
+	Refunded_Amount    float64            `json:"refunded_amount"`
+	Decline_Code       string             `json:"decline_code"`
+	Message            string             `json:"message"`
+	History            []PaymentEvent     `json:"history"`
+	Created_At         time.Time          `json:"created_at"`
+	Updated_At         time.Time          `json:"updated_at"`
+	Payment_Id         string             `json:"payment_id"`
+}
+
+type PaymentEvent struct {
+	Status  string    `json:"status"`
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+}
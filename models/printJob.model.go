@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PrintJob struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Type         *string            `json:"type" validate:"required,eq=RECEIPT|eq=KITCHEN"`
+	Printer      *string            `json:"printer" validate:"required,min=1,max=50"`
+	Reference_Id *string            `json:"reference_id" validate:"required"`
+	Data         []byte             `json:"data"`
+	Status       string             `json:"status"`
+	Attempts     int                `json:"attempts"`
+	Error        string             `json:"error"`
+	Claimed_At   time.Time          `json:"claimed_at"`
+	Claimed_By   string             `json:"claimed_by"`
+	Created_At   time.Time          `json:"created_at"`
+	Updated_At   time.Time          `json:"updated_at"`
+	Print_Job_Id string             `json:"print_job_id"`
+}
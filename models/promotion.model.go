@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Promotion struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Name            *string            `json:"name" validate:"required,min=2,max=100"`
+	Type            *string            `json:"type" validate:"required,eq=PERCENTAGE|eq=FIXED|eq=BUY_X_GET_Y"`
+	Scope           *string            `json:"scope" validate:"required,eq=ITEM|eq=ORDER"`
+	Value           *float64           `json:"value" validate:"omitempty,gte=0"`
+	Food_Id         *string            `json:"food_id"`
+	Menu_Id         *string            `json:"menu_id"`
+	Buy_Quantity    *int               `json:"buy_quantity" validate:"omitempty,gte=1"`
+	Get_Quantity    *int               `json:"get_quantity" validate:"omitempty,gte=1"`
+	Start_Time      *string            `json:"start_time" validate:"omitempty,datetime=15:04"`
+	End_Time        *string            `json:"end_time" validate:"omitempty,datetime=15:04"`
+	Days_Of_Week    []int              `json:"days_of_week" validate:"omitempty,dive,gte=0,lte=6"`
+	Start_Date      time.Time          `json:"start_date"`
+	End_Date        time.Time          `json:"end_date"`
+	Requires_Coupon bool               `json:"requires_coupon"`
+	Active          *bool              `json:"active"`
+	Created_At      time.Time          `json:"created_at"`
+	Updated_At      time.Time          `json:"updated_at"`
+	Promotion_Id    string             `json:"promotion_id"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Shift is a scheduled shift a manager assigns to a user.
+type Shift struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	User_Id    *string            `json:"user_id" validate:"required"`
+	Role       *string            `json:"role" validate:"required,eq=ADMIN|eq=MANAGER|eq=CASHIER|eq=WAITER|eq=KITCHEN|eq=BAR"`
+	Start_Time *time.Time         `json:"start_time" validate:"required"`
+	End_Time   *time.Time         `json:"end_time" validate:"required,gtfield=Start_Time"`
+	Notes      *string            `json:"notes" validate:"omitempty,max=500"`
+	Created_At time.Time          `json:"created_at"`
+	Updated_At time.Time          `json:"updated_at"`
+	Shift_Id   string             `json:"shift_id"`
+}
+
+// TimeEntry is one clock-in to clock-out span. A user has at most one OPEN
+// entry; the minute totals are filled in when it is closed.
+type TimeEntry struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	User_Id        string             `json:"user_id"`
+	Shift_Id       *string            `json:"shift_id"`
+	Terminal_Id    *string            `json:"terminal_id"`
+	Status         string             `json:"status" validate:"eq=OPEN|eq=CLOSED"`
+	Clock_In       time.Time          `json:"clock_in"`
+	Clock_Out      *time.Time         `json:"clock_out"`
+	Breaks         []TimeEntryBreak   `json:"breaks"`
+	Break_Minutes  float64            `json:"break_minutes"`
+	Worked_Minutes float64            `json:"worked_minutes"`
+	Edited_By      *string            `json:"edited_by"`
+	Created_At     time.Time          `json:"created_at"`
+	Updated_At     time.Time          `json:"updated_at"`
+	Time_Entry_Id  string             `json:"time_entry_id"`
+}
+
+type TimeEntryBreak struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end"`
+}
+
+// Minutes returns the break and worked minutes up to the clock-out, or up
+// to now while the entry is open.
+func (e TimeEntry) Minutes(now time.Time) (breakMinutes, workedMinutes float64) {
+	end := now
+	if e.Clock_Out != nil {
+		end = *e.Clock_Out
+	}
+
+	for _, b := range e.Breaks {
+		breakEnd := end
+		if b.End != nil && b.End.Before(end) {
+			breakEnd = *b.End
+		}
+		if breakEnd.After(b.Start) {
+			breakMinutes += breakEnd.Sub(b.Start).Minutes()
+		}
+	}
+
+	workedMinutes = end.Sub(e.Clock_In).Minutes() - breakMinutes
+	if workedMinutes < 0 {
+		workedMinutes = 0
+	}
+	return breakMinutes, workedMinutes
+}
+
+// WorkedMinutesBetween returns the minutes worked inside [from, to), less
+// any breaks taken in that window.
+func (e TimeEntry) WorkedMinutesBetween(from, to, now time.Time) float64 {
+	end := now
+	if e.Clock_Out != nil {
+		end = *e.Clock_Out
+	}
+
+	worked := overlapMinutes(e.Clock_In, end, from, to)
+	for _, b := range e.Breaks {
+		breakEnd := end
+		if b.End != nil && b.End.Before(end) {
+			breakEnd = *b.End
+		}
+		worked -= overlapMinutes(b.Start, breakEnd, from, to)
+	}
+	return max(worked, 0)
+}
+
+func overlapMinutes(start, end, from, to time.Time) float64 {
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start).Minutes()
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(2024, 3, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestTimeEntryMinutes(t *testing.T) {
+	tests := []struct {
+		name              string
+		entry             TimeEntry
+		now               time.Time
+		breakMins, worked float64
+	}{
+		{
+			name:   "closed without breaks",
+			entry:  TimeEntry{Clock_In: at(9, 0), Clock_Out: ptr(at(17, 0))},
+			now:    at(20, 0),
+			worked: 480,
+		},
+		{
+			name: "closed with a break",
+			entry: TimeEntry{Clock_In: at(9, 0), Clock_Out: ptr(at(17, 0)), Breaks: []TimeEntryBreak{
+				{Start: at(12, 0), End: ptr(at(12, 30))},
+			}},
+			now:       at(20, 0),
+			breakMins: 30,
+			worked:    450,
+		},
+		{
+			name:   "open entry runs to now",
+			entry:  TimeEntry{Clock_In: at(9, 0)},
+			now:    at(10, 15),
+			worked: 75,
+		},
+		{
+			name: "open break runs to now",
+			entry: TimeEntry{Clock_In: at(9, 0), Breaks: []TimeEntryBreak{
+				{Start: at(10, 0)},
+			}},
+			now:       at(10, 20),
+			breakMins: 20,
+			worked:    60,
+		},
+		{
+			name: "break left open past clock-out stops at clock-out",
+			entry: TimeEntry{Clock_In: at(9, 0), Clock_Out: ptr(at(11, 0)), Breaks: []TimeEntryBreak{
+				{Start: at(10, 30)},
+			}},
+			now:       at(15, 0),
+			breakMins: 30,
+			worked:    90,
+		},
+		{
+			name: "worked never goes negative",
+			entry: TimeEntry{Clock_In: at(9, 0), Clock_Out: ptr(at(9, 10)), Breaks: []TimeEntryBreak{
+				{Start: at(9, 0), End: ptr(at(9, 10))},
+				{Start: at(9, 0), End: ptr(at(9, 10))},
+			}},
+			now:       at(15, 0),
+			breakMins: 20,
+			worked:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breakMins, worked := tt.entry.Minutes(tt.now)
+			if breakMins != tt.breakMins || worked != tt.worked {
+				t.Errorf("Minutes() = (%v, %v), want (%v, %v)", breakMins, worked, tt.breakMins, tt.worked)
+			}
+		})
+	}
+}
+
+func TestTimeEntryWorkedMinutesBetween(t *testing.T) {
+	entry := TimeEntry{Clock_In: at(9, 0), Clock_Out: ptr(at(17, 0)), Breaks: []TimeEntryBreak{
+		{Start: at(12, 0), End: ptr(at(12, 30))},
+	}}
+
+	tests := []struct {
+		name     string
+		entry    TimeEntry
+		from, to time.Time
+		now      time.Time
+		want     float64
+	}{
+		{name: "window covers the entry", entry: entry, from: at(0, 0), to: at(23, 0), now: at(23, 0), want: 450},
+		{name: "window before the break", entry: entry, from: at(8, 0), to: at(12, 0), now: at(23, 0), want: 180},
+		{name: "window splits the break", entry: entry, from: at(12, 15), to: at(13, 0), now: at(23, 0), want: 30},
+		{name: "window outside the entry", entry: entry, from: at(18, 0), to: at(19, 0), now: at(23, 0), want: 0},
+		{name: "open entry stops at now", entry: TimeEntry{Clock_In: at(9, 0)}, from: at(0, 0), to: at(23, 0), now: at(10, 0), want: 60},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.WorkedMinutesBetween(tt.from, tt.to, tt.now); got != tt.want {
+				t.Errorf("WorkedMinutesBetween() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SigningKey is one key of the token signing keyring. Keys are shared by
+// every server through the database, so anyone with read access to this
+// collection can mint tokens; restrict it accordingly.
+type SigningKey struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Kid         string             `json:"kid"`
+	Generation  int                `json:"generation"`
+	Algorithm   string             `json:"algorithm"`
+	Private_Key string             `json:"-"`
+	Public_Key  string             `json:"public_key"`
+	// Active_From is when the key starts signing. New keys are published
+	// in the JWKS a while before that so verifiers can pick them up.
+	Active_From time.Time `json:"active_from"`
+	Created_At  time.Time `json:"created_at"`
+}
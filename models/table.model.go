@@ -13,4 +13,5 @@ type Table struct {
 	Created_At       time.Time          `json:"created_at"`
 	Updated_At       time.Time          `json:"updated_at"`
 	Table_Id         string             `json:"table_id"`
+	Qr_Version       int                `json:"qr_version"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Terminal is a shared POS device registered by a manager. It proves its
+// identity with a key issued at registration; only the key's hash is kept.
+type Terminal struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Name         *string            `json:"name" validate:"required,min=2,max=100"`
+	Key_Hash     string             `json:"-"`
+	Active       *bool              `json:"active"`
+	Session      *TerminalSession   `json:"session"`
+	Last_Seen_At *time.Time         `json:"last_seen_at"`
+	Created_At   time.Time          `json:"created_at"`
+	Updated_At   time.Time          `json:"updated_at"`
+	Terminal_Id  string             `json:"terminal_id"`
+}
+
+// TerminalSession is the user currently signed in on a terminal. A new PIN
+// login replaces it, which ends the previous user's session.
+type TerminalSession struct {
+	Session_Id       string    `json:"session_id"`
+	User_Id          string    `json:"user_id"`
+	Started_At       time.Time `json:"started_at"`
+	Last_Activity_At time.Time `json:"last_activity_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TipPool is a tip-out rule: every server gives Percent of their tips to
+// the staff holding one of Roles, split by hours worked or evenly among
+// those who clocked in that business day.
+type TipPool struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Name        *string            `json:"name" validate:"required,min=2,max=100"`
+	Roles       []string           `json:"roles" validate:"required,min=1,dive,eq=ADMIN|eq=MANAGER|eq=CASHIER|eq=WAITER|eq=KITCHEN|eq=BAR"`
+	Percent     *float64           `json:"percent" validate:"required,gt=0,lte=100"`
+	Split       *string            `json:"split" validate:"required,eq=HOURS|eq=EVEN"`
+	Active      *bool              `json:"active"`
+	Created_At  time.Time          `json:"created_at"`
+	Updated_At  time.Time          `json:"updated_at"`
+	Tip_Pool_Id string             `json:"tip_pool_id"`
+}
@@ -7,16 +7,88 @@ import (
 )
 
 type User struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	First_Name    *string            `json:"first_name" validate:"required,min=2,max=100"`
-	Last_Name     *string            `json:"last_name" validate:"required,min=2,max=100"`
-	Password      *string            `json:"password" validate:"required,min=6"`
-	Email         *string            `json:"email" validate:"required,email"`
-	Avatar        *string            `json:"avatar"`
-	Phone         *string            `json:"phone" validate:"required"`
-	Token         *string            `json:"token"`
-	Refresh_Token *string            `json:"refresh_token"`
-	Created_At    time.Time          `json:"created_at"`
-	Updated_At    time.Time          `json:"updated_at"`
-	User_Id       string             `json:"user_id"`
+	ID         primitive.ObjectID `bson:"_id"`
+	First_Name *string            `json:"first_name" validate:"required,min=2,max=100"`
+	Last_Name  *string            `json:"last_name" validate:"required,min=2,max=100"`
+	Password   *string            `json:"password" validate:"required,min=6"`
+	Email      *string            `json:"email" validate:"required,email"`
+	Avatar     *string            `json:"avatar"`
+	// Avatar_Variants maps the stored avatar sizes to their URLs.
+	Avatar_Variants map[string]string `json:"avatar_variants,omitempty"`
+	Phone           *string           `json:"phone" validate:"required"`
+	Role            *string           `json:"role" validate:"omitempty,eq=ADMIN|eq=MANAGER|eq=CASHIER|eq=WAITER|eq=KITCHEN|eq=BAR"`
+	// Active is false for deactivated users; older users without the
+	// field are active.
+	Active        *bool   `json:"active"`
+	Token         *string `json:"token"`
+	Refresh_Token *string `json:"refresh_token"`
+	// Tokens issued before this instant are rejected.
+	Tokens_Valid_After time.Time `json:"tokens_valid_after"`
+	// Pin_Hash is the bcrypt hash of the staff PIN used on POS terminals.
+	Pin_Hash         *string    `json:"-"`
+	Pin_Failures     int        `json:"-"`
+	Pin_Locked_Until *time.Time `json:"pin_locked_until,omitempty"`
+	// Two-factor authentication. The pending secret is replaced by the
+	// real one once the first code is confirmed; recovery codes are kept
+	// as SHA-256 hashes.
+	Totp_Enabled        bool      `json:"totp_enabled"`
+	Totp_Secret         *string   `json:"-"`
+	Totp_Pending_Secret *string   `json:"-"`
+	Totp_Last_Step      int64     `json:"-"`
+	Recovery_Codes      []string  `json:"-"`
+	Created_At          time.Time `json:"created_at"`
+	Updated_At          time.Time `json:"updated_at"`
+	User_Id             string    `json:"user_id"`
+}
+
+// UserRoles lists the roles a user can hold. New sign-ups start as WAITER;
+// anything more is granted by an admin.
+var UserRoles = []string{"ADMIN", "MANAGER", "CASHIER", "WAITER", "KITCHEN", "BAR"}
+
+const DefaultUserRole = "WAITER"
+
+func (u User) IsActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+// PublicUser is what the API shows of a user. It leaves out the password
+// and token fields, which must never leave the server.
+type PublicUser struct {
+	User_Id         string            `json:"user_id"`
+	First_Name      *string           `json:"first_name"`
+	Last_Name       *string           `json:"last_name"`
+	Email           *string           `json:"email"`
+	Phone           *string           `json:"phone"`
+	Avatar          *string           `json:"avatar"`
+	Avatar_Variants map[string]string `json:"avatar_variants,omitempty"`
+	Role            *string           `json:"role"`
+	Active          bool              `json:"active"`
+	Totp_Enabled    bool              `json:"totp_enabled"`
+	Created_At      time.Time         `json:"created_at"`
+	Updated_At      time.Time         `json:"updated_at"`
+}
+
+func (u User) Public() PublicUser {
+	return PublicUser{
+		User_Id:         u.User_Id,
+		First_Name:      u.First_Name,
+		Last_Name:       u.Last_Name,
+		Email:           u.Email,
+		Phone:           u.Phone,
+		Avatar:          u.Avatar,
+		Avatar_Variants: u.Avatar_Variants,
+		Role:            u.Role,
+		Active:          u.IsActive(),
+		Totp_Enabled:    u.Totp_Enabled,
+		Created_At:      u.Created_At,
+		Updated_At:      u.Updated_At,
+	}
+}
+
+func PublicUsers(users []User) []PublicUser {
+	public := make([]PublicUser, 0, len(users))
+	for _, user := range users {
+		public = append(public, user.Public())
+	}
+	return public
 }
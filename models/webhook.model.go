@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Webhook struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Url         *string            `json:"url" validate:"required,url"`
+	Secret      *string            `json:"secret" validate:"required,min=16"`
+	Event_Types []string           `json:"event_types" validate:"required,min=1,dive,required"`
+	Description *string            `json:"description"`
+	Active      *bool              `json:"active"`
+	Created_At  time.Time          `json:"created_at"`
+	Updated_At  time.Time          `json:"updated_at"`
+	Webhook_Id  string             `json:"webhook_id"`
+}
+
+type WebhookDelivery struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Webhook_Id      string             `json:"webhook_id"`
+	Event_Id        string             `json:"event_id"`
+	Event_Type      string             `json:"event_type"`
+	Payload         string             `json:"payload"`
+	Status          string             `json:"status"`
+	Attempts        int                `json:"attempts"`
+	Next_Attempt_At time.Time          `json:"next_attempt_at"`
+	Last_Error      string             `json:"last_error"`
+	Log             []DeliveryAttempt  `json:"log"`
+	Created_At      time.Time          `json:"created_at"`
+	Updated_At      time.Time          `json:"updated_at"`
+	Delivery_Id     string             `json:"delivery_id"`
+}
+
+type DeliveryAttempt struct {
+	At            time.Time `json:"at"`
+	Response_Code int       `json:"response_code"`
+	Error         string    `json:"error"`
+	Duration_Ms   int64     `json:"duration_ms"`
+}
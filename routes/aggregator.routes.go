@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func AggregatorRoutes(router *gin.Engine) {
+	router.POST("/aggregators/:platform/orders", controllers.IngestAggregatorOrder())
+	router.GET("/aggregators/:platform/item-mappings", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.GetItemMappings())
+	router.POST("/aggregators/:platform/item-mappings", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.SaveItemMapping())
+	router.GET("/aggregator-orders", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.GetAggregatorOrders())
+}
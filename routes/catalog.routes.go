@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func CatalogRoutes(router *gin.Engine) {
+	router.GET("/catalog/menus/export", controllers.ExportMenus())
+	router.POST("/catalog/menus/import", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.ImportMenus())
+	router.GET("/catalog/foods/export", controllers.ExportFoods())
+	router.POST("/catalog/foods/import", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.ImportFoods())
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func CouponRoutes(router *gin.Engine) {
+	router.GET("/coupons", controllers.GetCoupons())
+	router.GET("/coupons/:code", controllers.GetCoupon())
+	router.POST("/coupons", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.CreateCoupon())
+	router.PATCH("/coupons/:code", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.UpdateCoupon())
+}
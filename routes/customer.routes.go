@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func CustomerRoutes(router *gin.Engine) {
+	router.GET("/customers", middleware.Authentication(), controllers.GetCustomers())
+	router.GET("/customers-lookup", middleware.Authentication(), controllers.LookupCustomer())
+	router.GET("/customers/:customer_id", middleware.Authentication(), controllers.GetCustomer())
+	router.GET("/customers/:customer_id/visits", middleware.Authentication(), controllers.GetCustomerVisits())
+	router.POST("/customers", middleware.Authentication(), controllers.CreateCustomer())
+	router.PATCH("/customers/:customer_id", middleware.Authentication(), controllers.UpdateCustomer())
+}
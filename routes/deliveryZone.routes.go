@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func DeliveryZoneRoutes(router *gin.Engine) {
+	router.GET("/delivery-zones", controllers.GetDeliveryZones())
+	router.GET("/delivery-zones/:delivery_zone_id", controllers.GetDeliveryZone())
+	router.POST("/delivery-zones", controllers.CreateDeliveryZone())
+	router.PATCH("/delivery-zones/:delivery_zone_id", controllers.UpdateDeliveryZone())
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func DrawerSessionRoutes(router *gin.Engine) {
+	router.GET("/drawer-sessions", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.GetDrawerSessions())
+	router.POST("/drawer-sessions", middleware.Authentication(), controllers.OpenDrawerSession())
+	router.GET("/drawer-sessions/current", middleware.Authentication(), controllers.GetCurrentDrawerSession())
+	router.GET("/drawer-sessions/:drawer_session_id", middleware.Authentication(), controllers.GetDrawerSession())
+	router.POST("/drawer-sessions/:drawer_session_id/movements", middleware.Authentication(), controllers.RecordDrawerMovement())
+	router.POST("/drawer-sessions/:drawer_session_id/close", middleware.Authentication(), controllers.CloseDrawerSession())
+	router.POST("/invoices/:invoice_id/cash-payments", middleware.Authentication(), controllers.CreateCashPayment())
+	router.GET("/reports/over-short", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.GetOverShort())
+}
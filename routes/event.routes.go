@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func EventRoutes(router *gin.Engine) {
+	router.GET("/events", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.GetEvents())
+	router.POST("/events/:event_id/retry", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.RetryEvent())
+}
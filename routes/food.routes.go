@@ -10,4 +10,6 @@ func FoodRoutes(router *gin.Engine) {
 	router.GET("/foods/:food_id", controllers.GetFood())
 	router.POST("/foods", controllers.CreateFood())
 	router.PATCH("/foods/:food_id", controllers.UpdateFood())
+	router.POST("/foods/:food_id/image", controllers.UploadFoodImage())
+	router.GET("/images/*key", controllers.ServeImage())
 }
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// guestRateKey limits each table code per client address, so one table
+// can't exhaust another's allowance.
+func guestRateKey(c *gin.Context) string {
+	return c.ClientIP() + "|" + c.Param("guest_token")
+}
+
+func GuestRoutes(router *gin.Engine) {
+	guest := router.Group("/guest/:guest_token")
+	guest.Use(middleware.RateLimit(60, time.Minute, guestRateKey), middleware.GuestTable())
+	guest.GET("/menu", controllers.GetGuestMenu())
+	guest.GET("/bill", controllers.GetGuestBill())
+	guest.POST("/items", middleware.RateLimit(10, time.Minute, guestRateKey), controllers.AddGuestItems())
+	guest.POST("/call-waiter", middleware.RateLimit(3, time.Minute, guestRateKey), controllers.CallWaiter())
+
+	router.GET("/guest-orders/pending", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER", "CASHIER", "WAITER"), controllers.GetPendingGuestItems())
+	router.POST("/guest-orders/:order_item_id/approve", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER", "CASHIER", "WAITER"), controllers.ApproveGuestItem())
+	router.POST("/guest-orders/:order_item_id/reject", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER", "CASHIER", "WAITER"), controllers.RejectGuestItem())
+}
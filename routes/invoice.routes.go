@@ -2,12 +2,16 @@ package routes
 
 import (
 	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
 	"github.com/gin-gonic/gin"
 )
 
 func InvoiceRoutes(router *gin.Engine) {
 	router.GET("/invoices", controllers.GetInvoices())
 	router.GET("/invoices/:invoice_id", controllers.GetInvoice())
-	router.POST("/invoices", controllers.CreateInvoice())
-	router.PATCH("/invoices/:invoice_id", controllers.UpdateInvoice())
+	router.POST("/invoices", middleware.Authentication(), controllers.CreateInvoice())
+	router.PATCH("/invoices/:invoice_id", middleware.Authentication(), controllers.UpdateInvoice())
+	router.POST("/invoices/:invoice_id/redeem-points", middleware.Authentication(), controllers.RedeemLoyaltyPoints())
+	router.GET("/invoices/:invoice_id/pdf", controllers.GetInvoicePDF())
+	router.POST("/invoices/:invoice_id/pdf", controllers.IssueInvoicePDF())
 }
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/gin-gonic/gin"
+)
+
+func KeyRoutes(router *gin.Engine) {
+	router.GET("/.well-known/jwks.json", controllers.GetJWKS())
+}
@@ -2,12 +2,13 @@ package routes
 
 import (
 	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
 	"github.com/gin-gonic/gin"
 )
 
 func OrderRoutes(router *gin.Engine) {
 	router.GET("/orders", controllers.GetOrders())
 	router.GET("/orders/:order_id", controllers.GetOrder())
-	router.POST("/orders", controllers.CreateOrder())
+	router.POST("/orders", middleware.Authentication(), middleware.ClockedIn(), controllers.CreateOrder())
 	router.PATCH("/orders/:order_id", controllers.UpdateOrder())
 }
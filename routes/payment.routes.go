@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func PaymentRoutes(router *gin.Engine) {
+	router.GET("/invoices/:invoice_id/payments", middleware.Authentication(), controllers.GetInvoicePayments())
+	router.POST("/invoices/:invoice_id/payments", middleware.Authentication(), controllers.CreateCardPayment())
+	router.GET("/payments/:payment_id", middleware.Authentication(), controllers.GetPayment())
+	router.POST("/payments/:payment_id/capture", middleware.Authentication(), controllers.CapturePayment())
+	router.POST("/payments/:payment_id/void", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.VoidPayment())
+	router.POST("/payments/:payment_id/refund", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.RefundPayment())
+	router.POST("/payment-webhooks/:provider", controllers.PaymentWebhook())
+}
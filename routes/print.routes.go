@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func PrintRoutes(router *gin.Engine) {
+	router.GET("/invoices/:invoice_id/receipt", controllers.GetInvoiceReceipt())
+	router.GET("/orders/:order_id/kitchen-ticket", controllers.GetKitchenTicket())
+	router.GET("/print-jobs", middleware.Authentication(), controllers.GetPrintJobs())
+	router.GET("/print-jobs/next", middleware.Terminal(), controllers.ClaimPrintJob())
+	router.POST("/print-jobs", middleware.Authentication(), controllers.CreatePrintJob())
+	router.POST("/print-jobs/:print_job_id/ack", middleware.Terminal(), controllers.AckPrintJob())
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func PromotionRoutes(router *gin.Engine) {
+	router.GET("/promotions", controllers.GetPromotions())
+	router.GET("/promotions/:promotion_id", controllers.GetPromotion())
+	router.POST("/promotions", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.CreatePromotion())
+	router.PATCH("/promotions/:promotion_id", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.UpdatePromotion())
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func ShiftRoutes(router *gin.Engine) {
+	router.GET("/shifts", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.GetShifts())
+	router.POST("/shifts", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.CreateShift())
+	router.PATCH("/shifts/:shift_id", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.UpdateShift())
+	router.DELETE("/shifts/:shift_id", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.DeleteShift())
+
+	router.GET("/clock", middleware.Authentication(), controllers.GetClockStatus())
+	router.POST("/clock/in", middleware.Authentication(), controllers.ClockIn())
+	router.POST("/clock/out", middleware.Authentication(), controllers.ClockOut())
+	router.POST("/clock/break/start", middleware.Authentication(), controllers.StartBreak())
+	router.POST("/clock/break/end", middleware.Authentication(), controllers.EndBreak())
+
+	router.PATCH("/time-entries/:time_entry_id", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.UpdateTimeEntry())
+	router.GET("/timesheets", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.GetTimesheets())
+	router.GET("/reports/sales-by-server", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.GetSalesByServer())
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
 	"github.com/gin-gonic/gin"
 )
 
@@ -10,4 +11,7 @@ func TableRoutes(router *gin.Engine) {
 	router.GET("/tables/:table_id", controllers.GetTable())
 	router.POST("/tables", controllers.CreateTable())
 	router.PATCH("/tables/:table_id", controllers.UpdateTable())
+	router.POST("/tables/:table_id/request-bill", controllers.RequestBill())
+	router.GET("/tables/:table_id/qr", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.GetTableQR())
+	router.POST("/tables/:table_id/qr", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.GenerateTableQR())
 }
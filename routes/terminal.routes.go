@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func terminalRateKey(c *gin.Context) string {
+	return c.ClientIP() + "|" + c.GetHeader("X-Terminal-Id")
+}
+
+func TerminalRoutes(router *gin.Engine) {
+	router.GET("/terminals", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.GetTerminals())
+	router.POST("/terminals", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.RegisterTerminal())
+	router.POST("/terminals/:terminal_id/deactivate", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.DeactivateTerminal())
+
+	router.GET("/terminal/staff", middleware.Terminal(), controllers.GetTerminalStaff())
+	router.POST("/terminal/lock", middleware.Terminal(), controllers.LockTerminal())
+
+	router.PUT("/users/pin", middleware.Authentication(), controllers.SetPin())
+	router.POST("/users/pin-login", middleware.RateLimit(30, time.Minute, terminalRateKey), middleware.Terminal(), controllers.PinLogin())
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func TipRoutes(router *gin.Engine) {
+	router.POST("/invoices/:invoice_id/tips", middleware.Authentication(), controllers.AddInvoiceTip())
+	router.GET("/tip-pools", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.GetTipPools())
+	router.POST("/tip-pools", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.CreateTipPool())
+	router.PATCH("/tip-pools/:tip_pool_id", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.UpdateTipPool())
+	router.GET("/reports/tip-out", middleware.Authentication(), middleware.RequireRole("ADMIN", "MANAGER"), controllers.GetTipOut())
+}
@@ -1,13 +1,37 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
 	"github.com/gin-gonic/gin"
 )
 
+func clientIPKey(c *gin.Context) string {
+	return c.ClientIP()
+}
+
 func UserRoutes(router *gin.Engine) {
-	router.GET("/users", controllers.GetUsers())
+	router.GET("/users", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.GetUsers())
+	router.GET("/users/me", middleware.Authentication(), controllers.GetMe())
+	router.PATCH("/users/me", middleware.Authentication(), controllers.UpdateMe())
+	router.POST("/users/me/avatar", middleware.Authentication(), controllers.UploadAvatar())
 	router.GET("/users/:user_id", controllers.GetUser())
+	router.POST("/users/:user_id/deactivate", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.DeactivateUser())
+	router.POST("/users/:user_id/reactivate", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.ReactivateUser())
 	router.POST("/users/signup", controllers.SignUp())
 	router.POST("/users/login", controllers.Login())
+	router.POST("/users/change-password", middleware.Authentication(), controllers.ChangePassword())
+	router.POST("/users/forgot-password", middleware.RateLimit(5, 15*time.Minute, clientIPKey), controllers.ForgotPassword())
+	router.POST("/users/reset-password", middleware.RateLimit(10, 15*time.Minute, clientIPKey), controllers.ResetPassword())
+	router.POST("/users/login/2fa", middleware.RateLimit(10, time.Minute, clientIPKey), controllers.VerifyLoginTwoFactor())
+	router.POST("/users/login/2fa/enroll", middleware.RateLimit(10, time.Minute, clientIPKey), controllers.EnrollLoginTwoFactor())
+	router.POST("/users/login/2fa/confirm", middleware.RateLimit(10, time.Minute, clientIPKey), controllers.ConfirmLoginTwoFactor())
+	router.POST("/users/2fa/enroll", middleware.Authentication(), controllers.EnrollTwoFactor())
+	router.POST("/users/2fa/confirm", middleware.Authentication(), controllers.ConfirmTwoFactor())
+	router.POST("/users/2fa/disable", middleware.Authentication(), controllers.DisableTwoFactor())
+	router.POST("/users/2fa/recovery-codes", middleware.Authentication(), controllers.RegenerateRecoveryCodes())
+	router.POST("/users/:user_id/unlock", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.UnlockUser())
+	router.GET("/login-attempts", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.GetLoginAttempts())
 }
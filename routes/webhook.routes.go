@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+func WebhookRoutes(router *gin.Engine) {
+	router.GET("/webhooks", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.GetWebhooks())
+	router.POST("/webhooks", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.CreateWebhook())
+	router.PATCH("/webhooks/:webhook_id", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.UpdateWebhook())
+	router.GET("/webhooks/:webhook_id/deliveries", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.GetWebhookDeliveries())
+	router.GET("/webhook-deliveries/dead", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.GetDeadWebhookDeliveries())
+	router.POST("/webhook-deliveries/:delivery_id/retry", middleware.Authentication(), middleware.RequireRole("ADMIN"), controllers.RetryWebhookDelivery())
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var seedMenus = []controllers.MenuRow{
+	{Name: "Breakfast", Category: "Morning"},
+	{Name: "Mains", Category: "All day"},
+	{Name: "Desserts", Category: "All day"},
+	{Name: "Drinks", Category: "Beverages"},
+}
+
+var seedFoods = []controllers.FoodRow{
+	{Name: "Pancakes", Price: seedPrice(6.50), Menu: "Breakfast"},
+	{Name: "Eggs Benedict", Price: seedPrice(9.00), Menu: "Breakfast"},
+	{Name: "Margherita Pizza", Price: seedPrice(11.00), Menu: "Mains"},
+	{Name: "Chicken Curry", Price: seedPrice(13.50), Menu: "Mains"},
+	{Name: "Veggie Burger", Price: seedPrice(10.00), Menu: "Mains"},
+	{Name: "Tiramisu", Price: seedPrice(5.50), Menu: "Desserts"},
+	{Name: "Cheesecake", Price: seedPrice(5.00), Menu: "Desserts"},
+	{Name: "Lemonade", Price: seedPrice(3.00), Menu: "Drinks"},
+	{Name: "Espresso", Price: seedPrice(2.20), Menu: "Drinks"},
+}
+
+func seedPrice(price float64) *float64 {
+	return &price
+}
+
+// runSeed loads sample data for development. Menus and foods are matched by
+// name and tables by number, so running it twice changes nothing.
+func runSeed(args []string) int {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	tables := flags.Int("tables", 8, "number of tables to create")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	start := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	end := time.Now().AddDate(1, 0, 0).Format("2006-01-02")
+	menus := make([]controllers.MenuRow, len(seedMenus))
+	for i, menu := range seedMenus {
+		menu.Start_Date, menu.End_Date = start, end
+		menus[i] = menu
+	}
+
+	report, err := controllers.ImportMenuRows(ctx, menus, false)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "seeding menus:", err)
+		return 1
+	}
+	if report.Failed > 0 {
+		fmt.Fprintln(os.Stderr, "seeding menus: rows failed validation:", report.Rows)
+		return 1
+	}
+	fmt.Printf("menus: %d created, %d updated\n", report.Created, report.Updated)
+
+	report, err = controllers.ImportFoodRows(ctx, seedFoods, false)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "seeding foods:", err)
+		return 1
+	}
+	if report.Failed > 0 {
+		fmt.Fprintln(os.Stderr, "seeding foods: rows failed validation:", report.Rows)
+		return 1
+	}
+	fmt.Printf("foods: %d created, %d updated\n", report.Created, report.Updated)
+
+	created, err := seedTables(ctx, *tables)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "seeding tables:", err)
+		return 1
+	}
+	fmt.Printf("tables: %d created\n", created)
+	return 0
+}
+
+func seedTables(ctx context.Context, count int) (int, error) {
+	tableModel := database.OpenCollection(database.MongoClient, "table")
+
+	created := 0
+	for number := 1; number <= count; number++ {
+		id := primitive.NewObjectID()
+		now := time.Now().UTC()
+		result, err := tableModel.UpdateOne(ctx,
+			bson.M{"table_number": number},
+			bson.M{"$setOnInsert": bson.M{
+				"_id":              id,
+				"table_id":         id.Hex(),
+				"table_number":     number,
+				"number_of_guests": 4,
+				"qr_version":       0,
+				"created_at":       now,
+				"updated_at":       now,
+			}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return created, err
+		}
+		if result.UpsertedCount > 0 {
+			created++
+		}
+	}
+	return created, nil
+}
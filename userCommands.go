@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/restaurant_management/controllers"
+	"github.com/djwhocodes/restaurant_management/database"
+	"github.com/djwhocodes/restaurant_management/helpers"
+	"github.com/djwhocodes/restaurant_management/models"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const userUsage = `usage: restaurant_management user <create|reset-password> [flags]
+
+  create          --email --first-name --last-name --phone --role [--password]
+  reset-password  --email [--password]
+
+Without --password the password is read as one line from stdin.`
+
+func runUser(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "create":
+		return runUserCreate(args[1:])
+	case "reset-password":
+		return runUserResetPassword(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+}
+
+func runUserCreate(args []string) int {
+	flags := flag.NewFlagSet("user create", flag.ContinueOnError)
+	email := flags.String("email", "", "email address (required)")
+	firstName := flags.String("first-name", "", "first name (required)")
+	lastName := flags.String("last-name", "", "last name (required)")
+	phone := flags.String("phone", "", "phone number (required)")
+	role := flags.String("role", models.DefaultUserRole, "one of "+strings.Join(models.UserRoles, ", "))
+	password := flags.String("password", "", "password, read from stdin when empty")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *password == "" {
+		var err error
+		if *password, err = readPassword(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	*email = controllers.NormalizeEmail(*email)
+	upperRole := strings.ToUpper(*role)
+	active := true
+	user := models.User{
+		First_Name: firstName,
+		Last_Name:  lastName,
+		Email:      email,
+		Phone:      phone,
+		Password:   password,
+		Role:       &upperRole,
+		Active:     &active,
+	}
+	if err := validator.New().Struct(user); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid user:", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	hashedPassword := controllers.HashPassword(*password)
+	user.Password = &hashedPassword
+	user.ID = primitive.NewObjectID()
+	user.User_Id = user.ID.Hex()
+	user.Created_At = time.Now()
+	user.Updated_At = time.Now()
+
+	userModel := database.OpenCollection(database.MongoClient, "user")
+	if _, err := userModel.InsertOne(ctx, user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			fmt.Fprintln(os.Stderr, "a user with that email or phone already exists")
+			return 1
+		}
+		fmt.Fprintln(os.Stderr, "creating user:", err)
+		return 1
+	}
+
+	fmt.Printf("created %s user %s (%s)\n", upperRole, *email, user.User_Id)
+	return 0
+}
+
+// runUserResetPassword sets a new password and revokes the user's tokens,
+// so existing sessions have to sign in again.
+func runUserResetPassword(args []string) int {
+	flags := flag.NewFlagSet("user reset-password", flag.ContinueOnError)
+	email := flags.String("email", "", "email address of the user (required)")
+	password := flags.String("password", "", "new password, read from stdin when empty")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *email == "" {
+		fmt.Fprintln(os.Stderr, "--email is required")
+		return 2
+	}
+
+	if *password == "" {
+		var err error
+		if *password, err = readPassword(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if len(*password) < 6 {
+		fmt.Fprintln(os.Stderr, "passwords must be at least 6 characters")
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	userModel := database.OpenCollection(database.MongoClient, "user")
+	result, err := userModel.UpdateOne(ctx,
+		bson.M{"email": *email},
+		bson.M{"$set": bson.M{
+			"password":   controllers.HashPassword(*password),
+			"updated_at": time.Now(),
+		}},
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "resetting password:", err)
+		return 1
+	}
+	if result.MatchedCount == 0 {
+		fmt.Fprintf(os.Stderr, "no user with email %s\n", *email)
+		return 1
+	}
+
+	if _, err := helpers.RevokeTokens(ctx, bson.M{"email": *email}); err != nil {
+		fmt.Fprintln(os.Stderr, "password changed but revoking tokens failed:", err)
+		return 1
+	}
+
+	fmt.Printf("password reset for %s\n", *email)
+	return 0
+}
+
+const tokensUsage = `usage: restaurant_management tokens revoke-all [--email EMAIL]`
+
+func runTokens(args []string) int {
+	if len(args) == 0 || args[0] != "revoke-all" {
+		fmt.Fprintln(os.Stderr, tokensUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("tokens revoke-all", flag.ContinueOnError)
+	email := flags.String("email", "", "only revoke this user's tokens")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if *email != "" {
+		filter["email"] = *email
+	}
+
+	revoked, err := helpers.RevokeTokens(ctx, filter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "revoking tokens:", err)
+		return 1
+	}
+
+	fmt.Printf("revoked tokens of %d user(s)\n", revoked)
+	return 0
+}
+
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("no password given on stdin")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}